@@ -39,16 +39,28 @@ consumeLoop:
 	for {
 		select {
 		case msg := <-partitionConsumer.Messages():
-			// Ищем заголовок dlq_reason
-			reason := "unknown reason"
+			// Собираем заголовки dlq_* с классом ошибки и метаданными источника
+			headers := make(map[string]string, len(msg.Headers))
 			for _, h := range msg.Headers {
-				if string(h.Key) == "dlq_reason" { // ← []byte → string
-					reason = string(h.Value)
-					break
-				}
+				headers[string(h.Key)] = string(h.Value) // ← []byte → string
+			}
+			reason, ok := headers["dlq_reason"]
+			if !ok {
+				reason = "unknown reason"
 			}
 
 			fmt.Printf("❌ INVALID ORDER (offset %d) → REASON: %s\n", msg.Offset, reason)
+			if detail, ok := headers["dlq_error"]; ok {
+				fmt.Printf("   Error: %s\n", detail)
+			}
+			if fields, ok := headers["dlq_invalid_fields"]; ok {
+				fmt.Printf("   Invalid fields: %s\n", fields)
+			}
+			if source, ok := headers["dlq_source_topic"]; ok {
+				fmt.Printf("   Source: %s[%s]@%s at %s, attempts: %s, consumer: %s\n",
+					source, headers["dlq_source_partition"], headers["dlq_source_offset"],
+					headers["dlq_source_timestamp"], headers["dlq_attempts"], headers["dlq_consumer"])
+			}
 			fmt.Printf("   Payload: %s\n\n", string(msg.Value))
 
 		case err := <-partitionConsumer.Errors():
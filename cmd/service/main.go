@@ -2,10 +2,13 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
 	"os"
 	"os/signal"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/config"
 	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/cache"
@@ -20,6 +23,14 @@ var (
 	cfgPath = "config/config.yaml"
 )
 
+// snapshotClockSkew — запас на расхождение часов сервиса и PostgreSQL при дозагрузке после снимка
+// или перезапуска с файловым хранилищем
+const snapshotClockSkew = time.Minute
+
+// consumerShutdownTimeout ограничивает ожидание остановки потребителя: он дообрабатывает текущее
+// сообщение и коммитит отмеченные смещения, пока БД и кэш ещё открыты
+const consumerShutdownTimeout = 30 * time.Second
+
 func main() {
 	logger := initializeLogger()
 	defer func() {
@@ -31,22 +42,21 @@ func main() {
 	ordersRepo := initializeRepository(cfg, logger)
 	defer closeRepository(ordersRepo, logger)
 
-	//инициализация всех таблиц через одну схему
-	err := migrations.InitializeDatabaseSchema(ordersRepo.DB, logger)
-	if err != nil {
+	// Инициализация таблиц БД через миграции с версионированием (migrations/versions):
+	// от них зависят updated_at, индексы прогрева и processed_messages
+	migrationManager := migrations.NewMigrationManager(ordersRepo.DB, logger)
+	if err := migrationManager.Up(); err != nil {
 		logger.Fatal("Failed to run migrations", zap.Error(err))
 	}
 
-	//инициализация таблиц бд через миграцию с версионированием
-	//migrationManager := migrations.NewMigrationManager(ordersRepo.DB, logger)
-	//if err := migrationManager.Up(); err != nil {
-	//	logger.Fatal("Failed to run migrations", zap.Error(err))
-	//}
-
-	appCache := initializeCache(cfg, ordersRepo, logger)
+	appCache, warmUp := initializeCache(cfg, ordersRepo, logger)
 	defer closeCache(appCache, logger)
 
+	// Сервис готов принимать трафик после завершения прогрева кэша
+	var ready atomic.Bool
 	httpServer := initializeController(cfg, appCache, logger)
+	httpServer.SetReadinessCheck(ready.Load)
+	httpServer.SetConsumerStats(consumer.CurrentStats)
 	startServer(httpServer, logger)
 
 	// Канал для системных сигналов
@@ -56,13 +66,19 @@ func main() {
 	defer cancel()
 
 	go func() {
+		warmUp(ctx)
+		ready.Store(true)
+	}()
+
+	consumerDone := make(chan struct{})
+	go func() {
+		defer close(consumerDone)
 		if err := consumer.Subscribe(
 			ctx,
 			appCache,
 			ordersRepo,
 			logger,
-			cfg.Kafka.Brokers,
-			cfg.Kafka.DlqTopic,
+			cfg.Kafka,
 		); err != nil {
 			logger.Error("Consumer error", zap.Error(err))
 		}
@@ -75,6 +91,12 @@ func main() {
 	cancel()
 	_ = httpServer.Shutdown()
 
+	select {
+	case <-consumerDone:
+	case <-time.After(consumerShutdownTimeout):
+		logger.Warn("Consumer did not stop in time", zap.Duration("timeout", consumerShutdownTimeout))
+	}
+
 	logger.Info("Application shut down gracefully")
 }
 
@@ -128,35 +150,153 @@ func closeRepository(repo *repository.OrdersRepo, logger *zap.Logger) {
 	}
 }
 
-func initializeCache(cfg *config.Config, ordersRepo *repository.OrdersRepo, logger *zap.Logger) cache.Cache {
-	// Создаем кэш на основе конфигурации
-	appCache, err := cache.New(cfg.Cache.ToCacheConfig())
+// initializeCache создаёт кэш и возвращает функцию его прогрева, которая запускается в фоне
+func initializeCache(cfg *config.Config, ordersRepo *repository.OrdersRepo, logger *zap.Logger) (cache.Cache, func(ctx context.Context)) {
+	cacheCfg, err := cfg.Cache.ToCacheConfig()
 	if err != nil {
-		logger.Fatal("Failed to initialize cache", zap.Error(err))
+		logger.Fatal("Invalid cache configuration", zap.Error(err))
 	}
 
-	// Загружаем заказы из БД в кэш
-	orders, err := ordersRepo.GetOrders()
+	// Создаем кэш на основе конфигурации
+	backend, err := cache.New(cacheCfg)
 	if err != nil {
-		logger.Fatal("Orders Load error", zap.Error(err))
+		logger.Fatal("Failed to initialize cache", zap.Error(err))
 	}
 
-	for _, order := range orders {
-		if err := appCache.SaveOrder(order); err != nil {
-			logger.Error("Failed to save order to cache",
-				zap.String("order_uid", order.OrderUID),
+	// При промахе кэш дочитывает заказы из PostgreSQL
+	appCache := cache.NewReadThroughCache(backend, ordersRepo, cacheCfg.NegativeTTL)
+
+	logger.Info("Cache initialized successfully",
+		zap.String("cache_type", string(cfg.Cache.Type)),
+		zap.String("warm_up_strategy", string(cacheCfg.WarmUp.Strategy)))
+
+	warmUp := func(ctx context.Context) {
+		start := time.Now()
+		// Снимок с дозагрузкой изменённых заказов заменяет прогрев по стратегии
+		if restoreFromSnapshot(ctx, appCache, backend, cacheCfg.SnapshotPath, ordersRepo, logger) {
+			return
+		}
+		// Файловое хранилище пережило перезапуск: дочитываем только изменённые заказы
+		if restoreFromDisk(ctx, appCache, backend, ordersRepo, logger) {
+			return
+		}
+
+		loaded, err := warmUpCache(ctx, appCache, backend, ordersRepo, cacheCfg.WarmUp)
+		if errors.Is(err, cache.ErrRebuildInProgress) {
+			logger.Info("Cache is being rebuilt by another replica, skipping warm-up")
+			return
+		}
+		if err != nil {
+			// Недостающие заказы дочитает read-through
+			logger.Error("Cache warm-up failed",
+				zap.Int("orders_loaded", loaded),
 				zap.Error(err))
+			return
+		}
+		logger.Info("Cache warm-up completed",
+			zap.String("strategy", string(cacheCfg.WarmUp.Strategy)),
+			zap.Int("orders_loaded", loaded),
+			zap.Duration("duration", time.Since(start)))
+	}
+
+	return appCache, warmUp
+}
+
+// warmUpCache прогревает кэш по стратегии. Общий для реплик кэш (redis, tiered) перестраивается
+// в новом поколении ключей под распределённой блокировкой: читатели не видят его пустым,
+// а одновременно стартующие реплики не перетирают работу друг друга.
+func warmUpCache(ctx context.Context, appCache, backend cache.Cache, ordersRepo *repository.OrdersRepo, cfg cache.WarmUpConfig) (int, error) {
+	rebuilder, ok := backend.(cache.Rebuilder)
+	if !ok || cfg.Strategy == cache.WarmUpNone {
+		return cache.WarmUp(ctx, appCache, ordersRepo, cfg)
+	}
+
+	var loaded int
+	err := rebuilder.Rebuild(ctx, func(ctx context.Context, c cache.Cache) error {
+		var err error
+		loaded, err = cache.WarmUp(ctx, c, ordersRepo, cfg)
+		return err
+	})
+	return loaded, err
+}
+
+// restoreFromSnapshot восстанавливает кэш из снимка (если снимки включены) и загружает из БД
+// заказы, изменённые после снимка. Возвращает false, если снимка нет или он не читается.
+func restoreFromSnapshot(ctx context.Context, appCache, backend cache.Cache, snapshotPath string, ordersRepo *repository.OrdersRepo, logger *zap.Logger) bool {
+	snapshotter, ok := backend.(cache.Snapshotter)
+	if snapshotPath == "" || !ok {
+		return false
+	}
+
+	createdAt, err := cache.LoadSnapshot(snapshotter, snapshotPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			logger.Info("Cache snapshot not found, running warm-up", zap.String("path", snapshotPath))
 		} else {
-			logger.Info("Order cached successfully",
-				zap.String("order_uid", order.OrderUID))
+			// Неполный или повреждённый снимок не меняет кэш, поэтому достаточно обычного прогрева
+			logger.Warn("Failed to restore cache from snapshot, running warm-up",
+				zap.String("path", snapshotPath),
+				zap.Error(err))
 		}
+		return false
 	}
 
-	logger.Info("Cache initialized successfully",
-		zap.Int("orders_loaded", len(orders)),
-		zap.String("cache_type", string(cfg.Cache.Type)))
+	changed, ok := saveChangedSince(ctx, appCache, createdAt, ordersRepo, logger)
+	if !ok {
+		return false
+	}
 
-	return appCache
+	logger.Info("Cache restored from snapshot",
+		zap.String("path", snapshotPath),
+		zap.Time("snapshot_time", createdAt),
+		zap.Int("orders_changed", changed))
+	return true
+}
+
+// restoreFromDisk дозагружает в непустое файловое хранилище (bolt) заказы, изменённые
+// после его последней записи. Возвращает false, если хранилище пусто и нужен прогрев.
+func restoreFromDisk(ctx context.Context, appCache, backend cache.Cache, ordersRepo *repository.OrdersRepo, logger *zap.Logger) bool {
+	store, ok := backend.(*cache.BoltCache)
+	if !ok {
+		return false
+	}
+
+	stats, err := store.Stats(ctx)
+	if err != nil || stats.Size == 0 {
+		return false
+	}
+	updatedAt, err := store.UpdatedAt()
+	if err != nil {
+		logger.Warn("Failed to read cache store metadata, running warm-up", zap.Error(err))
+		return false
+	}
+
+	changed, ok := saveChangedSince(ctx, appCache, updatedAt, ordersRepo, logger)
+	if !ok {
+		return false
+	}
+
+	logger.Info("Cache restored from disk",
+		zap.Int64("orders_stored", stats.Size),
+		zap.Time("last_write", updatedAt),
+		zap.Int("orders_changed", changed))
+	return true
+}
+
+// saveChangedSince сохраняет в кэш заказы, изменённые в БД после since (с запасом на расхождение часов).
+// Возвращает false, если заказы не удалось прочитать из БД.
+func saveChangedSince(ctx context.Context, appCache cache.Cache, since time.Time, ordersRepo *repository.OrdersRepo, logger *zap.Logger) (int, bool) {
+	orders, err := ordersRepo.GetOrdersUpdatedSince(since.Add(-snapshotClockSkew))
+	if err != nil {
+		logger.Warn("Failed to load changed orders, running warm-up", zap.Error(err))
+		return 0, false
+	}
+	if err := cache.FillOrders(ctx, appCache, orders); err != nil {
+		logger.Error("Failed to save changed orders",
+			zap.Int("orders_count", len(orders)),
+			zap.Error(err))
+	}
+	return len(orders), true
 }
 
 func closeCache(appCache cache.Cache, logger *zap.Logger) {
@@ -37,6 +37,16 @@ type KafkaConfig struct {
 	Brokers  []string `yaml:"brokers" env:"KAFKA_BROKERS" env-separator:"," env-default:"localhost:9092"`
 	Topic    string   `yaml:"topic" env:"KAFKA_TOPIC" env-default:"orders"`
 	DlqTopic string   `yaml:"dlq_topic" env:"KAFKA_DLQ_TOPIC" env-default:"orders.dlq"`
+	// Группа потребителей: партиции топика делятся между репликами группы, смещения коммитятся в Kafka
+	GroupID string `yaml:"group_id" env:"KAFKA_GROUP_ID" env-default:"orders-service"`
+	// Задержки ступеней повторов при ошибке сохранения в БД: для "1m" — топик {topic}.retry.1m.
+	// После последней ступени сообщение уходит в DLQ; пустой список — сразу в DLQ
+	RetryDelays []string `yaml:"retry_delays" env:"KAFKA_RETRY_DELAYS" env-separator:"," env-default:"1m,10m,1h"`
+	// Пакетная обработка: сообщения партиции копятся до BatchSize штук или в течение BatchInterval,
+	// проверяются параллельно в BatchWorkers потоках и сохраняются одной транзакцией. 1 — по одному
+	BatchSize     int    `yaml:"batch_size" env:"KAFKA_BATCH_SIZE" env-default:"1"`
+	BatchInterval string `yaml:"batch_interval" env:"KAFKA_BATCH_INTERVAL" env-default:"100ms"`
+	BatchWorkers  int    `yaml:"batch_workers" env:"KAFKA_BATCH_WORKERS" env-default:"4"`
 }
 
 // CacheConfig конфигурация кэша
@@ -47,7 +57,94 @@ type CacheConfig struct {
 	Password string          `yaml:"password" env:"CACHE_PASSWORD" env-default:""`
 	DB       int             `yaml:"db" env:"CACHE_DB" env-default:"0"`
 	Capacity int             `yaml:"capacity" env:"CACHE_CAPACITY" env-default:"1000"`
+	MaxBytes int64           `yaml:"max_bytes" env:"CACHE_MAX_BYTES" env-default:"0"`
+	Shards   int             `yaml:"shards" env:"CACHE_SHARDS" env-default:"1"`
 	TTL      string          `yaml:"ttl" env:"CACHE_TTL" env-default:"30m"`
+
+	// Мягкий TTL (только для inmemory): после него заказ отдаётся устаревшим и обновляется из БД
+	SoftTTL string `yaml:"soft_ttl" env:"CACHE_SOFT_TTL"`
+
+	PromoteWindow string               `yaml:"promote_window" env:"CACHE_PROMOTE_WINDOW"`
+	Eviction      cache.EvictionPolicy `yaml:"eviction" env:"CACHE_EVICTION" env-default:"lru"`
+
+	// Снимок in-memory кэша на диск (только для inmemory; пустой путь — выключен)
+	SnapshotPath     string `yaml:"snapshot_path" env:"CACHE_SNAPSHOT_PATH"`
+	SnapshotInterval string `yaml:"snapshot_interval" env:"CACHE_SNAPSHOT_INTERVAL" env-default:"5m"`
+
+	NegativeTTL string `yaml:"negative_ttl" env:"CACHE_NEGATIVE_TTL" env-default:"30s"`
+
+	Invalidation InvalidationConfig `yaml:"invalidation"`
+
+	TTLPolicy TTLPolicyConfig `yaml:"ttl_policy"`
+
+	WarmUp WarmUpConfig `yaml:"warm_up"`
+
+	// Файл встроенного хранилища (только для bolt)
+	Path string `yaml:"path" env:"CACHE_PATH" env-default:"cache.db"`
+
+	// Сериализация заказов (для redis, tiered и bolt)
+	Codec             cache.CodecFormat `yaml:"codec" env:"CACHE_CODEC" env-default:"json"`
+	Compression       cache.Compression `yaml:"compression" env:"CACHE_COMPRESSION" env-default:"none"`
+	CompressThreshold int               `yaml:"compress_threshold" env:"CACHE_COMPRESS_THRESHOLD" env-default:"512"`
+
+	// Топология Redis (для redis, tiered и шины инвалидации)
+	Mode             cache.RedisMode `yaml:"mode" env:"CACHE_REDIS_MODE" env-default:"standalone"`
+	Addrs            []string        `yaml:"addrs" env:"CACHE_ADDRS" env-separator:","`
+	MasterName       string          `yaml:"master_name" env:"CACHE_MASTER_NAME"`
+	Username         string          `yaml:"username" env:"CACHE_USERNAME"`
+	SentinelPassword string          `yaml:"sentinel_password" env:"CACHE_SENTINEL_PASSWORD"`
+	TLS              CacheTLSConfig  `yaml:"tls"`
+}
+
+// CacheTLSConfig настройки TLS-подключения к Redis
+type CacheTLSConfig struct {
+	Enabled            bool   `yaml:"enabled" env:"CACHE_TLS_ENABLED" env-default:"false"`
+	CAFile             string `yaml:"ca_file" env:"CACHE_TLS_CA_FILE"`
+	ServerName         string `yaml:"server_name" env:"CACHE_TLS_SERVER_NAME"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify" env:"CACHE_TLS_INSECURE_SKIP_VERIFY" env-default:"false"`
+}
+
+// TTLPolicyConfig правила сокращения TTL для отдельных заказов (пустые значения отключают правило)
+type TTLPolicyConfig struct {
+	AgedAfter        string `yaml:"aged_after" env:"CACHE_TTL_AGED_AFTER"`
+	AgedTTL          string `yaml:"aged_ttl" env:"CACHE_TTL_AGED"`
+	TerminalStatuses []int  `yaml:"terminal_statuses" env:"CACHE_TTL_TERMINAL_STATUSES" env-separator:","`
+	TerminalTTL      string `yaml:"terminal_ttl" env:"CACHE_TTL_TERMINAL"`
+}
+
+// InvalidationConfig — шина инвалидации между репликами через Redis pub/sub.
+// Для inmemory включается Enabled (подключение — по настройкам Redis), tiered использует шину всегда.
+type InvalidationConfig struct {
+	Enabled bool   `yaml:"enabled" env:"CACHE_INVALIDATION_ENABLED" env-default:"false"`
+	Channel string `yaml:"channel" env:"CACHE_INVALIDATION_CHANNEL" env-default:"orders:invalidate"`
+}
+
+// WarmUpConfig — прогрев кэша при старте
+type WarmUpConfig struct {
+	Strategy cache.WarmUpStrategy `yaml:"strategy" env:"CACHE_WARM_UP_STRATEGY" env-default:"recent"`
+	Count    int                  `yaml:"count" env:"CACHE_WARM_UP_COUNT" env-default:"1000"`
+	Window   string               `yaml:"window" env:"CACHE_WARM_UP_WINDOW" env-default:"24h"`
+	UIDFile  string               `yaml:"uid_file" env:"CACHE_WARM_UP_UID_FILE"`
+	PageSize int                  `yaml:"page_size" env:"CACHE_WARM_UP_PAGE_SIZE" env-default:"500"`
+}
+
+// toCacheConfig преобразует настройки прогрева в конфиг кэша
+func (w *WarmUpConfig) toCacheConfig() (cache.WarmUpConfig, error) {
+	window, err := parseOptionalDuration("cache.warm_up.window", w.Window)
+	if err != nil {
+		return cache.WarmUpConfig{}, err
+	}
+	cfg := cache.WarmUpConfig{
+		Strategy: w.Strategy,
+		Count:    w.Count,
+		Window:   window,
+		UIDFile:  w.UIDFile,
+		PageSize: w.PageSize,
+	}
+	if err := cfg.Validate(); err != nil {
+		return cache.WarmUpConfig{}, fmt.Errorf("invalid cache.warm_up: %w", err)
+	}
+	return cfg, nil
 }
 
 // Load загружает конфигурацию из файла
@@ -109,6 +206,34 @@ func (c *Config) Validate() error {
 	if c.Kafka.DlqTopic == "" { // ← новая проверка
 		return fmt.Errorf("kafka.dlq_topic is required")
 	}
+	if c.Kafka.GroupID == "" {
+		return fmt.Errorf("kafka.group_id is required")
+	}
+	for _, delay := range c.Kafka.RetryDelays {
+		d, err := time.ParseDuration(delay)
+		if err != nil {
+			return fmt.Errorf("invalid kafka.retry_delays value %q: %w", delay, err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("kafka.retry_delays must be positive, got %q", delay)
+		}
+	}
+
+	if c.Kafka.BatchSize < 1 {
+		return fmt.Errorf("kafka.batch_size must be at least 1, got %d", c.Kafka.BatchSize)
+	}
+	if c.Kafka.BatchSize > 1 {
+		interval, err := time.ParseDuration(c.Kafka.BatchInterval)
+		if err != nil {
+			return fmt.Errorf("invalid kafka.batch_interval format: %w", err)
+		}
+		if interval <= 0 {
+			return fmt.Errorf("kafka.batch_interval must be positive, got %q", c.Kafka.BatchInterval)
+		}
+		if c.Kafka.BatchWorkers < 1 {
+			return fmt.Errorf("kafka.batch_workers must be at least 1, got %d", c.Kafka.BatchWorkers)
+		}
+	}
 
 	if err := c.Cache.Validate(); err != nil {
 		return fmt.Errorf("cache validation failed: %w", err)
@@ -117,30 +242,57 @@ func (c *Config) Validate() error {
 	return nil
 }
 
-// Validate проверяет корректность конфигурации кэша
+// Validate проверяет корректность конфигурации кэша. Форматы значений разбирает ToCacheConfig,
+// а настройки бэкенда проверяет валидатор его типа из реестра кэша.
 func (c *CacheConfig) Validate() error {
-	switch c.Type {
-	case cache.CacheTypeRedis:
-		if c.Host == "" {
-			return fmt.Errorf("cache.host is required for redis cache")
-		}
-		if c.Port == "" {
-			return fmt.Errorf("cache.port is required for redis cache")
-		}
-	case cache.CacheTypeInMemory:
-		if c.Capacity <= 0 {
-			return fmt.Errorf("cache.capacity must be positive for in-memory cache")
-		}
-		// Проверяем, что TTL парсится
-		if c.TTL != "" {
-			if _, err := time.ParseDuration(c.TTL); err != nil {
-				return fmt.Errorf("invalid cache.ttl format: %w", err)
-			}
-		}
-	default:
-		return fmt.Errorf("unknown cache type: %s", c.Type)
+	// Правила политики TTL разбираются, даже если политика не включена
+	if _, err := c.TTLPolicy.toRules(); err != nil {
+		return err
 	}
-	return nil
+	if c.CompressThreshold < 0 {
+		return fmt.Errorf("cache.compress_threshold must not be negative")
+	}
+
+	cacheCfg, err := c.ToCacheConfig()
+	if err != nil {
+		return err
+	}
+	return cache.Validate(cacheCfg)
+}
+
+// toRules преобразует настройки политики TTL в правила кэша
+func (p *TTLPolicyConfig) toRules() (cache.OrderTTLRules, error) {
+	var rules cache.OrderTTLRules
+	var err error
+
+	if rules.AgedAfter, err = parseOptionalDuration("cache.ttl_policy.aged_after", p.AgedAfter); err != nil {
+		return rules, err
+	}
+	if rules.AgedTTL, err = parseOptionalDuration("cache.ttl_policy.aged_ttl", p.AgedTTL); err != nil {
+		return rules, err
+	}
+	if rules.TerminalTTL, err = parseOptionalDuration("cache.ttl_policy.terminal_ttl", p.TerminalTTL); err != nil {
+		return rules, err
+	}
+	rules.TerminalStatuses = p.TerminalStatuses
+	return rules, nil
+}
+
+// enabled сообщает, задано ли хотя бы одно правило политики TTL
+func (p *TTLPolicyConfig) enabled() bool {
+	return p.AgedTTL != "" || p.TerminalTTL != ""
+}
+
+// parseOptionalDuration разбирает необязательную длительность; пустая строка даёт 0
+func parseOptionalDuration(name, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s format: %w", name, err)
+	}
+	return d, nil
 }
 
 // GetDBConnectionString возвращает строку подключения к базе данных
@@ -154,9 +306,10 @@ func (c *ConfigApp) GetAppAddress() string {
 	return fmt.Sprintf("%s:%s", c.Host, c.Port)
 }
 
-// GetCacheAddress возвращает адрес кэша в формате host:port (только для Redis)
+// GetCacheAddress возвращает адрес Redis в формате host:port (пусто, если хост не задан).
+// Адрес нужен не только redis и tiered, но и шине инвалидации inmemory и сторонним бэкендам.
 func (c *CacheConfig) GetCacheAddress() string {
-	if c.Type != cache.CacheTypeRedis {
+	if c.Host == "" {
 		return ""
 	}
 	return fmt.Sprintf("%s:%s", c.Host, c.Port)
@@ -177,14 +330,83 @@ func (c *CacheConfig) ToCacheConfig() (cache.Config, error) {
 		ttl = 30 * time.Minute
 	}
 
+	var negativeTTL time.Duration
+	if c.NegativeTTL != "" {
+		negativeTTL, err = time.ParseDuration(c.NegativeTTL)
+		if err != nil {
+			return cache.Config{}, fmt.Errorf("invalid cache.negative_ttl format: %w", err)
+		}
+	}
+
+	promoteWindow, err := parseOptionalDuration("cache.promote_window", c.PromoteWindow)
+	if err != nil {
+		return cache.Config{}, err
+	}
+
+	snapshotInterval, err := parseOptionalDuration("cache.snapshot_interval", c.SnapshotInterval)
+	if err != nil {
+		return cache.Config{}, err
+	}
+
+	softTTL, err := parseOptionalDuration("cache.soft_ttl", c.SoftTTL)
+	if err != nil {
+		return cache.Config{}, err
+	}
+
+	warmUp, err := c.WarmUp.toCacheConfig()
+	if err != nil {
+		return cache.Config{}, err
+	}
+
+	var ttlPolicy cache.TTLPolicy
+	if c.TTLPolicy.enabled() {
+		rules, err := c.TTLPolicy.toRules()
+		if err != nil {
+			return cache.Config{}, err
+		}
+		ttlPolicy = rules.Policy()
+	}
+
 	return cache.Config{
 		Type: c.Type,
 		Redis: cache.RedisConfig{
-			Addr:     c.GetCacheAddress(),
-			Password: c.Password,
-			DB:       c.DB,
+			Mode:             c.Mode,
+			Addr:             c.GetCacheAddress(),
+			Addrs:            c.Addrs,
+			MasterName:       c.MasterName,
+			Username:         c.Username,
+			Password:         c.Password,
+			DB:               c.DB,
+			SentinelPassword: c.SentinelPassword,
+			TLS: cache.RedisTLSConfig{
+				Enabled:            c.TLS.Enabled,
+				CAFile:             c.TLS.CAFile,
+				ServerName:         c.TLS.ServerName,
+				InsecureSkipVerify: c.TLS.InsecureSkipVerify,
+			},
 		},
-		Capacity: c.Capacity,
-		TTL:      ttl,
+		Capacity:    c.Capacity,
+		MaxBytes:    c.MaxBytes,
+		Shards:      c.Shards,
+		TTL:         ttl,
+		SoftTTL:     softTTL,
+		NegativeTTL: negativeTTL,
+		TTLPolicy:   ttlPolicy,
+		WarmUp:      warmUp,
+
+		InvalidationBus:     c.Invalidation.Enabled,
+		InvalidationChannel: c.Invalidation.Channel,
+
+		PromoteWindow: promoteWindow,
+		Eviction:      c.Eviction,
+
+		SnapshotPath:     c.SnapshotPath,
+		SnapshotInterval: snapshotInterval,
+
+		Path: c.Path,
+
+		Codec:             c.Codec,
+		Compression:       c.Compression,
+		CompressThreshold: c.CompressThreshold,
 	}, nil
 }
@@ -0,0 +1,555 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/models"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// defaultBoltTTL — время жизни записи во встроенном хранилище, если TTL не задан
+	defaultBoltTTL = 24 * time.Hour
+	// boltOpenTimeout ограничивает ожидание файловой блокировки, если файл открыт другим процессом
+	boltOpenTimeout = 5 * time.Second
+	// boltCompactInterval — период фонового удаления истёкших записей
+	boltCompactInterval = time.Minute
+	// boltCompactBatch — сколько истёкших записей удаляется за одну транзакцию,
+	// чтобы компакция не держала блокировку записи надолго
+	boltCompactBatch = 1000
+)
+
+// Бакеты хранилища. Запись заказа — 8 байт срока жизни (UnixNano, big-endian) и заказ в кодеке кэша.
+// Бакет сроков упорядочен по времени истечения, поэтому компакция читает только истёкший префикс.
+var (
+	boltOrdersBucket = []byte("orders") // uid → срок жизни и заказ
+	boltExpiryBucket = []byte("expiry") // срок жизни + uid → пусто
+	boltIndexBucket  = []byte("index")  // поле \x00 значение \x00 uid → пусто
+	boltMetaBucket   = []byte("meta")
+
+	boltUpdatedAtKey = []byte("updated_at") // время последней записи (UnixNano)
+)
+
+// BoltCache — кэш во встроенном файловом хранилище bbolt для развёртываний с одним узлом без Redis.
+// Содержимое переживает перезапуск сервиса. TTL хранится рядом с заказом: истёкшие записи
+// не отдаются при чтении и удаляются фоновой компакцией; освобождённые страницы файла
+// bbolt переиспользует для новых записей. Файл может открыть только один процесс.
+type BoltCache struct {
+	db   *bolt.DB
+	opts options // TTL, политика TTL по заказу и кодек
+
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+
+	hits        atomic.Uint64
+	misses      atomic.Uint64
+	expirations atomic.Uint64
+}
+
+// NewBoltCache открывает (или создаёт) файл хранилища и запускает фоновую компакцию.
+// По умолчанию записи живут 24 часа; opts позволяют задать TTL, политику TTL и кодек.
+func NewBoltCache(path string, opts ...Option) (*BoltCache, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: boltOpenTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bolt cache %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltOrdersBucket, boltExpiryBucket, boltIndexBucket, boltMetaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to initialize bolt cache: %w", err)
+	}
+
+	c := &BoltCache{
+		db:     db,
+		opts:   applyOptions(options{ttl: defaultBoltTTL, codec: DefaultCodec()}, opts),
+		stopCh: make(chan struct{}),
+	}
+
+	c.wg.Add(1)
+	go c.compactLoop(boltCompactInterval)
+
+	return c, nil
+}
+
+// SaveOrder сохраняет заказ с TTL, вычисленным по настройкам и политике кэша
+func (c *BoltCache) SaveOrder(ctx context.Context, order models.Order) error {
+	return c.SaveOrders(ctx, []models.Order{order})
+}
+
+// SaveOrders сохраняет заказы одной транзакцией
+func (c *BoltCache) SaveOrders(ctx context.Context, orders []models.Order) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(orders) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		for _, order := range orders {
+			if err := c.put(tx, order, now.Add(c.opts.ttlFor(order))); err != nil {
+				return err
+			}
+		}
+		return touchUpdatedAt(tx, now)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save orders to bolt cache: %w", err)
+	}
+	return nil
+}
+
+// put записывает заказ, его срок жизни и вторичные индексы, заменяя прежнюю запись
+func (c *BoltCache) put(tx *bolt.Tx, order models.Order, exp time.Time) error {
+	if _, err := c.delete(tx, order.OrderUID); err != nil {
+		return err
+	}
+
+	data, err := c.opts.codec.Encode(order)
+	if err != nil {
+		return err
+	}
+	value := make([]byte, 8+len(data))
+	binary.BigEndian.PutUint64(value, uint64(exp.UnixNano()))
+	copy(value[8:], data)
+
+	uid := []byte(order.OrderUID)
+	if err := tx.Bucket(boltOrdersBucket).Put(uid, value); err != nil {
+		return err
+	}
+	if err := tx.Bucket(boltExpiryBucket).Put(boltExpiryKey(exp.UnixNano(), uid), nil); err != nil {
+		return err
+	}
+	index := tx.Bucket(boltIndexBucket)
+	for _, field := range indexFields {
+		if fieldValue := field.value(order); fieldValue != "" {
+			if err := index.Put(boltIndexKey(field, fieldValue, order.OrderUID), nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// delete удаляет заказ вместе со сроком жизни и записями индексов; false — заказа не было
+func (c *BoltCache) delete(tx *bolt.Tx, orderUID string) (bool, error) {
+	orders := tx.Bucket(boltOrdersBucket)
+	uid := []byte(orderUID)
+	value := orders.Get(uid)
+	if value == nil {
+		return false, nil
+	}
+
+	// Заказ нужен, чтобы найти его записи индексов; декодируем до изменения бакетов
+	order, err := c.opts.codec.Decode(value[8:])
+	if err != nil {
+		return false, err
+	}
+	exp := int64(binary.BigEndian.Uint64(value))
+
+	if err := tx.Bucket(boltExpiryBucket).Delete(boltExpiryKey(exp, uid)); err != nil {
+		return false, err
+	}
+	index := tx.Bucket(boltIndexBucket)
+	for _, field := range indexFields {
+		if fieldValue := field.value(order); fieldValue != "" {
+			if err := index.Delete(boltIndexKey(field, fieldValue, orderUID)); err != nil {
+				return false, err
+			}
+		}
+	}
+	return true, orders.Delete(uid)
+}
+
+// lookup читает неистёкший заказ; false — заказа нет или его TTL истёк
+func (c *BoltCache) lookup(tx *bolt.Tx, orderUID string, now time.Time) (models.Order, bool, error) {
+	value := tx.Bucket(boltOrdersBucket).Get([]byte(orderUID))
+	if !boltAlive(value, now) {
+		return models.Order{}, false, nil
+	}
+	order, err := c.opts.codec.Decode(value[8:])
+	if err != nil {
+		return models.Order{}, false, err
+	}
+	return order, true, nil
+}
+
+// GetOrder получает заказ по UID
+func (c *BoltCache) GetOrder(ctx context.Context, orderUID string) (models.Order, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Order{}, false, err
+	}
+
+	var (
+		order models.Order
+		found bool
+	)
+	err := c.db.View(func(tx *bolt.Tx) error {
+		var err error
+		order, found, err = c.lookup(tx, orderUID, time.Now())
+		return err
+	})
+	if err != nil {
+		return models.Order{}, false, fmt.Errorf("failed to get order from bolt cache: %w", err)
+	}
+
+	if found {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+	return order, found, nil
+}
+
+// GetOrders читает заказы одной транзакцией
+func (c *BoltCache) GetOrders(ctx context.Context, orderUIDs []string) ([]models.Order, []string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	found := make([]models.Order, 0, len(orderUIDs))
+	var missing []string
+	err := c.db.View(func(tx *bolt.Tx) error {
+		now := time.Now()
+		for _, uid := range orderUIDs {
+			order, ok, err := c.lookup(tx, uid, now)
+			if err != nil {
+				return err
+			}
+			if ok {
+				found = append(found, order)
+			} else {
+				missing = append(missing, uid)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get orders from bolt cache: %w", err)
+	}
+
+	c.hits.Add(uint64(len(found)))
+	c.misses.Add(uint64(len(missing)))
+	return found, missing, nil
+}
+
+// OrderExists проверяет наличие неистёкшего заказа, не декодируя его
+func (c *BoltCache) OrderExists(ctx context.Context, orderUID string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	var exists bool
+	err := c.db.View(func(tx *bolt.Tx) error {
+		exists = boltAlive(tx.Bucket(boltOrdersBucket).Get([]byte(orderUID)), time.Now())
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to check order existence: %w", err)
+	}
+	return exists, nil
+}
+
+// RemoveOrder удаляет заказ по UID
+func (c *BoltCache) RemoveOrder(ctx context.Context, orderUID string) error {
+	return c.RemoveOrders(ctx, []string{orderUID})
+}
+
+// RemoveOrders удаляет заказы одной транзакцией
+func (c *BoltCache) RemoveOrders(ctx context.Context, orderUIDs []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(orderUIDs) == 0 {
+		return nil
+	}
+
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		for _, uid := range orderUIDs {
+			if _, err := c.delete(tx, uid); err != nil {
+				return err
+			}
+		}
+		return touchUpdatedAt(tx, time.Now())
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove orders from bolt cache: %w", err)
+	}
+	return nil
+}
+
+// Clear удаляет все заказы, сроки жизни и индексы
+func (c *BoltCache) Clear(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltOrdersBucket, boltExpiryBucket, boltIndexBucket} {
+			if err := tx.DeleteBucket(name); err != nil {
+				return err
+			}
+			if _, err := tx.CreateBucket(name); err != nil {
+				return err
+			}
+		}
+		return touchUpdatedAt(tx, time.Now())
+	})
+	if err != nil {
+		return fmt.Errorf("failed to clear bolt cache: %w", err)
+	}
+	return nil
+}
+
+// FindOrders ищет заказы по вторичному индексу префиксным обходом бакета индексов
+func (c *BoltCache) FindOrders(ctx context.Context, field IndexField, value string) ([]models.Order, error) {
+	if err := field.Validate(); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	orders := make([]models.Order, 0)
+	err := c.db.View(func(tx *bolt.Tx) error {
+		now := time.Now()
+		prefix := boltIndexKey(field, value, "")
+		cur := tx.Bucket(boltIndexBucket).Cursor()
+		for k, _ := cur.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = cur.Next() {
+			order, ok, err := c.lookup(tx, string(k[len(prefix):]), now)
+			if err != nil {
+				return err
+			}
+			if ok {
+				orders = append(orders, order)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find orders in bolt cache: %w", err)
+	}
+
+	sortIndexed(orders)
+	return orders, nil
+}
+
+// GetAllOrders возвращает все неистёкшие заказы
+func (c *BoltCache) GetAllOrders(ctx context.Context) ([]models.Order, error) {
+	var orders []models.Order
+	cursor := ""
+	for {
+		page, next, err := c.ListOrders(ctx, cursor, allOrdersPageSize)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, page...)
+		if next == "" {
+			return orders, nil
+		}
+		cursor = next
+	}
+}
+
+// ListOrders возвращает страницу заказов в порядке UID. Курсор — последний UID страницы в hex,
+// поэтому записи, добавленные или удалённые между страницами, не сдвигают обход.
+func (c *BoltCache) ListOrders(ctx context.Context, cursor string, limit int) ([]models.Order, string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+	if limit <= 0 {
+		return nil, "", fmt.Errorf("limit must be > 0")
+	}
+	after, err := hex.DecodeString(cursor)
+	if err != nil {
+		return nil, "", ErrInvalidCursor
+	}
+
+	orders := make([]models.Order, 0, limit)
+	var next string
+	err = c.db.View(func(tx *bolt.Tx) error {
+		now := time.Now()
+		cur := tx.Bucket(boltOrdersBucket).Cursor()
+
+		k, v := cur.First()
+		if len(after) > 0 {
+			if k, v = cur.Seek(after); bytes.Equal(k, after) {
+				k, v = cur.Next()
+			}
+		}
+		for ; k != nil; k, v = cur.Next() {
+			if len(orders) == limit {
+				next = hex.EncodeToString([]byte(orders[limit-1].OrderUID))
+				return nil
+			}
+			if !boltAlive(v, now) {
+				continue
+			}
+			order, err := c.opts.codec.Decode(v[8:])
+			if err != nil {
+				return err
+			}
+			orders = append(orders, order)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list orders from bolt cache: %w", err)
+	}
+	return orders, next, nil
+}
+
+// Stats возвращает статистику кэша. Размер — количество записей, включая истёкшие
+// и ещё не удалённые компакцией; MemoryBytes — размер файла хранилища.
+func (c *BoltCache) Stats(ctx context.Context) (Stats, error) {
+	if err := ctx.Err(); err != nil {
+		return Stats{}, err
+	}
+
+	stats := Stats{
+		Backend:     string(CacheTypeBolt),
+		Hits:        c.hits.Load(),
+		Misses:      c.misses.Load(),
+		Expirations: c.expirations.Load(),
+	}
+	err := c.db.View(func(tx *bolt.Tx) error {
+		stats.Size = int64(tx.Bucket(boltOrdersBucket).Stats().KeyN)
+		stats.MemoryBytes = tx.Size()
+		return nil
+	})
+	if err != nil {
+		return Stats{}, fmt.Errorf("failed to get bolt cache stats: %w", err)
+	}
+	return stats, nil
+}
+
+// UpdatedAt возвращает время последней записи в хранилище (нулевое, если записей не было).
+// После перезапуска по нему дочитываются заказы, изменённые в БД, пока сервис не работал.
+func (c *BoltCache) UpdatedAt() (time.Time, error) {
+	var updatedAt time.Time
+	err := c.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(boltMetaBucket).Get(boltUpdatedAtKey); len(v) == 8 {
+			updatedAt = time.Unix(0, int64(binary.BigEndian.Uint64(v)))
+		}
+		return nil
+	})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read bolt cache metadata: %w", err)
+	}
+	return updatedAt, nil
+}
+
+// compactLoop периодически удаляет истёкшие записи до закрытия кэша
+func (c *BoltCache) compactLoop(interval time.Duration) {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// Ошибка компакции не критична: истёкшие записи не отдаются и будут удалены в следующий раз
+			_, _ = c.compact(time.Now())
+		case <-c.stopCh:
+			return
+		}
+	}
+}
+
+// compact удаляет записи, истёкшие к now, пачками по boltCompactBatch и возвращает их количество
+func (c *BoltCache) compact(now time.Time) (int, error) {
+	total := 0
+	for {
+		removed := 0
+		err := c.db.Update(func(tx *bolt.Tx) error {
+			var uids []string
+			cur := tx.Bucket(boltExpiryBucket).Cursor()
+			for k, _ := cur.First(); k != nil && len(uids) < boltCompactBatch; k, _ = cur.Next() {
+				if int64(binary.BigEndian.Uint64(k)) > now.UnixNano() {
+					break
+				}
+				uids = append(uids, string(k[8:]))
+			}
+
+			for _, uid := range uids {
+				ok, err := c.delete(tx, uid)
+				if err != nil {
+					return err
+				}
+				if ok {
+					removed++
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return total, fmt.Errorf("failed to compact bolt cache: %w", err)
+		}
+
+		total += removed
+		c.expirations.Add(uint64(removed))
+		if removed < boltCompactBatch {
+			return total, nil
+		}
+	}
+}
+
+// Close останавливает компакцию и закрывает файл хранилища; содержимое сохраняется
+func (c *BoltCache) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.stopCh)
+		c.wg.Wait()
+		err = c.db.Close()
+	})
+	return err
+}
+
+// boltAlive проверяет, что запись есть и её TTL не истёк
+func boltAlive(value []byte, now time.Time) bool {
+	return len(value) >= 8 && int64(binary.BigEndian.Uint64(value)) > now.UnixNano()
+}
+
+// boltExpiryKey формирует ключ бакета сроков: срок жизни упорядочивает ключи, uid делает их уникальными
+func boltExpiryKey(exp int64, uid []byte) []byte {
+	key := make([]byte, 8+len(uid))
+	binary.BigEndian.PutUint64(key, uint64(exp))
+	copy(key[8:], uid)
+	return key
+}
+
+// boltIndexKey формирует ключ бакета индексов; с пустым uid — префикс для поиска по значению
+func boltIndexKey(field IndexField, value, uid string) []byte {
+	return []byte(string(field) + "\x00" + value + "\x00" + uid)
+}
+
+// touchUpdatedAt запоминает время последней записи
+func touchUpdatedAt(tx *bolt.Tx, now time.Time) error {
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint64(v, uint64(now.UnixNano()))
+	return tx.Bucket(boltMetaBucket).Put(boltUpdatedAtKey, v)
+}
+
+// Проверка на соответствие интерфейсам Cache и Indexer.
+var (
+	_ Cache   = (*BoltCache)(nil)
+	_ Indexer = (*BoltCache)(nil)
+)
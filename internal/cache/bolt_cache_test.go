@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/datagenerators"
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupTestBoltCache создаёт кэш во временном файле, который удаляется после теста
+func setupTestBoltCache(t *testing.T, opts ...Option) *BoltCache {
+	cache, err := NewBoltCache(filepath.Join(t.TempDir(), "cache.db"), opts...)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		cache.Close()
+	})
+
+	return cache
+}
+
+func TestBoltCache_SurvivesReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cache.db")
+
+	cache, err := NewBoltCache(path)
+	require.NoError(t, err)
+
+	order := datagenerators.GenerateOrder()
+	removed := datagenerators.GenerateOrder()
+	require.NoError(t, cache.SaveOrders(ctx, []models.Order{order, removed}))
+	require.NoError(t, cache.RemoveOrder(ctx, removed.OrderUID))
+	require.NoError(t, cache.Close())
+
+	reopened, err := NewBoltCache(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { reopened.Close() })
+
+	got, exists, err := reopened.GetOrder(ctx, order.OrderUID)
+	require.NoError(t, err)
+	require.True(t, exists)
+	assert.Equal(t, order.Items, got.Items)
+
+	exists, err = reopened.OrderExists(ctx, removed.OrderUID)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	// Индексы тоже хранятся на диске
+	found, err := reopened.FindOrders(ctx, IndexTrackNumber, order.TrackNumber)
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, order.OrderUID, found[0].OrderUID)
+
+	updatedAt, err := reopened.UpdatedAt()
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), updatedAt, time.Minute)
+}
+
+func TestBoltCache_TTLAndCompaction(t *testing.T) {
+	ctx := context.Background()
+	cache := setupTestBoltCache(t, WithTTL(100*time.Millisecond))
+
+	order := datagenerators.GenerateOrder()
+	require.NoError(t, cache.SaveOrder(ctx, order))
+
+	exists, err := cache.OrderExists(ctx, order.OrderUID)
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	time.Sleep(150 * time.Millisecond)
+
+	// Истёкшая запись не отдаётся, но остаётся в файле до компакции
+	_, exists, err = cache.GetOrder(ctx, order.OrderUID)
+	require.NoError(t, err)
+	assert.False(t, exists)
+	found, err := cache.FindOrders(ctx, IndexCustomerID, order.CustomerId)
+	require.NoError(t, err)
+	assert.Empty(t, found)
+
+	stats, err := cache.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stats.Size)
+
+	removed, err := cache.compact(time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	stats, err = cache.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, string(CacheTypeBolt), stats.Backend)
+	assert.Equal(t, int64(0), stats.Size)
+	assert.Equal(t, uint64(1), stats.Expirations)
+	assert.Equal(t, uint64(1), stats.Misses)
+	assert.Positive(t, stats.MemoryBytes)
+}
+
+func TestBoltCache_CompactionKeepsLiveEntries(t *testing.T) {
+	ctx := context.Background()
+	policy := func(order models.Order, ttl time.Duration) time.Duration {
+		if order.TrackNumber == "SHORT" {
+			return 50 * time.Millisecond
+		}
+		return ttl
+	}
+	cache := setupTestBoltCache(t, WithTTLPolicy(policy))
+
+	short := datagenerators.GenerateOrder()
+	short.TrackNumber = "SHORT"
+	long := datagenerators.GenerateOrder()
+	require.NoError(t, cache.SaveOrders(ctx, []models.Order{short, long}))
+
+	// Перезапись продлевает срок: старый ключ срока жизни не должен удалить заказ
+	long.TrackNumber = "UPDATED"
+	require.NoError(t, cache.SaveOrder(ctx, long))
+
+	removed, err := cache.compact(time.Now().Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	exists, err := cache.OrderExists(ctx, long.OrderUID)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = cache.OrderExists(ctx, short.OrderUID)
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
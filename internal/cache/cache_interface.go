@@ -1,14 +1,55 @@
 package cache
 
-import "github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/models"
+import (
+	"context"
+	"errors"
 
-// Cache определяет контракт для кэша
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/models"
+)
+
+// Cache определяет контракт для кэша.
+// Все операции, кроме Close, принимают context.Context: отмена и дедлайн
+// контекста прерывают обращение к хранилищу.
 type Cache interface {
-	SaveOrder(order models.Order) error
-	GetOrder(orderUID string) (models.Order, bool, error)
-	OrderExists(orderUID string) (bool, error)
-	RemoveOrder(orderUID string) error
-	Clear() error
-	GetAllOrders() ([]models.Order, error)
+	SaveOrder(ctx context.Context, order models.Order) error
+	GetOrder(ctx context.Context, orderUID string) (models.Order, bool, error)
+	OrderExists(ctx context.Context, orderUID string) (bool, error)
+	RemoveOrder(ctx context.Context, orderUID string) error
+	// SaveOrders, GetOrders и RemoveOrders — пакетные варианты операций с одним заказом,
+	// выполняемые за одно обращение к хранилищу (одна блокировка, один пайплайн).
+	SaveOrders(ctx context.Context, orders []models.Order) error
+	// GetOrders возвращает найденные заказы в порядке orderUIDs и UID, которых нет в кэше.
+	GetOrders(ctx context.Context, orderUIDs []string) (found []models.Order, missing []string, err error)
+	RemoveOrders(ctx context.Context, orderUIDs []string) error
+	Clear(ctx context.Context) error
+	GetAllOrders(ctx context.Context) ([]models.Order, error)
+	// ListOrders возвращает страницу заказов и курсор следующей страницы.
+	// Пустой cursor означает начало обхода, пустой следующий курсор — его конец.
+	ListOrders(ctx context.Context, cursor string, limit int) ([]models.Order, string, error)
+	// Stats возвращает статистику кэша: попадания, промахи, вытеснения, размер и т.д.
+	Stats(ctx context.Context) (Stats, error)
 	Close() error
 }
+
+// StaleReader — кэш с мягким TTL: после него заказ ещё отдаётся, но помечается устаревшим
+// (stale), чтобы вызывающий обновил его из первичного хранилища. После жёсткого TTL — промах.
+type StaleReader interface {
+	GetOrderStale(ctx context.Context, orderUID string) (order models.Order, found, stale bool, err error)
+}
+
+// Filler — кэш, в котором заполнение данными из первичного хранилища отличается от сохранения
+// изменённого заказа: FillOrders кладёт прочитанные из БД заказы, не оповещая остальные реплики.
+type Filler interface {
+	FillOrders(ctx context.Context, orders []models.Order) error
+}
+
+// FillOrders сохраняет прочитанные из БД заказы через Filler, а если кэш его не реализует — через SaveOrders
+func FillOrders(ctx context.Context, c Cache, orders []models.Order) error {
+	if filler, ok := c.(Filler); ok {
+		return filler.FillOrders(ctx, orders)
+	}
+	return c.SaveOrders(ctx, orders)
+}
+
+// ErrInvalidCursor возвращается ListOrders, если курсор не удалось разобрать
+var ErrInvalidCursor = errors.New("invalid cursor")
@@ -0,0 +1,357 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/datagenerators"
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// batchTestBackends возвращает реализации Cache для общих тестов поведения и пакетных операций.
+// Бэкенды на Redis создаются только при доступном Redis.
+func batchTestBackends() map[string]func(t *testing.T) Cache {
+	return map[string]func(t *testing.T) Cache{
+		"inmemory": func(t *testing.T) Cache {
+			c := NewInMemoryCache(100, time.Minute)
+			t.Cleanup(func() { c.Close() })
+			return c
+		},
+		"sharded": func(t *testing.T) Cache {
+			c, err := NewShardedInMemoryCache(4, 100, time.Minute)
+			require.NoError(t, err)
+			t.Cleanup(func() { c.Close() })
+			return c
+		},
+		"mock": func(t *testing.T) Cache {
+			return NewMock()
+		},
+		"redis": func(t *testing.T) Cache {
+			return setupTestRedisCache(t)
+		},
+		"tiered": func(t *testing.T) Cache {
+			c := setupTestTieredCache(t)
+			require.NoError(t, c.Clear(context.Background()))
+			return c
+		},
+		"bolt": func(t *testing.T) Cache {
+			return setupTestBoltCache(t)
+		},
+		"readthrough": func(t *testing.T) Cache {
+			c := NewReadThroughCache(NewInMemoryCache(100, time.Minute), newStubLoader(), time.Minute)
+			t.Cleanup(func() { c.Close() })
+			return c
+		},
+	}
+}
+
+// TestCache_Behaviour — общий для всех бэкендов контракт Cache
+func TestCache_Behaviour(t *testing.T) {
+	for name, newCache := range batchTestBackends() {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			t.Run("save and get", func(t *testing.T) {
+				cache := newCache(t)
+				order := datagenerators.GenerateOrder()
+				require.NoError(t, cache.SaveOrder(ctx, order))
+
+				got, exists, err := cache.GetOrder(ctx, order.OrderUID)
+				require.NoError(t, err)
+				require.True(t, exists)
+				assert.Equal(t, order.OrderUID, got.OrderUID)
+				assert.Equal(t, order.TrackNumber, got.TrackNumber)
+				assert.Equal(t, order.Delivery, got.Delivery)
+				assert.Equal(t, order.Payment.AmountTotal, got.Payment.AmountTotal)
+				assert.Equal(t, order.Items, got.Items)
+
+				_, exists, err = cache.GetOrder(ctx, "non-existent")
+				require.NoError(t, err)
+				assert.False(t, exists)
+			})
+
+			t.Run("exists and remove", func(t *testing.T) {
+				cache := newCache(t)
+				order := datagenerators.GenerateOrder()
+
+				exists, err := cache.OrderExists(ctx, order.OrderUID)
+				require.NoError(t, err)
+				assert.False(t, exists)
+
+				require.NoError(t, cache.SaveOrder(ctx, order))
+				exists, err = cache.OrderExists(ctx, order.OrderUID)
+				require.NoError(t, err)
+				assert.True(t, exists)
+
+				require.NoError(t, cache.RemoveOrder(ctx, order.OrderUID))
+				exists, err = cache.OrderExists(ctx, order.OrderUID)
+				require.NoError(t, err)
+				assert.False(t, exists)
+
+				// Удаление отсутствующего заказа — не ошибка
+				require.NoError(t, cache.RemoveOrder(ctx, order.OrderUID))
+			})
+
+			t.Run("get all and clear", func(t *testing.T) {
+				cache := newCache(t)
+				saved := make(map[string]bool)
+				for i := 0; i < 3; i++ {
+					order := datagenerators.GenerateOrder()
+					require.NoError(t, cache.SaveOrder(ctx, order))
+					saved[order.OrderUID] = true
+				}
+
+				orders, err := cache.GetAllOrders(ctx)
+				require.NoError(t, err)
+				listed := make(map[string]bool)
+				for _, o := range orders {
+					listed[o.OrderUID] = true
+				}
+				assert.Equal(t, saved, listed)
+
+				require.NoError(t, cache.Clear(ctx))
+				orders, err = cache.GetAllOrders(ctx)
+				require.NoError(t, err)
+				assert.Empty(t, orders)
+			})
+
+			t.Run("list orders", func(t *testing.T) {
+				cache := newCache(t)
+				saved := make(map[string]bool)
+				for i := 0; i < 7; i++ {
+					order := datagenerators.GenerateOrder()
+					require.NoError(t, cache.SaveOrder(ctx, order))
+					saved[order.OrderUID] = true
+				}
+
+				listed := make(map[string]bool)
+				cursor := ""
+				for {
+					orders, next, err := cache.ListOrders(ctx, cursor, 3)
+					require.NoError(t, err)
+					for _, o := range orders {
+						listed[o.OrderUID] = true
+					}
+					if next == "" {
+						break
+					}
+					cursor = next
+				}
+				assert.Equal(t, saved, listed)
+
+				_, _, err := cache.ListOrders(ctx, "not-a-number", 3)
+				assert.ErrorIs(t, err, ErrInvalidCursor)
+			})
+
+			t.Run("canceled context", func(t *testing.T) {
+				cache := newCache(t)
+				order := datagenerators.GenerateOrder()
+				require.NoError(t, cache.SaveOrder(ctx, order))
+
+				canceled, cancel := context.WithCancel(ctx)
+				cancel()
+
+				assert.ErrorIs(t, cache.SaveOrder(canceled, datagenerators.GenerateOrder()), context.Canceled)
+				_, _, err := cache.GetOrder(canceled, order.OrderUID)
+				assert.ErrorIs(t, err, context.Canceled)
+
+				_, exists, err := cache.GetOrder(ctx, order.OrderUID)
+				require.NoError(t, err)
+				assert.True(t, exists)
+			})
+		})
+	}
+}
+
+func TestCache_BatchOperations(t *testing.T) {
+	ctx := context.Background()
+
+	for name, newCache := range batchTestBackends() {
+		t.Run(name, func(t *testing.T) {
+			cache := newCache(t)
+
+			orders := make([]models.Order, 5)
+			uids := make([]string, len(orders))
+			for i := range orders {
+				orders[i] = datagenerators.GenerateOrder()
+				uids[i] = orders[i].OrderUID
+			}
+			require.NoError(t, cache.SaveOrders(ctx, orders))
+
+			// Найденные заказы возвращаются в порядке запроса, отсутствующие — отдельно
+			request := []string{uids[3], "missing-1", uids[0], uids[4], "missing-2"}
+			found, missing, err := cache.GetOrders(ctx, request)
+			require.NoError(t, err)
+			require.Len(t, found, 3)
+			assert.Equal(t, uids[3], found[0].OrderUID)
+			assert.Equal(t, uids[0], found[1].OrderUID)
+			assert.Equal(t, uids[4], found[2].OrderUID)
+			assert.Equal(t, orders[3].Items, found[0].Items)
+			assert.Equal(t, []string{"missing-1", "missing-2"}, missing)
+
+			require.NoError(t, cache.RemoveOrders(ctx, uids[:2]))
+			found, missing, err = cache.GetOrders(ctx, uids)
+			require.NoError(t, err)
+			assert.Len(t, found, 3)
+			assert.Equal(t, uids[:2], missing)
+
+			found, missing, err = cache.GetOrders(ctx, nil)
+			require.NoError(t, err)
+			assert.Empty(t, found)
+			assert.Empty(t, missing)
+
+			// Пустые пакеты допустимы
+			require.NoError(t, cache.SaveOrders(ctx, nil))
+			require.NoError(t, cache.RemoveOrders(ctx, nil))
+		})
+	}
+}
+
+func TestInMemoryCache_BatchRespectsCapacity(t *testing.T) {
+	ctx := context.Background()
+	cache := NewInMemoryCache(3, time.Minute)
+	t.Cleanup(func() { cache.Close() })
+
+	orders := make([]models.Order, 5)
+	for i := range orders {
+		orders[i] = datagenerators.GenerateOrder()
+	}
+	require.NoError(t, cache.SaveOrders(ctx, orders))
+
+	// Вытеснены два первых заказа пакета
+	found, missing, err := cache.GetOrders(ctx, []string{orders[0].OrderUID, orders[1].OrderUID, orders[4].OrderUID})
+	require.NoError(t, err)
+	assert.Len(t, found, 1)
+	assert.Equal(t, []string{orders[0].OrderUID, orders[1].OrderUID}, missing)
+
+	stats, err := cache.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), stats.Evictions)
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, uint64(2), stats.Misses)
+}
+
+func TestReadThroughCache_GetOrdersLoadsMissing(t *testing.T) {
+	ctx := context.Background()
+	cached := datagenerators.GenerateOrder()
+	inDB := datagenerators.GenerateOrder()
+	loader := newStubLoader(inDB)
+	inner := NewInMemoryCache(100, time.Minute)
+	cache := NewReadThroughCache(inner, loader, time.Minute)
+	defer cache.Close()
+	require.NoError(t, cache.SaveOrder(ctx, cached))
+
+	found, missing, err := cache.GetOrders(ctx, []string{"absent", inDB.OrderUID, cached.OrderUID})
+	require.NoError(t, err)
+	require.Len(t, found, 2)
+	assert.Equal(t, inDB.OrderUID, found[0].OrderUID)
+	assert.Equal(t, cached.OrderUID, found[1].OrderUID)
+	assert.Equal(t, []string{"absent"}, missing)
+	assert.Equal(t, int32(2), loader.calls.Load())
+
+	// Загруженный заказ попал в кэш, отсутствующий запомнен
+	exists, err := inner.OrderExists(ctx, inDB.OrderUID)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	_, missing, err = cache.GetOrders(ctx, []string{"absent"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"absent"}, missing)
+	assert.Equal(t, int32(2), loader.calls.Load())
+}
+
+func TestCache_SecondaryIndexes(t *testing.T) {
+	ctx := context.Background()
+
+	for name, newCache := range batchTestBackends() {
+		t.Run(name, func(t *testing.T) {
+			cache := newCache(t)
+			indexer, ok := cache.(Indexer)
+			require.True(t, ok)
+
+			now := time.Now().UTC().Truncate(time.Second)
+			orders := make([]models.Order, 3)
+			for i := range orders {
+				orders[i] = datagenerators.GenerateOrder()
+				orders[i].CustomerId = "customer-" + name
+				orders[i].DateCreated = now.Add(time.Duration(i) * time.Minute)
+			}
+			orders[0].TrackNumber = "TRACK-" + name
+			orders[1].TrackNumber = "TRACK-" + name
+			require.NoError(t, cache.SaveOrders(ctx, orders))
+
+			// Заказы клиента идут от новых к старым
+			found, err := indexer.FindOrders(ctx, IndexCustomerID, "customer-"+name)
+			require.NoError(t, err)
+			require.Len(t, found, 3)
+			assert.Equal(t, orders[2].OrderUID, found[0].OrderUID)
+			assert.Equal(t, orders[0].OrderUID, found[2].OrderUID)
+
+			found, err = indexer.FindOrders(ctx, IndexTrackNumber, "TRACK-"+name)
+			require.NoError(t, err)
+			assert.Len(t, found, 2)
+
+			// Смена трек-номера и удаление заказа убирают его из результатов
+			orders[0].TrackNumber = "OTHER-" + name
+			require.NoError(t, cache.SaveOrder(ctx, orders[0]))
+			require.NoError(t, cache.RemoveOrder(ctx, orders[2].OrderUID))
+
+			found, err = indexer.FindOrders(ctx, IndexTrackNumber, "TRACK-"+name)
+			require.NoError(t, err)
+			require.Len(t, found, 1)
+			assert.Equal(t, orders[1].OrderUID, found[0].OrderUID)
+
+			found, err = indexer.FindOrders(ctx, IndexTrackNumber, "OTHER-"+name)
+			require.NoError(t, err)
+			require.Len(t, found, 1)
+			assert.Equal(t, orders[0].OrderUID, found[0].OrderUID)
+
+			found, err = indexer.FindOrders(ctx, IndexCustomerID, "customer-"+name)
+			require.NoError(t, err)
+			assert.Len(t, found, 2)
+
+			require.NoError(t, cache.Clear(ctx))
+			found, err = indexer.FindOrders(ctx, IndexCustomerID, "customer-"+name)
+			require.NoError(t, err)
+			assert.Empty(t, found)
+
+			_, err = indexer.FindOrders(ctx, "email", "x")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestInMemoryCache_IndexFollowsEvictionAndExpiry(t *testing.T) {
+	ctx := context.Background()
+	cache := NewInMemoryCache(2, time.Minute)
+	t.Cleanup(func() { cache.Close() })
+
+	orders := make([]models.Order, 3)
+	for i := range orders {
+		orders[i] = datagenerators.GenerateOrder()
+		orders[i].CustomerId = "customer"
+	}
+	require.NoError(t, cache.SaveOrders(ctx, orders))
+
+	// Вытесненный заказ удалён из индекса
+	found, err := cache.FindOrders(ctx, IndexCustomerID, "customer")
+	require.NoError(t, err)
+	assert.Len(t, found, 2)
+
+	short := NewInMemoryCache(10, 50*time.Millisecond)
+	t.Cleanup(func() { short.Close() })
+	require.NoError(t, short.SaveOrder(ctx, orders[0]))
+
+	// Просроченный заказ не находится, а после фоновой очистки индекс пустеет
+	require.Eventually(t, func() bool {
+		short.mu.RLock()
+		defer short.mu.RUnlock()
+		return len(short.index[IndexCustomerID]) == 0 && len(short.index[IndexTrackNumber]) == 0
+	}, time.Second, 10*time.Millisecond)
+	found, err = short.FindOrders(ctx, IndexCustomerID, "customer")
+	require.NoError(t, err)
+	assert.Empty(t, found)
+}
@@ -0,0 +1,216 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/models"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// CodecFormat формат сериализации заказа
+type CodecFormat string
+
+const (
+	CodecJSON    CodecFormat = "json"
+	CodecMsgpack CodecFormat = "msgpack"
+)
+
+// Compression алгоритм сжатия сериализованного заказа
+type Compression string
+
+const (
+	CompressionNone   Compression = "none"
+	CompressionSnappy Compression = "snappy"
+	CompressionZstd   Compression = "zstd"
+)
+
+// Заголовок версионированной записи: [codecVersion][формат][сжатие][данные...].
+// Записи без заголовка (начинаются с '{') — JSON в формате до появления кодеков.
+const (
+	codecVersion    byte = 1
+	codecHeaderSize      = 3
+)
+
+// Идентификаторы формата и сжатия в заголовке
+const (
+	formatJSON    byte = 0
+	formatMsgpack byte = 1
+
+	compressionNone   byte = 0
+	compressionSnappy byte = 1
+	compressionZstd   byte = 2
+)
+
+// defaultCompressThreshold — размер, начиная с которого данные сжимаются, если порог не задан
+const defaultCompressThreshold = 512
+
+// Codec сериализует заказы для хранения в кэше.
+// Decode читает любые записи, созданные любым Codec (и JSON без заголовка),
+// поэтому смена кодека не делает старые записи нечитаемыми.
+type Codec interface {
+	Encode(order models.Order) ([]byte, error)
+	Decode(data []byte) (models.Order, error)
+}
+
+// orderCodec — реализация Codec с выбором формата и сжатия
+type orderCodec struct {
+	format      byte
+	compression byte
+	threshold   int
+	legacy      bool // писать JSON без заголовка, как до появления кодеков
+}
+
+// zstd-кодировщик и декодировщик потокобезопасны для EncodeAll/DecodeAll
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// NewCodec создаёт кодек.
+// threshold — минимальный размер сериализованного заказа, при котором применяется сжатие
+// (0 — значение по умолчанию). JSON без сжатия пишется без заголовка, чтобы
+// узлы предыдущей версии могли читать записи во время выкатки.
+func NewCodec(format CodecFormat, compression Compression, threshold int) (Codec, error) {
+	c := &orderCodec{threshold: threshold}
+	if c.threshold <= 0 {
+		c.threshold = defaultCompressThreshold
+	}
+
+	switch format {
+	case CodecJSON, "":
+		c.format = formatJSON
+	case CodecMsgpack:
+		c.format = formatMsgpack
+	default:
+		return nil, fmt.Errorf("unknown cache codec: %s", format)
+	}
+
+	switch compression {
+	case CompressionNone, "":
+		c.compression = compressionNone
+	case CompressionSnappy:
+		c.compression = compressionSnappy
+	case CompressionZstd:
+		c.compression = compressionZstd
+	default:
+		return nil, fmt.Errorf("unknown cache compression: %s", compression)
+	}
+
+	c.legacy = c.format == formatJSON && c.compression == compressionNone
+	return c, nil
+}
+
+// DefaultCodec возвращает кодек, совместимый с записями до появления кодеков (JSON без заголовка)
+func DefaultCodec() Codec {
+	return &orderCodec{format: formatJSON, compression: compressionNone, threshold: defaultCompressThreshold, legacy: true}
+}
+
+// Encode сериализует заказ и при превышении порога сжимает его
+func (c *orderCodec) Encode(order models.Order) ([]byte, error) {
+	var payload []byte
+	var err error
+
+	switch c.format {
+	case formatMsgpack:
+		payload, err = marshalMsgpack(order)
+	default:
+		payload, err = json.Marshal(order)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal order: %w", err)
+	}
+
+	if c.legacy {
+		return payload, nil
+	}
+
+	compression := compressionNone
+	if c.compression != compressionNone && len(payload) >= c.threshold {
+		compression = c.compression
+	}
+
+	data := make([]byte, codecHeaderSize, codecHeaderSize+len(payload))
+	data[0], data[1], data[2] = codecVersion, c.format, compression
+
+	switch compression {
+	case compressionSnappy:
+		return append(data, snappy.Encode(nil, payload)...), nil
+	case compressionZstd:
+		return zstdEncoder.EncodeAll(payload, data), nil
+	default:
+		return append(data, payload...), nil
+	}
+}
+
+// Decode восстанавливает заказ из записи любого поддерживаемого формата
+func (c *orderCodec) Decode(data []byte) (models.Order, error) {
+	var order models.Order
+
+	if len(data) == 0 {
+		return order, fmt.Errorf("failed to unmarshal order: empty data")
+	}
+
+	// Запись в формате до появления кодеков
+	if data[0] == '{' {
+		if err := json.Unmarshal(data, &order); err != nil {
+			return order, fmt.Errorf("failed to unmarshal order: %w", err)
+		}
+		return order, nil
+	}
+
+	if len(data) < codecHeaderSize || data[0] != codecVersion {
+		return order, fmt.Errorf("failed to unmarshal order: unsupported codec version %d", data[0])
+	}
+
+	format, compression, payload := data[1], data[2], data[codecHeaderSize:]
+
+	var err error
+	switch compression {
+	case compressionNone:
+	case compressionSnappy:
+		payload, err = snappy.Decode(nil, payload)
+	case compressionZstd:
+		payload, err = zstdDecoder.DecodeAll(payload, nil)
+	default:
+		err = fmt.Errorf("unknown compression %d", compression)
+	}
+	if err != nil {
+		return order, fmt.Errorf("failed to decompress order: %w", err)
+	}
+
+	switch format {
+	case formatJSON:
+		err = json.Unmarshal(payload, &order)
+	case formatMsgpack:
+		err = unmarshalMsgpack(payload, &order)
+	default:
+		err = fmt.Errorf("unknown format %d", format)
+	}
+	if err != nil {
+		return order, fmt.Errorf("failed to unmarshal order: %w", err)
+	}
+	return order, nil
+}
+
+// marshalMsgpack сериализует заказ в msgpack, используя json-теги как имена полей
+// (они короче имён полей Go и совпадают с ключами JSON-представления)
+func marshalMsgpack(order models.Order) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(order); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// unmarshalMsgpack восстанавливает заказ из msgpack (см. marshalMsgpack)
+func unmarshalMsgpack(data []byte, order *models.Order) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(order)
+}
@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/datagenerators"
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// assertSameOrder сравнивает заказы через JSON-представление
+// (msgpack восстанавливает время в локальной зоне, поэтому прямое сравнение time.Time ненадёжно)
+func assertSameOrder(t *testing.T, expected, actual models.Order) {
+	t.Helper()
+	expectedJSON, err := json.Marshal(expected)
+	require.NoError(t, err)
+	actualJSON, err := json.Marshal(actual)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(expectedJSON), string(actualJSON))
+}
+
+func TestCodec_RoundTrip(t *testing.T) {
+	order := datagenerators.GenerateOrder()
+
+	for _, format := range []CodecFormat{CodecJSON, CodecMsgpack} {
+		for _, compression := range []Compression{CompressionNone, CompressionSnappy, CompressionZstd} {
+			// Порог 1 байт: сжатие применяется всегда
+			codec, err := NewCodec(format, compression, 1)
+			require.NoError(t, err)
+
+			data, err := codec.Encode(order)
+			require.NoError(t, err, "%s/%s", format, compression)
+
+			decoded, err := codec.Decode(data)
+			require.NoError(t, err, "%s/%s", format, compression)
+			assertSameOrder(t, order, decoded)
+		}
+	}
+}
+
+func TestCodec_CompressionThreshold(t *testing.T) {
+	order := datagenerators.GenerateOrder()
+
+	codec, err := NewCodec(CodecMsgpack, CompressionZstd, 1<<20)
+	require.NoError(t, err)
+
+	data, err := codec.Encode(order)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{codecVersion, formatMsgpack, compressionNone}, data[:codecHeaderSize],
+		"small records must not be compressed")
+}
+
+func TestCodec_ReadsRecordsOfOtherCodecs(t *testing.T) {
+	order := datagenerators.GenerateOrder()
+
+	// Запись в формате до появления кодеков
+	legacy, err := json.Marshal(order)
+	require.NoError(t, err)
+
+	packed, err := NewCodec(CodecMsgpack, CompressionSnappy, 1)
+	require.NoError(t, err)
+	packedData, err := packed.Encode(order)
+	require.NoError(t, err)
+
+	for _, data := range [][]byte{legacy, packedData} {
+		decoded, err := DefaultCodec().Decode(data)
+		require.NoError(t, err)
+		assertSameOrder(t, order, decoded)
+
+		decoded, err = packed.Decode(data)
+		require.NoError(t, err)
+		assertSameOrder(t, order, decoded)
+	}
+}
+
+func TestCodec_InvalidInput(t *testing.T) {
+	_, err := NewCodec("xml", CompressionNone, 0)
+	assert.Error(t, err)
+	_, err = NewCodec(CodecJSON, "lz4", 0)
+	assert.Error(t, err)
+
+	codec := DefaultCodec()
+	_, err = codec.Decode(nil)
+	assert.Error(t, err)
+	_, err = codec.Decode([]byte{99, formatJSON, compressionNone})
+	assert.Error(t, err)
+}
+
+func TestRedisCache_Codec(t *testing.T) {
+	ctx := context.Background()
+	if !isRedisAvailable() {
+		t.Skip("Redis is not available")
+	}
+
+	codec, err := NewCodec(CodecMsgpack, CompressionZstd, 1)
+	require.NoError(t, err)
+
+	cache, err := NewRedisCache("localhost:6379", "", 6, WithCodec(codec)) // DB 6 — тесты кодеков
+	require.NoError(t, err)
+	require.NoError(t, cache.Clear(ctx))
+	t.Cleanup(func() {
+		cache.Clear(ctx)
+		cache.Close()
+	})
+
+	packed := datagenerators.GenerateOrder()
+	require.NoError(t, cache.SaveOrder(ctx, packed))
+
+	// Запись, оставленная узлом со старой версией
+	legacy := datagenerators.GenerateOrder()
+	legacyJSON, err := json.Marshal(legacy)
+	require.NoError(t, err)
+	require.NoError(t, cache.client.Set(ctx, cache.getOrderKey(legacy.OrderUID), legacyJSON, 0).Err())
+
+	for _, order := range []models.Order{packed, legacy} {
+		retrieved, exists, err := cache.GetOrder(ctx, order.OrderUID)
+		require.NoError(t, err)
+		require.True(t, exists)
+		assertSameOrder(t, order, retrieved)
+	}
+
+	all, err := cache.GetAllOrders(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+}
@@ -0,0 +1,160 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/models"
+	"github.com/go-redis/redis/v8"
+)
+
+// CoherentCache — локальный кэш реплики (обычно InMemoryCache), согласованный с остальными
+// репликами через шину инвалидации. Сохранения, удаления и очистки применяются локально,
+// а остальным репликам рассылаются только UID: они удаляют свою копию и при следующем чтении
+// загружают заказ из БД через read-through. Заполнение из БД (FillOrders) не рассылается.
+type CoherentCache struct {
+	Cache
+	client redis.UniversalClient
+	bus    *InvalidationBus
+}
+
+// NewCoherentCache подключается к Redis и подписывает локальный кэш на шину инвалидации.
+// Канал шины задаётся опцией WithInvalidationChannel.
+func NewCoherentCache(local Cache, cfg RedisConfig, opts ...Option) (*CoherentCache, error) {
+	client, err := newRedisClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	o := applyOptions(options{}, opts)
+	c := &CoherentCache{Cache: local, client: client}
+
+	bus, err := newInvalidationBus(client, o.invalidationChannel, c.applyInvalidation)
+	if err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	c.bus = bus
+	return c, nil
+}
+
+// applyInvalidation применяет к локальному кэшу событие другой реплики
+func (c *CoherentCache) applyInvalidation(ctx context.Context, event invalidationEvent) {
+	switch event.Op {
+	case invalidationSave, invalidationRemove:
+		_ = c.Cache.RemoveOrders(ctx, event.uids())
+	case invalidationClear:
+		_ = c.Cache.Clear(ctx)
+	}
+}
+
+// SaveOrder сохраняет заказ локально и инвалидирует его копии на остальных репликах
+func (c *CoherentCache) SaveOrder(ctx context.Context, order models.Order) error {
+	if err := c.Cache.SaveOrder(ctx, order); err != nil {
+		return err
+	}
+	return c.bus.publish(ctx, invalidationEvent{Op: invalidationSave, OrderUID: order.OrderUID})
+}
+
+// SaveOrders сохраняет заказы локально и инвалидирует их копии на остальных репликах одним событием
+func (c *CoherentCache) SaveOrders(ctx context.Context, orders []models.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+	if err := c.Cache.SaveOrders(ctx, orders); err != nil {
+		return err
+	}
+	return c.bus.publish(ctx, invalidationEvent{Op: invalidationSave, OrderUIDs: orderUIDs(orders)})
+}
+
+// FillOrders сохраняет прочитанные из БД заказы только в локальный кэш: у остальных реплик
+// та же БД, а об изменениях заказов они узнают из SaveOrder и SaveOrders
+func (c *CoherentCache) FillOrders(ctx context.Context, orders []models.Order) error {
+	return c.Cache.SaveOrders(ctx, orders)
+}
+
+// RemoveOrder удаляет заказ локально и на остальных репликах
+func (c *CoherentCache) RemoveOrder(ctx context.Context, orderUID string) error {
+	if err := c.Cache.RemoveOrder(ctx, orderUID); err != nil {
+		return err
+	}
+	return c.bus.publish(ctx, invalidationEvent{Op: invalidationRemove, OrderUID: orderUID})
+}
+
+// RemoveOrders удаляет заказы локально и рассылает одно событие на пакет
+func (c *CoherentCache) RemoveOrders(ctx context.Context, orderUIDs []string) error {
+	if len(orderUIDs) == 0 {
+		return nil
+	}
+	if err := c.Cache.RemoveOrders(ctx, orderUIDs); err != nil {
+		return err
+	}
+	return c.bus.publish(ctx, invalidationEvent{Op: invalidationRemove, OrderUIDs: orderUIDs})
+}
+
+// Clear очищает локальный кэш и кэши остальных реплик
+func (c *CoherentCache) Clear(ctx context.Context) error {
+	if err := c.Cache.Clear(ctx); err != nil {
+		return err
+	}
+	return c.bus.publish(ctx, invalidationEvent{Op: invalidationClear})
+}
+
+// GetOrderStale читает заказ с признаком устаревания, если его поддерживает локальный кэш
+func (c *CoherentCache) GetOrderStale(ctx context.Context, orderUID string) (models.Order, bool, bool, error) {
+	if reader, ok := c.Cache.(StaleReader); ok {
+		return reader.GetOrderStale(ctx, orderUID)
+	}
+	order, found, err := c.Cache.GetOrder(ctx, orderUID)
+	return order, found, false, err
+}
+
+// FindOrders ищет заказы по вторичному индексу локального кэша
+func (c *CoherentCache) FindOrders(ctx context.Context, field IndexField, value string) ([]models.Order, error) {
+	indexer, ok := c.Cache.(Indexer)
+	if !ok {
+		return nil, ErrIndexNotSupported
+	}
+	return indexer.FindOrders(ctx, field, value)
+}
+
+// WriteSnapshot записывает снимок локального кэша
+func (c *CoherentCache) WriteSnapshot(w io.Writer) error {
+	snapshotter, ok := c.Cache.(Snapshotter)
+	if !ok {
+		return fmt.Errorf("cache backend does not support snapshots")
+	}
+	return snapshotter.WriteSnapshot(w)
+}
+
+// ReadSnapshot восстанавливает локальный кэш из снимка; восстановление не рассылается репликам
+func (c *CoherentCache) ReadSnapshot(r io.Reader) (time.Time, error) {
+	snapshotter, ok := c.Cache.(Snapshotter)
+	if !ok {
+		return time.Time{}, fmt.Errorf("cache backend does not support snapshots")
+	}
+	return snapshotter.ReadSnapshot(r)
+}
+
+// Close отписывается от шины и закрывает локальный кэш и соединение с Redis
+func (c *CoherentCache) Close() error {
+	err := c.bus.Close()
+	if cacheErr := c.Cache.Close(); err == nil {
+		err = cacheErr
+	}
+	if clientErr := c.client.Close(); err == nil {
+		err = clientErr
+	}
+	return err
+}
+
+// Проверка на соответствие интерфейсам Cache, Filler, StaleReader, Indexer и Snapshotter.
+var (
+	_ Cache       = (*CoherentCache)(nil)
+	_ Filler      = (*CoherentCache)(nil)
+	_ StaleReader = (*CoherentCache)(nil)
+	_ Indexer     = (*CoherentCache)(nil)
+	_ Snapshotter = (*CoherentCache)(nil)
+)
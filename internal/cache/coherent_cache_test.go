@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/datagenerators"
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testInvalidationChannel — отдельный канал, чтобы события тестов не смешивались с tiered-кэшем
+const testInvalidationChannel = "orders:invalidate:test"
+
+// setupTestCoherentCache создает реплику in-memory кэша, подключенную к шине инвалидации
+func setupTestCoherentCache(t *testing.T) *CoherentCache {
+	if !isRedisAvailable() {
+		t.Skip("Redis is not available")
+	}
+
+	cache, err := NewCoherentCache(NewInMemoryCache(100, time.Minute),
+		RedisConfig{Addr: "localhost:6379"},
+		WithInvalidationChannel(testInvalidationChannel))
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		cache.Close()
+	})
+
+	return cache
+}
+
+func TestCoherentCache_InvalidatesAcrossNodes(t *testing.T) {
+	ctx := context.Background()
+	node1 := setupTestCoherentCache(t)
+	node2 := setupTestCoherentCache(t)
+
+	// Вторая реплика держит прежнюю версию заказа
+	order := datagenerators.GenerateOrder()
+	require.NoError(t, node2.Cache.SaveOrder(ctx, order))
+
+	updated := order
+	updated.TrackNumber = "UPDATED"
+	require.NoError(t, node1.SaveOrder(ctx, updated))
+
+	// Копия второй реплики удаляется, новая версия не копируется
+	require.Eventually(t, func() bool {
+		exists, err := node2.Cache.OrderExists(ctx, order.OrderUID)
+		return err == nil && !exists
+	}, 2*time.Second, 10*time.Millisecond)
+	got, exists, err := node1.Cache.GetOrder(ctx, order.OrderUID)
+	require.NoError(t, err)
+	require.True(t, exists)
+	assert.Equal(t, "UPDATED", got.TrackNumber)
+
+	// Пакетное сохранение инвалидирует все заказы пакета
+	batch := []models.Order{datagenerators.GenerateOrder(), datagenerators.GenerateOrder()}
+	require.NoError(t, node1.Cache.SaveOrders(ctx, batch))
+	require.NoError(t, node2.SaveOrders(ctx, batch))
+	require.Eventually(t, func() bool {
+		_, missing, err := node1.Cache.GetOrders(ctx, orderUIDs(batch))
+		return err == nil && len(missing) == len(batch)
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// Удаление на второй реплике доходит до первой
+	require.NoError(t, node2.RemoveOrder(ctx, order.OrderUID))
+	require.Eventually(t, func() bool {
+		exists, err := node1.Cache.OrderExists(ctx, order.OrderUID)
+		return err == nil && !exists
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// Clear очищает кэши остальных реплик
+	other := datagenerators.GenerateOrder()
+	require.NoError(t, node2.Cache.SaveOrder(ctx, other))
+	require.NoError(t, node1.Clear(ctx))
+	require.Eventually(t, func() bool {
+		orders, err := node2.Cache.GetAllOrders(ctx)
+		return err == nil && len(orders) == 0
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestCoherentCache_PublishesOnlyUIDs(t *testing.T) {
+	ctx := context.Background()
+	node := setupTestCoherentCache(t)
+
+	sub := node.client.Subscribe(ctx, testInvalidationChannel)
+	defer sub.Close()
+	_, err := sub.Receive(ctx)
+	require.NoError(t, err)
+
+	// Заполнение из БД остаётся локальным и не публикуется
+	filled := datagenerators.GenerateOrder()
+	require.NoError(t, FillOrders(ctx, node, []models.Order{filled}))
+	exists, err := node.Cache.OrderExists(ctx, filled.OrderUID)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	order := datagenerators.GenerateOrder()
+	require.NoError(t, node.SaveOrder(ctx, order))
+
+	select {
+	case msg := <-sub.Channel():
+		var payload map[string]any
+		require.NoError(t, json.Unmarshal([]byte(msg.Payload), &payload))
+		assert.Equal(t, invalidationSave, payload["op"])
+		assert.Equal(t, order.OrderUID, payload["order_uid"])
+		assert.NotContains(t, payload, "orders")
+		assert.NotContains(t, msg.Payload, order.TrackNumber)
+	case <-time.After(2 * time.Second):
+		t.Fatal("invalidation event was not published")
+	}
+}
+
+func TestCoherentCache_GapClearsLocalCache(t *testing.T) {
+	ctx := context.Background()
+	node := setupTestCoherentCache(t)
+
+	order := datagenerators.GenerateOrder()
+	require.NoError(t, node.Cache.SaveOrder(ctx, order))
+
+	publish := func(event invalidationEvent) {
+		payload, err := json.Marshal(event)
+		require.NoError(t, err)
+		require.NoError(t, node.client.Publish(ctx, testInvalidationChannel, payload).Err())
+	}
+
+	// Первое событие неизвестного узла принимается с любым номером
+	publish(invalidationEvent{NodeID: "peer", Seq: 5, Op: invalidationRemove, OrderUID: "unrelated"})
+	time.Sleep(50 * time.Millisecond)
+	exists, err := node.Cache.OrderExists(ctx, order.OrderUID)
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	// Пропуск событий 6 и 7: реплика не знает, что было потеряно, и очищает кэш
+	publish(invalidationEvent{NodeID: "peer", Seq: 8, Op: invalidationRemove, OrderUID: "unrelated"})
+	assert.Eventually(t, func() bool {
+		exists, err := node.Cache.OrderExists(ctx, order.OrderUID)
+		return err == nil && !exists
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestInvalidationBus_Track(t *testing.T) {
+	bus := &InvalidationBus{lastSeq: make(map[string]uint64)}
+
+	tests := []struct {
+		name   string
+		event  invalidationEvent
+		accept bool
+		gap    bool
+	}{
+		{"first event of node", invalidationEvent{NodeID: "a", Seq: 3}, true, false},
+		{"next event", invalidationEvent{NodeID: "a", Seq: 4}, true, false},
+		{"duplicate", invalidationEvent{NodeID: "a", Seq: 4}, false, false},
+		{"older event", invalidationEvent{NodeID: "a", Seq: 2}, false, false},
+		{"gap", invalidationEvent{NodeID: "a", Seq: 7}, true, true},
+		{"other node", invalidationEvent{NodeID: "b", Seq: 1}, true, false},
+	}
+
+	for _, tt := range tests {
+		accept, gap := bus.track(tt.event)
+		assert.Equal(t, tt.accept, accept, tt.name)
+		assert.Equal(t, tt.gap, gap, tt.name)
+	}
+}
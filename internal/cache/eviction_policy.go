@@ -0,0 +1,526 @@
+package cache
+
+import (
+	"container/heap"
+	"container/list"
+	"fmt"
+	"hash/fnv"
+)
+
+// EvictionPolicy алгоритм вытеснения in-memory кэша
+type EvictionPolicy string
+
+const (
+	EvictionLRU     EvictionPolicy = "lru"     // вытесняется давно не использованная запись
+	EvictionLFU     EvictionPolicy = "lfu"     // вытесняется реже всего использованная запись
+	EvictionARC     EvictionPolicy = "arc"     // Adaptive Replacement Cache: баланс между недавностью и частотой
+	EvictionTinyLFU EvictionPolicy = "tinylfu" // W-TinyLFU: окно LRU + SLRU с допуском по count-min sketch
+)
+
+// Validate проверяет, что политика известна (пустая строка означает LRU)
+func (p EvictionPolicy) Validate() error {
+	switch p {
+	case EvictionLRU, EvictionLFU, EvictionARC, EvictionTinyLFU, "":
+		return nil
+	default:
+		return fmt.Errorf("unknown eviction policy: %s", p)
+	}
+}
+
+// evictor ведёт порядок вытеснения ключей in-memory кэша.
+// Методы вызываются под блокировкой кэша на запись.
+type evictor interface {
+	insert(key string) // в кэш добавлен новый ключ
+	access(key string) // попадание по ключу или перезапись существующего ключа
+	miss(key string)   // промах по ключу, которого нет в кэше
+	remove(key string) // ключ удалён из кэша явно или по TTL
+
+	// evict выбирает ключ для вытеснения и перестаёт его отслеживать
+	evict() (string, bool)
+	// each обходит ключи; порядок стабилен, пока кэш не меняется
+	each(fn func(key string) bool)
+}
+
+// newEvictor создаёт реализацию политики вытеснения.
+// capacity — ограничение по количеству записей (0 — размер определяется бюджетом памяти).
+func newEvictor(policy EvictionPolicy, capacity int) evictor {
+	switch policy {
+	case EvictionLFU:
+		return newLFUEvictor()
+	case EvictionARC:
+		return newARCEvictor(capacity)
+	case EvictionTinyLFU:
+		return newTinyLFUEvictor(capacity)
+	default:
+		return &lruEvictor{newKeyList()}
+	}
+}
+
+// keyList — список ключей в порядке использования (голова — самый свежий) с индексом по ключу
+type keyList struct {
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+func newKeyList() *keyList {
+	return &keyList{ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (l *keyList) len() int { return l.ll.Len() }
+
+func (l *keyList) contains(key string) bool {
+	_, ok := l.items[key]
+	return ok
+}
+
+func (l *keyList) pushFront(key string) {
+	l.items[key] = l.ll.PushFront(key)
+}
+
+func (l *keyList) moveToFront(key string) bool {
+	e, ok := l.items[key]
+	if ok {
+		l.ll.MoveToFront(e)
+	}
+	return ok
+}
+
+func (l *keyList) remove(key string) bool {
+	e, ok := l.items[key]
+	if ok {
+		l.ll.Remove(e)
+		delete(l.items, key)
+	}
+	return ok
+}
+
+// back возвращает самый давно использованный ключ
+func (l *keyList) back() (string, bool) {
+	e := l.ll.Back()
+	if e == nil {
+		return "", false
+	}
+	return e.Value.(string), true
+}
+
+// front возвращает самый свежий ключ
+func (l *keyList) front() (string, bool) {
+	e := l.ll.Front()
+	if e == nil {
+		return "", false
+	}
+	return e.Value.(string), true
+}
+
+// popBack удаляет и возвращает самый давно использованный ключ
+func (l *keyList) popBack() (string, bool) {
+	key, ok := l.back()
+	if ok {
+		l.remove(key)
+	}
+	return key, ok
+}
+
+// each обходит ключи от самого свежего; возвращает false, если fn остановил обход
+func (l *keyList) each(fn func(key string) bool) bool {
+	for e := l.ll.Front(); e != nil; e = e.Next() {
+		if !fn(e.Value.(string)) {
+			return false
+		}
+	}
+	return true
+}
+
+// lruEvictor — классический LRU
+type lruEvictor struct {
+	keys *keyList
+}
+
+func (e *lruEvictor) insert(key string)             { e.keys.pushFront(key) }
+func (e *lruEvictor) access(key string)             { e.keys.moveToFront(key) }
+func (e *lruEvictor) miss(string)                   {}
+func (e *lruEvictor) remove(key string)             { e.keys.remove(key) }
+func (e *lruEvictor) evict() (string, bool)         { return e.keys.popBack() }
+func (e *lruEvictor) each(fn func(key string) bool) { e.keys.each(fn) }
+
+// lfuItem — ключ LFU-кучи
+type lfuItem struct {
+	key   string
+	freq  uint64
+	tick  uint64 // момент последнего обращения: при равной частоте вытесняется более старый
+	index int
+}
+
+// lfuHeap — min-куча по (freq, tick)
+type lfuHeap []*lfuItem
+
+func (h lfuHeap) Len() int { return len(h) }
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].freq != h[j].freq {
+		return h[i].freq < h[j].freq
+	}
+	return h[i].tick < h[j].tick
+}
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+func (h *lfuHeap) Push(x any) {
+	item := x.(*lfuItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+func (h *lfuHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return item
+}
+
+// lfuEvictor — LFU с вытеснением самой старой записи среди наименее частых
+type lfuEvictor struct {
+	heap  lfuHeap
+	items map[string]*lfuItem
+	tick  uint64
+}
+
+func newLFUEvictor() *lfuEvictor {
+	return &lfuEvictor{items: make(map[string]*lfuItem)}
+}
+
+func (e *lfuEvictor) insert(key string) {
+	e.tick++
+	item := &lfuItem{key: key, freq: 1, tick: e.tick}
+	e.items[key] = item
+	heap.Push(&e.heap, item)
+}
+
+func (e *lfuEvictor) access(key string) {
+	item, ok := e.items[key]
+	if !ok {
+		return
+	}
+	e.tick++
+	item.freq++
+	item.tick = e.tick
+	heap.Fix(&e.heap, item.index)
+}
+
+func (e *lfuEvictor) miss(string) {}
+
+func (e *lfuEvictor) remove(key string) {
+	item, ok := e.items[key]
+	if !ok {
+		return
+	}
+	heap.Remove(&e.heap, item.index)
+	delete(e.items, key)
+}
+
+func (e *lfuEvictor) evict() (string, bool) {
+	if len(e.heap) == 0 {
+		return "", false
+	}
+	item := heap.Pop(&e.heap).(*lfuItem)
+	delete(e.items, item.key)
+	return item.key, true
+}
+
+func (e *lfuEvictor) each(fn func(key string) bool) {
+	for _, item := range e.heap {
+		if !fn(item.key) {
+			return
+		}
+	}
+}
+
+// arcEvictor — Adaptive Replacement Cache (Megiddo, Modha).
+// T1 — ключи, использованные один раз, T2 — повторно; B1 и B2 — «призраки»
+// вытесненных из них ключей, по попаданиям в которые подстраивается целевой размер T1 (p).
+type arcEvictor struct {
+	capacity int
+	p        int
+	t1, t2   *keyList
+	b1, b2   *keyList
+	fromB2   bool // последний добавленный ключ найден в B2 (учитывается при выборе жертвы)
+}
+
+func newARCEvictor(capacity int) *arcEvictor {
+	return &arcEvictor{
+		capacity: capacity,
+		t1:       newKeyList(),
+		t2:       newKeyList(),
+		b1:       newKeyList(),
+		b2:       newKeyList(),
+	}
+}
+
+// size возвращает размер кэша в записях: ёмкость либо, при бюджете памяти, текущее количество записей
+func (e *arcEvictor) size() int {
+	if e.capacity > 0 {
+		return e.capacity
+	}
+	return max(1, e.t1.len()+e.t2.len())
+}
+
+func (e *arcEvictor) insert(key string) {
+	e.fromB2 = false
+	switch {
+	case e.b1.remove(key):
+		e.p = min(e.size(), e.p+max(1, e.b2.len()/max(1, e.b1.len()+1)))
+		e.t2.pushFront(key)
+	case e.b2.remove(key):
+		e.p = max(0, e.p-max(1, e.b1.len()/max(1, e.b2.len()+1)))
+		e.t2.pushFront(key)
+		e.fromB2 = true
+	default:
+		e.t1.pushFront(key)
+	}
+}
+
+func (e *arcEvictor) access(key string) {
+	if e.t1.remove(key) {
+		e.t2.pushFront(key)
+		return
+	}
+	e.t2.moveToFront(key)
+}
+
+func (e *arcEvictor) miss(string) {}
+
+func (e *arcEvictor) remove(key string) {
+	if !e.t1.remove(key) {
+		e.t2.remove(key)
+	}
+}
+
+func (e *arcEvictor) evict() (string, bool) {
+	var key string
+	var ok bool
+
+	t1 := e.t1.len()
+	if t1 > 0 && (t1 > e.p || (e.fromB2 && t1 == e.p) || e.t2.len() == 0) {
+		if key, ok = e.t1.popBack(); ok {
+			e.b1.pushFront(key)
+		}
+	} else if key, ok = e.t2.popBack(); ok {
+		e.b2.pushFront(key)
+	}
+
+	// Призрачные списки ограничены: |T1|+|B1| <= c, а всего отслеживается не более 2c ключей
+	c := e.size()
+	for e.t1.len()+e.b1.len() > c && e.b1.len() > 0 {
+		e.b1.popBack()
+	}
+	for e.t1.len()+e.t2.len()+e.b1.len()+e.b2.len() > 2*c && e.b2.len() > 0 {
+		e.b2.popBack()
+	}
+	return key, ok
+}
+
+func (e *arcEvictor) each(fn func(key string) bool) {
+	if e.t2.each(fn) {
+		e.t1.each(fn)
+	}
+}
+
+// Доли областей W-TinyLFU (в процентах)
+const (
+	tinyLFUWindowPercent    = 1  // окно для новых записей
+	tinyLFUProtectedPercent = 80 // защищённая часть основной области
+)
+
+// tinyLFUEvictor — W-TinyLFU (Einziger, Friedman, Manes).
+// Новые ключи попадают в небольшое LRU-окно. Вытесненный из окна кандидат
+// остаётся в основной SLRU-области, только если по оценке count-min sketch
+// он встречался чаще, чем её жертва. Поэтому разовые обходы
+// (GetAllOrders, прогрев) не вымывают часто запрашиваемые заказы.
+type tinyLFUEvictor struct {
+	capacity  int
+	sketch    *countMinSketch
+	window    *keyList
+	probation *keyList // основная область: ключи, ещё не запрошенные повторно
+	protected *keyList // основная область: ключи с повторными обращениями
+}
+
+func newTinyLFUEvictor(capacity int) *tinyLFUEvictor {
+	return &tinyLFUEvictor{
+		capacity:  capacity,
+		sketch:    newCountMinSketch(capacity),
+		window:    newKeyList(),
+		probation: newKeyList(),
+		protected: newKeyList(),
+	}
+}
+
+// size возвращает размер кэша в записях (см. arcEvictor.size)
+func (e *tinyLFUEvictor) size() int {
+	if e.capacity > 0 {
+		return e.capacity
+	}
+	return max(1, e.window.len()+e.probation.len()+e.protected.len())
+}
+
+func (e *tinyLFUEvictor) windowSize() int {
+	return max(1, e.size()*tinyLFUWindowPercent/100)
+}
+
+func (e *tinyLFUEvictor) protectedSize() int {
+	return (e.size() - e.windowSize()) * tinyLFUProtectedPercent / 100
+}
+
+func (e *tinyLFUEvictor) insert(key string) {
+	e.sketch.increment(key)
+	e.window.pushFront(key)
+
+	// Вытесненные из окна ключи становятся кандидатами в начале испытательной области
+	for e.window.len() > e.windowSize() {
+		candidate, _ := e.window.popBack()
+		e.probation.pushFront(candidate)
+	}
+}
+
+func (e *tinyLFUEvictor) access(key string) {
+	e.sketch.increment(key)
+	switch {
+	case e.window.moveToFront(key):
+	case e.probation.remove(key):
+		e.protected.pushFront(key)
+		// Переполнение защищённой области возвращает самые старые ключи на испытательный срок
+		for e.protected.len() > e.protectedSize() {
+			demoted, _ := e.protected.popBack()
+			e.probation.pushFront(demoted)
+		}
+	default:
+		e.protected.moveToFront(key)
+	}
+}
+
+func (e *tinyLFUEvictor) miss(key string) {
+	e.sketch.increment(key)
+}
+
+func (e *tinyLFUEvictor) remove(key string) {
+	if !e.window.remove(key) && !e.probation.remove(key) {
+		e.protected.remove(key)
+	}
+}
+
+// evict сравнивает последнего кандидата из окна (начало испытательной области)
+// с жертвой основной области (конец испытательной, а если в ней один ключ — защищённой)
+// и вытесняет того, кто встречался реже.
+func (e *tinyLFUEvictor) evict() (string, bool) {
+	candidate, hasCandidate := e.probation.front()
+
+	victimArea := e.probation
+	if e.probation.len() < 2 {
+		victimArea = e.protected
+	}
+	victim, hasVictim := victimArea.back()
+
+	switch {
+	case hasCandidate && hasVictim:
+		// Допуск: кандидат остаётся, только если встречался чаще жертвы
+		if e.sketch.estimate(candidate) > e.sketch.estimate(victim) {
+			victimArea.remove(victim)
+			return victim, true
+		}
+		e.probation.remove(candidate)
+		return candidate, true
+	case hasCandidate:
+		return e.probation.popBack()
+	case hasVictim:
+		return e.protected.popBack()
+	default:
+		return e.window.popBack()
+	}
+}
+
+func (e *tinyLFUEvictor) each(fn func(key string) bool) {
+	if e.window.each(fn) && e.protected.each(fn) {
+		e.probation.each(fn)
+	}
+}
+
+// Параметры count-min sketch
+const (
+	sketchDepth      = 4
+	sketchMinWidth   = 64
+	sketchMaxCounter = 15 // 4-битные счётчики, как в TinyLFU
+	// sketchWidthFactor — счётчиков в строке на одну запись кэша (меньше коллизий при обходах)
+	sketchWidthFactor = 4
+	// sketchDefaultCapacity — ожидаемое число записей, если размер кэша определяется бюджетом памяти
+	sketchDefaultCapacity = 4096
+	// sketchSampleFactor — после capacity*factor инкрементов счётчики делятся пополам (старение)
+	sketchSampleFactor = 10
+)
+
+// countMinSketch — приблизительный счётчик частоты обращений к ключам со старением
+type countMinSketch struct {
+	rows      [sketchDepth][]uint8
+	mask      uint32
+	additions int
+	resetAt   int
+}
+
+func newCountMinSketch(capacity int) *countMinSketch {
+	if capacity <= 0 {
+		capacity = sketchDefaultCapacity
+	}
+	width := sketchMinWidth
+	for width < capacity*sketchWidthFactor {
+		width <<= 1
+	}
+
+	s := &countMinSketch{mask: uint32(width - 1), resetAt: capacity * sketchSampleFactor}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+// indexes возвращает позиции ключа в строках (двойное хэширование)
+func (s *countMinSketch) indexes(key string) [sketchDepth]uint32 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	sum := h.Sum64()
+	h1, h2 := uint32(sum), uint32(sum>>32)|1
+
+	var idx [sketchDepth]uint32
+	for i := range idx {
+		idx[i] = (h1 + uint32(i)*h2) & s.mask
+	}
+	return idx
+}
+
+func (s *countMinSketch) increment(key string) {
+	for i, pos := range s.indexes(key) {
+		if s.rows[i][pos] < sketchMaxCounter {
+			s.rows[i][pos]++
+		}
+	}
+
+	s.additions++
+	if s.additions >= s.resetAt {
+		s.reset()
+	}
+}
+
+func (s *countMinSketch) estimate(key string) uint8 {
+	est := uint8(sketchMaxCounter)
+	for i, pos := range s.indexes(key) {
+		est = min(est, s.rows[i][pos])
+	}
+	return est
+}
+
+// reset делит все счётчики пополам, чтобы старые обращения постепенно забывались
+func (s *countMinSketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
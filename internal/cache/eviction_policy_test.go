@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var allEvictionPolicies = []EvictionPolicy{EvictionLRU, EvictionLFU, EvictionARC, EvictionTinyLFU}
+
+func TestInMemoryCache_EvictionPolicies(t *testing.T) {
+	ctx := context.Background()
+
+	for _, policy := range allEvictionPolicies {
+		t.Run(string(policy), func(t *testing.T) {
+			cache := NewInMemoryCache(10, time.Minute, WithEvictionPolicy(policy))
+			t.Cleanup(func() { cache.Close() })
+
+			for i := 0; i < 50; i++ {
+				uid := fmt.Sprintf("order-%d", i)
+				require.NoError(t, cache.SaveOrder(ctx, models.Order{OrderUID: uid}))
+				_, _, err := cache.GetOrder(ctx, uid)
+				require.NoError(t, err)
+			}
+
+			stats, err := cache.Stats(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, string(policy), stats.Policy)
+			assert.Equal(t, int64(10), stats.Size)
+			assert.Equal(t, uint64(40), stats.Evictions)
+
+			all, err := cache.GetAllOrders(ctx)
+			require.NoError(t, err)
+			assert.Len(t, all, 10)
+
+			// Постраничный обход согласован с GetAllOrders
+			listed := 0
+			cursor := ""
+			for {
+				orders, next, err := cache.ListOrders(ctx, cursor, 3)
+				require.NoError(t, err)
+				listed += len(orders)
+				if next == "" {
+					break
+				}
+				cursor = next
+			}
+			assert.Equal(t, 10, listed)
+
+			for _, order := range all {
+				require.NoError(t, cache.RemoveOrder(ctx, order.OrderUID))
+			}
+			stats, err = cache.Stats(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, int64(0), stats.Size)
+			assert.Equal(t, int64(0), stats.MemoryBytes)
+		})
+	}
+}
+
+func TestInMemoryCache_LFUKeepsFrequentOrders(t *testing.T) {
+	ctx := context.Background()
+	cache := NewInMemoryCache(3, time.Minute, WithEvictionPolicy(EvictionLFU))
+	t.Cleanup(func() { cache.Close() })
+
+	for _, uid := range []string{"hot", "warm", "cold"} {
+		require.NoError(t, cache.SaveOrder(ctx, models.Order{OrderUID: uid}))
+	}
+	for i := 0; i < 3; i++ {
+		_, _, _ = cache.GetOrder(ctx, "hot")
+		_, _, _ = cache.GetOrder(ctx, "warm")
+	}
+	require.NoError(t, cache.SaveOrder(ctx, models.Order{OrderUID: "new"}))
+
+	for uid, expected := range map[string]bool{"hot": true, "warm": true, "cold": false, "new": true} {
+		exists, err := cache.OrderExists(ctx, uid)
+		require.NoError(t, err)
+		assert.Equal(t, expected, exists, uid)
+	}
+}
+
+// Разовый обход большого числа заказов не должен вымывать часто запрашиваемые
+func TestInMemoryCache_ScanResistance(t *testing.T) {
+	ctx := context.Background()
+
+	for _, policy := range []EvictionPolicy{EvictionARC, EvictionTinyLFU} {
+		t.Run(string(policy), func(t *testing.T) {
+			cache := NewInMemoryCache(100, time.Minute, WithEvictionPolicy(policy))
+			t.Cleanup(func() { cache.Close() })
+
+			hot := make([]string, 50)
+			for i := range hot {
+				hot[i] = fmt.Sprintf("hot-%d", i)
+				require.NoError(t, cache.SaveOrder(ctx, models.Order{OrderUID: hot[i]}))
+			}
+			for round := 0; round < 5; round++ {
+				for _, uid := range hot {
+					_, _, _ = cache.GetOrder(ctx, uid)
+				}
+			}
+
+			for i := 0; i < 1000; i++ {
+				require.NoError(t, cache.SaveOrder(ctx, models.Order{OrderUID: fmt.Sprintf("scan-%d", i)}))
+			}
+
+			kept := 0
+			for _, uid := range hot {
+				if exists, _ := cache.OrderExists(ctx, uid); exists {
+					kept++
+				}
+			}
+			assert.GreaterOrEqual(t, kept, 45, "hot orders flushed by scan")
+		})
+	}
+}
+
+func TestEvictionPolicy_Validate(t *testing.T) {
+	for _, policy := range allEvictionPolicies {
+		assert.NoError(t, policy.Validate())
+	}
+	assert.NoError(t, EvictionPolicy("").Validate())
+	assert.Error(t, EvictionPolicy("fifo").Validate())
+}
+
+func TestCountMinSketch(t *testing.T) {
+	sketch := newCountMinSketch(100)
+	for i := 0; i < 10; i++ {
+		sketch.increment("hot")
+	}
+	sketch.increment("cold")
+
+	assert.GreaterOrEqual(t, sketch.estimate("hot"), uint8(10))
+	assert.GreaterOrEqual(t, sketch.estimate("cold"), uint8(1))
+	assert.Less(t, sketch.estimate("cold"), sketch.estimate("hot"))
+
+	// Старение: после достаточного числа обращений счётчики уменьшаются
+	for i := 0; i < sketch.resetAt; i++ {
+		sketch.increment(fmt.Sprintf("noise-%d", i))
+	}
+	assert.Less(t, sketch.estimate("hot"), uint8(10))
+}
@@ -11,37 +11,229 @@ type CacheType string
 const (
 	CacheTypeRedis    CacheType = "redis"
 	CacheTypeInMemory CacheType = "inmemory"
+	CacheTypeTiered   CacheType = "tiered" // in-memory L1 перед Redis L2
+	CacheTypeBolt     CacheType = "bolt"   // встроенное файловое хранилище bbolt для одного узла
 )
 
 // Config конфигурация кэша
 type Config struct {
 	Type     CacheType
 	Redis    RedisConfig
-	Capacity int           // для in-memory кэша: максимальное количество записей
-	TTL      time.Duration // для in-memory кэша: время жизни записи
+	Capacity int           // для in-memory кэша и L1 tiered-кэша: максимальное количество записей
+	MaxBytes int64         // для in-memory кэша и L1: бюджет памяти в байтах; если задан, заменяет Capacity
+	Shards   int           // для in-memory кэша и L1: количество независимых LRU-сегментов (0 или 1 — без сегментов)
+	TTL      time.Duration // время жизни записи для всех бэкендов (0 — значение по умолчанию бэкенда)
+
+	// Мягкий TTL in-memory кэша (только для типа inmemory; 0 — выключен): после него заказ
+	// отдаётся как устаревший и обновляется из БД в фоне, а TTL становится жёстким
+	SoftTTL time.Duration
+
+	PromoteWindow time.Duration  // для in-memory кэша и L1: окно приблизительного LRU (0 — точный LRU)
+	Eviction      EvictionPolicy // для in-memory кэша и L1: алгоритм вытеснения (пусто — LRU)
+
+	// Снимки in-memory кэша на диск (только для типа inmemory; пустой путь — выключены)
+	SnapshotPath     string
+	SnapshotInterval time.Duration // период записи снимка (0 — только при закрытии)
+
+	TTLPolicy TTLPolicy // политика уточнения TTL по заказу (может быть nil)
+
+	NegativeTTL time.Duration // для read-through: сколько помнить, что заказа нет в БД
+
+	// Шина инвалидации через Redis pub/sub: для inmemory включается InvalidationBus
+	// (используются настройки Redis), tiered использует её всегда
+	InvalidationBus     bool
+	InvalidationChannel string // канал шины (пусто — DefaultInvalidationChannel)
+
+	WarmUp WarmUpConfig // прогрев кэша при старте сервиса
+
+	Path string // файл встроенного хранилища (только для типа bolt)
+
+	// Сериализация заказов в Redis и bolt (пустые значения — JSON без сжатия)
+	Codec             CodecFormat
+	Compression       Compression
+	CompressThreshold int // минимальный размер записи в байтах для сжатия (0 — значение по умолчанию)
 }
 type RedisConfig struct {
-	Addr     string
-	Password string
-	DB       int
-}
-
-// New создает кэш в зависимости от конфигурации
-func New(config Config, err error) (Cache, error) {
-	switch config.Type {
-	case CacheTypeRedis:
-		return NewRedisCache(config.Redis.Addr, config.Redis.Password, config.Redis.DB)
-	case CacheTypeInMemory:
-		if config.Capacity <= 0 {
-			return nil, fmt.Errorf("in-memory cache capacity must be > 0")
-		}
-		// Если TTL не задан — используем разумное значение по умолчанию, например 1 час
-		ttl := config.TTL
-		if ttl == 0 {
-			ttl = 1 * time.Hour
-		}
-		return NewInMemoryCache(config.Capacity, ttl), nil
-	default:
-		return nil, fmt.Errorf("unknown cache type: %s", config.Type)
+	Mode       RedisMode // standalone (по умолчанию), sentinel или cluster
+	Addr       string    // адрес одиночного узла
+	Addrs      []string  // адреса sentinel-узлов или seed-узлов кластера
+	MasterName string    // имя мастера для sentinel
+	Username   string    // пользователь ACL (Redis 6+)
+	Password   string
+	DB         int // не поддерживается в режиме cluster
+
+	SentinelPassword string // пароль sentinel-узлов, если отличается
+	TLS              RedisTLSConfig
+}
+
+// Встроенные типы кэша; сторонние бэкенды регистрируются через Register из своих пакетов
+func init() {
+	Register(CacheTypeRedis, newRedisBackend, validateRedisBackend)
+	Register(CacheTypeInMemory, newInMemoryBackend, validateInMemoryBackend)
+	Register(CacheTypeTiered, newTieredBackend, validateTieredBackend)
+	Register(CacheTypeBolt, newBoltBackend, validateBoltBackend)
+}
+
+// newRedisBackend создаёт Redis-кэш
+func newRedisBackend(config Config) (Cache, error) {
+	opts, err := storeOptions(config)
+	if err != nil {
+		return nil, err
+	}
+	return NewRedisCacheFromConfig(config.Redis, opts...)
+}
+
+// newInMemoryBackend создаёт in-memory кэш; при включённой шине инвалидации — согласованный с репликами
+func newInMemoryBackend(config Config) (Cache, error) {
+	local, err := newInMemoryFromConfig(config,
+		WithSoftTTL(config.SoftTTL),
+		WithSnapshot(config.SnapshotPath, config.SnapshotInterval),
+	)
+	if err != nil || !config.InvalidationBus {
+		return local, err
+	}
+	coherent, err := NewCoherentCache(local, config.Redis, WithInvalidationChannel(config.InvalidationChannel))
+	if err != nil {
+		_ = local.Close()
+		return nil, err
+	}
+	return coherent, nil
+}
+
+// newTieredBackend создаёт двухуровневый кэш: in-memory L1 перед Redis L2
+func newTieredBackend(config Config) (Cache, error) {
+	opts, err := storeOptions(config)
+	if err != nil {
+		return nil, err
+	}
+	l2, err := NewRedisCacheFromConfig(config.Redis, opts...)
+	if err != nil {
+		return nil, err
+	}
+	l1, err := newInMemoryFromConfig(config)
+	if err != nil {
+		_ = l2.Close()
+		return nil, err
+	}
+	tiered, err := NewTieredCache(l1, l2, WithInvalidationChannel(config.InvalidationChannel))
+	if err != nil {
+		_ = l1.Close()
+		_ = l2.Close()
+		return nil, err
+	}
+	return tiered, nil
+}
+
+// newBoltBackend открывает встроенное файловое хранилище
+func newBoltBackend(config Config) (Cache, error) {
+	opts, err := storeOptions(config)
+	if err != nil {
+		return nil, err
+	}
+	return NewBoltCache(config.Path, opts...)
+}
+
+// validateRedisBackend проверяет настройки подключения к Redis (не подключаясь к нему) и настройки сериализации
+func validateRedisBackend(config Config) error {
+	if err := config.Redis.Validate(); err != nil {
+		return err
+	}
+	_, err := storeOptions(config)
+	return err
+}
+
+// validateInMemoryBackend проверяет ограничения in-memory кэша, мягкий TTL, снимки и шину инвалидации
+func validateInMemoryBackend(config Config) error {
+	if err := validateInMemoryLimits(config); err != nil {
+		return err
+	}
+	if config.SoftTTL < 0 {
+		return fmt.Errorf("soft TTL must not be negative")
+	}
+	if config.SoftTTL > 0 && config.TTL > 0 && config.SoftTTL >= config.TTL {
+		return fmt.Errorf("soft TTL must be less than TTL")
+	}
+	if config.SnapshotInterval < 0 {
+		return fmt.Errorf("snapshot interval must not be negative")
+	}
+	if config.InvalidationBus {
+		return config.Redis.Validate()
+	}
+	return nil
+}
+
+// validateTieredBackend проверяет настройки обоих уровней tiered-кэша
+func validateTieredBackend(config Config) error {
+	if err := validateRedisBackend(config); err != nil {
+		return err
+	}
+	return validateInMemoryLimits(config)
+}
+
+// validateBoltBackend проверяет путь к файлу хранилища и настройки сериализации
+func validateBoltBackend(config Config) error {
+	if config.Path == "" {
+		return fmt.Errorf("bolt cache path is required")
+	}
+	_, err := storeOptions(config)
+	return err
+}
+
+// validateInMemoryLimits проверяет ограничения in-memory кэша (и L1 tiered-кэша)
+func validateInMemoryLimits(config Config) error {
+	if config.MaxBytes < 0 {
+		return fmt.Errorf("in-memory cache max bytes must not be negative")
+	}
+	if config.MaxBytes == 0 && config.Capacity <= 0 {
+		return fmt.Errorf("in-memory cache capacity must be > 0")
+	}
+	if config.Shards < 0 {
+		return fmt.Errorf("in-memory cache shards must not be negative")
+	}
+	if config.PromoteWindow < 0 {
+		return fmt.Errorf("promote window must not be negative")
+	}
+	return config.Eviction.Validate()
+}
+
+// newInMemoryFromConfig создаёт in-memory кэш (при Shards > 1 — сегментированный)
+// по настройкам Capacity (или MaxBytes) и TTL; extra дополняют опции из конфигурации.
+// Ограничения проверены заранее validateInMemoryLimits.
+func newInMemoryFromConfig(config Config, extra ...Option) (Cache, error) {
+	capacity := config.Capacity
+	if config.MaxBytes > 0 {
+		// Бюджет памяти заменяет ограничение по количеству записей
+		capacity = 0
+	}
+	// Если TTL не задан — используем разумное значение по умолчанию, например 1 час
+	ttl := config.TTL
+	if ttl == 0 {
+		ttl = 1 * time.Hour
+	}
+	opts := []Option{
+		WithTTLPolicy(config.TTLPolicy),
+		WithMaxBytes(config.MaxBytes),
+		WithApproximateLRU(config.PromoteWindow),
+		WithEvictionPolicy(config.Eviction),
+	}
+	opts = append(opts, extra...)
+	if config.Shards > 1 {
+		return NewShardedInMemoryCache(config.Shards, capacity, ttl, opts...)
+	}
+	return NewInMemoryCache(capacity, ttl, opts...), nil
+}
+
+// storeOptions возвращает опции бэкендов, хранящих заказы в сериализованном виде (Redis, bolt);
+// при нулевом TTL остаётся значение по умолчанию бэкенда
+func storeOptions(config Config) ([]Option, error) {
+	codec, err := NewCodec(config.Codec, config.Compression, config.CompressThreshold)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []Option{WithTTLPolicy(config.TTLPolicy), WithCodec(codec)}
+	if config.TTL > 0 {
+		opts = append(opts, WithTTL(config.TTL))
 	}
+	return opts, nil
 }
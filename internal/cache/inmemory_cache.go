@@ -1,7 +1,13 @@
 package cache
+
 import (
-	"container/list"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/models"
@@ -11,35 +17,88 @@ import (
 type entry struct {
 	key   string
 	value models.Order
-	exp   time.Time
+	exp   time.Time // жёсткий TTL: после него запись не отдаётся
+	stale time.Time // мягкий TTL: после него запись отдаётся как устаревшая (не позже exp)
+	size  int64     // оценка занимаемой памяти
+
+	promoted time.Time // когда запись последний раз перемещалась в начало LRU-списка
 }
 
-// InMemoryCache — потокобезопасный in-memory кэш с поддержкой TTL и LRU-вытеснения.
+// InMemoryCache — потокобезопасный in-memory кэш с поддержкой TTL и вытеснения
+// по выбранной политике (по умолчанию LRU).
 type InMemoryCache struct {
 	mu       sync.RWMutex
-	capacity int                      // Максимальное количество записей в кэше.
-	ttl      time.Duration            // Время жизни записи (0 — без ограничения).
-	cache    map[string]*list.Element // Отображение ключа на элемент двусвязного списка.
-	lruList  *list.List               // Двусвязный список: голова — самый свежий, хвост — самый старый.
-	stopCh   chan struct{}            // Канал для остановки фоновой горутины очистки.
+	capacity int               // Максимальное количество записей в кэше (0 — без ограничения).
+	maxBytes int64             // Максимальный суммарный размер записей в байтах (0 — без ограничения).
+	ttl      time.Duration     // Время жизни записи (0 — без ограничения).
+	softTTL  time.Duration     // Мягкий TTL (0 — выключен).
+	policy   TTLPolicy         // Политика уточнения TTL по заказу (может быть nil).
+	cache    map[string]*entry // Записи по ключу.
+	index    orderIndex        // Вторичные индексы по трек-номеру и клиенту.
+	stopCh   chan struct{}     // Канал для остановки фоновой горутины очистки.
+
+	eviction EvictionPolicy // Алгоритм вытеснения.
+	evictor  evictor        // Порядок вытеснения ключей.
+
+	// Окно приблизительного LRU: запись, продвинутая в начало списка позже чем
+	// promoteWindow назад, читается под RLock без перемещения (0 — точный LRU).
+	// Действует только для политики LRU.
+	promoteWindow time.Duration
+
+	snapshotPath string // Файл снимка, записываемого при Close (пусто — снимки выключены).
+
+	// Статистика. hits и misses обновляются и под RLock, остальное — под mu.
+	hits        atomic.Uint64
+	staleHits   atomic.Uint64
+	misses      atomic.Uint64
+	evictions   uint64
+	expirations uint64
+	bytes       int64 // Суммарная оценка памяти записей.
 }
 
 // NewInMemoryCache создаёт новый in-memory кэш с заданным размером и временем жизни записей.
-// capacity — максимальное количество записей (ограничение LRU).
+// capacity — максимальное количество записей (ограничение LRU); 0 — без ограничения.
 // ttl — время жизни записи; если 0, записи не устаревают автоматически.
-func NewInMemoryCache(capacity int, ttl time.Duration) *InMemoryCache {
+// opts позволяют переопределить TTL, задать политику TTL по заказу, бюджет памяти (WithMaxBytes)
+// алгоритм вытеснения (WithEvictionPolicy; неизвестный алгоритм заменяется на LRU)
+// мягкий TTL (WithSoftTTL) и снимки на диск (WithSnapshot).
+func NewInMemoryCache(capacity int, ttl time.Duration, opts ...Option) *InMemoryCache {
+	o := applyOptions(options{ttl: ttl}, opts)
+	if o.eviction.Validate() != nil || o.eviction == "" {
+		o.eviction = EvictionLRU
+	}
+	if o.eviction != EvictionLRU {
+		o.promoteWindow = 0
+	}
+	if o.softTTL < 0 || (o.ttl > 0 && o.softTTL >= o.ttl) {
+		o.softTTL = 0
+	}
+
 	c := &InMemoryCache{
 		capacity: capacity,
-		ttl:      ttl,
-		cache:    make(map[string]*list.Element, capacity),
-		lruList:  list.New(),
+		maxBytes: o.maxBytes,
+		ttl:      o.ttl,
+		softTTL:  o.softTTL,
+		policy:   o.ttlPolicy,
+		cache:    make(map[string]*entry, capacity),
+		index:    newOrderIndex(),
 		stopCh:   make(chan struct{}),
+
+		eviction: o.eviction,
+		evictor:  newEvictor(o.eviction, capacity),
+
+		promoteWindow: o.promoteWindow,
+
+		snapshotPath: o.snapshotPath,
 	}
 
 	// Запускаем фоновую горутину для периодической очистки просроченных записей.
-	if ttl > 0 {
+	if c.ttl > 0 {
 		go c.startCleanup()
 	}
+	if o.snapshotPath != "" && o.snapshotInterval > 0 {
+		go runSnapshots(c, o.snapshotPath, o.snapshotInterval, c.stopCh)
+	}
 
 	return c
 }
@@ -68,136 +127,490 @@ func (c *InMemoryCache) cleanupExpired() {
 	var keysToRemove []string
 
 	// Собираем ключи просроченных записей.
-	for key, elem := range c.cache {
-		if now.After(elem.Value.(*entry).exp) {
+	for key, ent := range c.cache {
+		if now.After(ent.exp) {
 			keysToRemove = append(keysToRemove, key)
 		}
 	}
 
-	// Удаляем просроченные записи из списка и карты.
+	// Удаляем просроченные записи.
 	for _, key := range keysToRemove {
-		elem := c.cache[key]
-		c.lruList.Remove(elem)
+		c.removeEntry(key)
+		c.expirations++
+	}
+}
+
+// deleteEntry удаляет запись из карты, вторичных индексов и учёта памяти. Вызывается под c.mu.
+func (c *InMemoryCache) deleteEntry(key string) {
+	if ent, ok := c.cache[key]; ok {
 		delete(c.cache, key)
+		c.index.remove(ent.value)
+		c.bytes -= ent.size
 	}
 }
 
-// evictIfNeeded удаляет наименее недавно использованные записи, если превышен лимит ёмкости.
+// removeEntry удаляет запись из кэша и из порядка вытеснения. Вызывается под c.mu.
+func (c *InMemoryCache) removeEntry(key string) {
+	c.deleteEntry(key)
+	c.evictor.remove(key)
+}
+
+// evictIfNeeded вытесняет записи по выбранной политике, пока не выполнены
+// ограничения по количеству записей и по суммарному размеру.
+// Запись, которая одна больше бюджета памяти, тоже вытесняется.
 func (c *InMemoryCache) evictIfNeeded() {
-	for c.lruList.Len() > c.capacity && c.capacity > 0 {
-		oldest := c.lruList.Back()
-		if oldest == nil {
+	for c.overLimit() {
+		key, ok := c.evictor.evict()
+		if !ok {
 			break
 		}
-		ent := oldest.Value.(*entry)
-		delete(c.cache, ent.key)
-		c.lruList.Remove(oldest)
+		c.deleteEntry(key)
+		c.evictions++
 	}
 }
 
+// overLimit сообщает, превышено ли одно из ограничений. Вызывается под c.mu.
+func (c *InMemoryCache) overLimit() bool {
+	if c.capacity > 0 && len(c.cache) > c.capacity {
+		return true
+	}
+	return c.maxBytes > 0 && c.bytes > c.maxBytes
+}
+
 // SaveOrder сохраняет заказ в кэш с установленным временем жизни.
-func (c *InMemoryCache) SaveOrder(order models.Order) error {
+func (c *InMemoryCache) SaveOrder(ctx context.Context, order models.Order) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	exp := time.Now().Add(c.ttl)
+	c.save(order, time.Now())
+	return nil
+}
+
+// SaveOrders сохраняет заказы под одной блокировкой.
+func (c *InMemoryCache) SaveOrders(ctx context.Context, orders []models.Order) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for _, order := range orders {
+		c.save(order, now)
+	}
+	return nil
+}
+
+// save сохраняет заказ с жёстким и мягким TTL, отсчитанными от now. Вызывается под c.mu.
+func (c *InMemoryCache) save(order models.Order, now time.Time) {
+	exp := now.Add(options{ttl: c.ttl, ttlPolicy: c.policy}.ttlFor(order))
+	stale := exp
+	if c.softTTL > 0 && now.Add(c.softTTL).Before(exp) {
+		stale = now.Add(c.softTTL)
+	}
+	c.store(order, stale, exp)
+}
+
+// store добавляет или заменяет запись и вытесняет лишние. Вызывается под c.mu.
+func (c *InMemoryCache) store(order models.Order, stale, exp time.Time) {
 	newEntry := &entry{
 		key:   order.OrderUID,
 		value: order,
 		exp:   exp,
-	}
+		stale: stale,
+		size:  estimateOrderSize(order),
 
-	// Если запись с таким ключом уже существует — удаляем её.
-	if elem, exists := c.cache[order.OrderUID]; exists {
-		c.lruList.Remove(elem)
+		promoted: time.Now(),
 	}
 
-	// Добавляем новую запись в начало списка (помечаем как недавно использованную).
-	elem := c.lruList.PushFront(newEntry)
-	c.cache[order.OrderUID] = elem
+	// Перезапись существующего ключа считается обращением к нему.
+	if _, exists := c.cache[order.OrderUID]; exists {
+		c.deleteEntry(order.OrderUID)
+		c.evictor.access(order.OrderUID)
+	} else {
+		c.evictor.insert(order.OrderUID)
+	}
+	c.cache[order.OrderUID] = newEntry
+	c.index.add(order)
+	c.bytes += newEntry.size
 
-	// При необходимости удаляем старые записи, чтобы не превысить лимит ёмкости.
+	// При необходимости вытесняем записи, чтобы не превысить ограничения.
 	c.evictIfNeeded()
-
-	return nil
 }
 
 // GetOrder возвращает заказ по UID, если он существует и не просрочен.
-// Возвращает заказ, флаг существования и ошибку (всегда nil в текущей реализации).
-func (c *InMemoryCache) GetOrder(orderUID string) (models.Order, bool, error) {
+// Возвращает заказ, флаг существования и ошибку (только если контекст отменён).
+// Устаревший после мягкого TTL заказ тоже возвращается; отличить его позволяет GetOrderStale.
+func (c *InMemoryCache) GetOrder(ctx context.Context, orderUID string) (models.Order, bool, error) {
+	order, ok, _, err := c.GetOrderStale(ctx, orderUID)
+	return order, ok, err
+}
+
+// GetOrderStale возвращает заказ и признак того, что его мягкий TTL истёк.
+func (c *InMemoryCache) GetOrderStale(ctx context.Context, orderUID string) (models.Order, bool, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Order{}, false, false, err
+	}
+
+	if c.promoteWindow > 0 {
+		if order, ok, stale, done := c.lookupShared(orderUID); done {
+			c.countLookup(ok, stale)
+			return order, ok, stale, nil
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	order, ok, stale := c.lookup(orderUID)
+	c.countLookup(ok, stale)
+	return order, ok, stale, nil
+}
+
+// GetOrders возвращает найденные заказы и отсутствующие UID под одной блокировкой.
+// Каждый UID учитывается в статистике так же, как отдельный GetOrder.
+func (c *InMemoryCache) GetOrders(ctx context.Context, orderUIDs []string) ([]models.Order, []string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	elem, ok := c.cache[orderUID]
-	if !ok {
-		return models.Order{}, false, nil
+	found := make([]models.Order, 0, len(orderUIDs))
+	var missing []string
+	for _, uid := range orderUIDs {
+		order, ok, stale := c.lookup(uid)
+		c.countLookup(ok, stale)
+		if ok {
+			found = append(found, order)
+		} else {
+			missing = append(missing, uid)
+		}
+	}
+	return found, missing, nil
+}
+
+// lookupShared пытается обслужить чтение под RLock (приблизительный LRU).
+// done = false означает, что запись нужно продвинуть или удалить, и чтение
+// следует повторить под блокировкой на запись.
+func (c *InMemoryCache) lookupShared(orderUID string) (order models.Order, ok, stale, done bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	ent, found := c.cache[orderUID]
+	if !found {
+		return models.Order{}, false, false, true
+	}
+
+	now := time.Now()
+	if now.After(ent.exp) || now.Sub(ent.promoted) >= c.promoteWindow {
+		return models.Order{}, false, false, false
+	}
+	return ent.value, true, now.After(ent.stale), true
+}
+
+// countLookup учитывает результат GetOrder в статистике
+func (c *InMemoryCache) countLookup(hit, stale bool) {
+	switch {
+	case hit && stale:
+		c.hits.Add(1)
+		c.staleHits.Add(1)
+	case hit:
+		c.hits.Add(1)
+	default:
+		c.misses.Add(1)
+	}
+}
+
+// lookup ищет не просроченную запись и сообщает политике вытеснения об обращении.
+// stale сообщает, что истёк мягкий TTL записи. Просроченная запись удаляется. Вызывается под c.mu.
+func (c *InMemoryCache) lookup(orderUID string) (order models.Order, ok, stale bool) {
+	ent, found := c.cache[orderUID]
+	if !found {
+		c.evictor.miss(orderUID)
+		return models.Order{}, false, false
 	}
 
-	ent := elem.Value.(*entry)
 	now := time.Now()
 
-	// Если запись просрочена — удаляем её и возвращаем "не найдено".
+	// Если истёк жёсткий TTL — удаляем запись и возвращаем "не найдено".
 	if now.After(ent.exp) {
-		c.lruList.Remove(elem)
-		delete(c.cache, orderUID)
-		return models.Order{}, false, nil
+		c.removeEntry(orderUID)
+		c.expirations++
+		c.evictor.miss(orderUID)
+		return models.Order{}, false, false
 	}
 
-	// Обновляем позицию записи в списке (помечаем как недавно использованную).
-	c.lruList.MoveToFront(elem)
-	return ent.value, true, nil
+	// Сообщаем политике об обращении (для LRU — перемещаем в начало списка).
+	c.evictor.access(orderUID)
+	ent.promoted = now
+	return ent.value, true, now.After(ent.stale)
 }
 
 // OrderExists проверяет, существует ли в кэше не просроченный заказ с указанным UID.
-func (c *InMemoryCache) OrderExists(orderUID string) (bool, error) {
-	_, ok, _ := c.GetOrder(orderUID)
+// В отличие от GetOrder, не учитывается в статистике попаданий.
+func (c *InMemoryCache) OrderExists(ctx context.Context, orderUID string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok, _ := c.lookup(orderUID)
 	return ok, nil
 }
 
 // RemoveOrder удаляет заказ из кэша по его UID.
-func (c *InMemoryCache) RemoveOrder(orderUID string) error {
+func (c *InMemoryCache) RemoveOrder(ctx context.Context, orderUID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.cache[orderUID]; ok {
+		c.removeEntry(orderUID)
+	}
+	return nil
+}
+
+// RemoveOrders удаляет заказы под одной блокировкой.
+func (c *InMemoryCache) RemoveOrders(ctx context.Context, orderUIDs []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if elem, ok := c.cache[orderUID]; ok {
-		c.lruList.Remove(elem)
-		delete(c.cache, orderUID)
+	for _, uid := range orderUIDs {
+		if _, ok := c.cache[uid]; ok {
+			c.removeEntry(uid)
+		}
 	}
 	return nil
 }
 
 // Clear полностью очищает кэш.
-func (c *InMemoryCache) Clear() error {
+func (c *InMemoryCache) Clear(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.cache = make(map[string]*list.Element, c.capacity)
-	c.lruList = list.New()
+	c.cache = make(map[string]*entry, c.capacity)
+	c.index = newOrderIndex()
+	c.evictor = newEvictor(c.eviction, c.capacity)
+	c.bytes = 0
 	return nil
 }
 
-// GetAllOrders возвращает все не просроченные заказы из кэша.
-func (c *InMemoryCache) GetAllOrders() ([]models.Order, error) {
+// Stats возвращает статистику кэша. Память оценивается по содержимому заказов.
+func (c *InMemoryCache) Stats(ctx context.Context) (Stats, error) {
+	if err := ctx.Err(); err != nil {
+		return Stats{}, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return Stats{
+		Backend:     string(CacheTypeInMemory),
+		Hits:        c.hits.Load(),
+		StaleHits:   c.staleHits.Load(),
+		Misses:      c.misses.Load(),
+		Evictions:   c.evictions,
+		Expirations: c.expirations,
+		Policy:      string(c.eviction),
+		Size:        int64(len(c.cache)),
+		Capacity:    c.capacity,
+		MaxBytes:    c.maxBytes,
+		MemoryBytes: c.bytes,
+	}, nil
+}
+
+// FindOrders возвращает не просроченные заказы с заданным значением поля по вторичному индексу.
+// Поиск не влияет на порядок вытеснения и статистику попаданий.
+func (c *InMemoryCache) FindOrders(ctx context.Context, field IndexField, value string) ([]models.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := field.Validate(); err != nil {
+		return nil, err
+	}
+
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
 	now := time.Now()
-	orders := make([]models.Order, 0, c.lruList.Len())
-	for e := c.lruList.Front(); e != nil; e = e.Next() {
-		ent := e.Value.(*entry)
-		if now.Before(ent.exp) {
+	uids := c.index.lookup(field, value)
+	orders := make([]models.Order, 0, len(uids))
+	for _, uid := range uids {
+		if ent := c.cache[uid]; now.Before(ent.exp) {
 			orders = append(orders, ent.value)
 		}
 	}
+	sortIndexed(orders)
+	return orders, nil
+}
+
+// GetAllOrders возвращает все не просроченные заказы из кэша.
+func (c *InMemoryCache) GetAllOrders(ctx context.Context) ([]models.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	now := time.Now()
+	orders := make([]models.Order, 0, len(c.cache))
+	c.evictor.each(func(key string) bool {
+		if ent := c.cache[key]; now.Before(ent.exp) {
+			orders = append(orders, ent.value)
+		}
+		return true
+	})
 	return orders, nil
 }
 
-// Close останавливает фоновую горутину очистки и очищает кэш.
+// ListOrders возвращает страницу не просроченных заказов в порядке политики вытеснения
+// (для LRU — от самых свежих к самым старым). Курсор — позиция в этом порядке, поэтому при
+// одновременных изменениях кэша записи на границе страниц могут повториться или пропуститься.
+func (c *InMemoryCache) ListOrders(ctx context.Context, cursor string, limit int) ([]models.Order, string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+	if limit <= 0 {
+		return nil, "", fmt.Errorf("limit must be > 0")
+	}
+	offset, err := parseOffsetCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	now := time.Now()
+	orders := make([]models.Order, 0, limit)
+	pos, more := 0, false
+	c.evictor.each(func(key string) bool {
+		if len(orders) == limit {
+			more = true
+			return false
+		}
+		if pos++; pos <= offset {
+			return true
+		}
+		if ent := c.cache[key]; now.Before(ent.exp) {
+			orders = append(orders, ent.value)
+		}
+		return true
+	})
+
+	if !more {
+		return orders, "", nil
+	}
+	return orders, strconv.Itoa(pos), nil
+}
+
+// parseOffsetCursor разбирает курсор-смещение; пустой курсор соответствует началу
+func parseOffsetCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	offset, err := strconv.Atoi(cursor)
+	if err != nil || offset < 0 {
+		return 0, ErrInvalidCursor
+	}
+	return offset, nil
+}
+
+// WriteSnapshot записывает снимок не просроченных записей в порядке вытеснения.
+func (c *InMemoryCache) WriteSnapshot(w io.Writer) error {
+	now := time.Now()
+	return encodeSnapshot(w, snapshotData{CreatedAt: now, Entries: c.snapshotEntries(now)})
+}
+
+// snapshotEntries возвращает не просроченные записи от самых холодных к самым горячим.
+func (c *InMemoryCache) snapshotEntries(now time.Time) []snapshotEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entries := make([]snapshotEntry, 0, len(c.cache))
+	c.evictor.each(func(key string) bool {
+		if ent := c.cache[key]; now.Before(ent.exp) {
+			entries = append(entries, snapshotEntry{
+				Order:   ent.value,
+				TTL:     ent.exp.Sub(now),
+				SoftTTL: ent.stale.Sub(now),
+			})
+		}
+		return true
+	})
+	slices.Reverse(entries)
+	return entries
+}
+
+// ReadSnapshot добавляет в кэш записи снимка. Оставшийся TTL отсчитывается от времени снимка,
+// поэтому записи, истёкшие за время простоя, пропускаются.
+func (c *InMemoryCache) ReadSnapshot(r io.Reader) (time.Time, error) {
+	data, err := decodeSnapshot(r)
+	if err != nil {
+		return time.Time{}, err
+	}
+	c.restoreEntries(data.CreatedAt, data.Entries)
+	return data.CreatedAt, nil
+}
+
+// restoreEntries вставляет записи снимка по порядку, сохраняя их положение в порядке вытеснения.
+func (c *InMemoryCache) restoreEntries(createdAt time.Time, entries []snapshotEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for _, e := range entries {
+		exp := createdAt.Add(e.TTL)
+		if !now.Before(exp) {
+			continue
+		}
+		stale := createdAt.Add(e.SoftTTL)
+		if e.SoftTTL == 0 || stale.After(exp) {
+			stale = exp
+		}
+		c.store(e.Order, stale, exp)
+	}
+}
+
+// Close останавливает фоновые горутины, сохраняет снимок (если включён) и очищает кэш.
 func (c *InMemoryCache) Close() error {
 	close(c.stopCh)
-	c.Clear()
-	return nil
+
+	var err error
+	if c.snapshotPath != "" {
+		err = SaveSnapshot(c, c.snapshotPath)
+	}
+	if clearErr := c.Clear(context.Background()); err == nil {
+		err = clearErr
+	}
+	return err
 }
 
-// Проверка на соответствие интерфейсу Cache.
-var _ Cache = (*In
+// Проверка на соответствие интерфейсам Cache, StaleReader, Snapshotter и Indexer.
+var (
+	_ Cache       = (*InMemoryCache)(nil)
+	_ StaleReader = (*InMemoryCache)(nil)
+	_ Snapshotter = (*InMemoryCache)(nil)
+	_ Indexer     = (*InMemoryCache)(nil)
+)
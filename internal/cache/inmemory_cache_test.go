@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"context"
 	"sync"
 	"testing"
 	"time"
@@ -12,9 +13,9 @@ import (
 
 // setupTestInMemoryCache создаёт тестовый кэш с заданной ёмкостью и TTL = 1 секунда
 func setupTestInMemoryCache(t *testing.T, capacity int) *InMemoryCache {
-	cache := NewInMemoryCache(capacity, 10)
+	cache := NewInMemoryCache(capacity, time.Second)
 	require.NotNil(t, cache)
-	err := cache.Clear()
+	err := cache.Clear(context.Background())
 	require.NoError(t, err)
 	return cache
 }
@@ -27,13 +28,14 @@ func TestNewInMemoryCache(t *testing.T) {
 }
 
 func TestInMemoryCache_SaveAndGetOrder(t *testing.T) {
+	ctx := context.Background()
 	cache := setupTestInMemoryCache(t, 100)
 
 	order := datagenerators.GenerateOrder()
-	err := cache.SaveOrder(order)
+	err := cache.SaveOrder(ctx, order)
 	require.NoError(t, err)
 
-	retrievedOrder, exists, err := cache.GetOrder(order.OrderUID)
+	retrievedOrder, exists, err := cache.GetOrder(ctx, order.OrderUID)
 	require.NoError(t, err)
 	assert.True(t, exists)
 	assert.Equal(t, order, retrievedOrder)
@@ -42,69 +44,98 @@ func TestInMemoryCache_SaveAndGetOrder(t *testing.T) {
 func TestInMemoryCache_GetOrder_NonExistent(t *testing.T) {
 	cache := setupTestInMemoryCache(t, 100)
 
-	_, exists, err := cache.GetOrder("non-existent-order-uid")
+	_, exists, err := cache.GetOrder(context.Background(), "non-existent-order-uid")
 	require.NoError(t, err)
 	assert.False(t, exists)
 }
 
 func TestInMemoryCache_OrderExists(t *testing.T) {
+	ctx := context.Background()
 	cache := setupTestInMemoryCache(t, 100)
 
 	order := datagenerators.GenerateOrder()
-	err := cache.SaveOrder(order)
+	err := cache.SaveOrder(ctx, order)
 	require.NoError(t, err)
 
-	exists, err := cache.OrderExists(order.OrderUID)
+	exists, err := cache.OrderExists(ctx, order.OrderUID)
 	require.NoError(t, err)
 	assert.True(t, exists)
 
-	exists, err = cache.OrderExists("non-existent")
+	exists, err = cache.OrderExists(ctx, "non-existent")
 	require.NoError(t, err)
 	assert.False(t, exists)
 }
 
 func TestInMemoryCache_RemoveOrder(t *testing.T) {
+	ctx := context.Background()
 	cache := setupTestInMemoryCache(t, 100)
 
 	order := datagenerators.GenerateOrder()
-	err := cache.SaveOrder(order)
+	err := cache.SaveOrder(ctx, order)
 	require.NoError(t, err)
 
-	err = cache.RemoveOrder(order.OrderUID)
+	err = cache.RemoveOrder(ctx, order.OrderUID)
 	require.NoError(t, err)
 
-	_, exists, err := cache.GetOrder(order.OrderUID)
+	_, exists, err := cache.GetOrder(ctx, order.OrderUID)
 	require.NoError(t, err)
 	assert.False(t, exists)
 }
 
 func TestInMemoryCache_Clear(t *testing.T) {
+	ctx := context.Background()
 	cache := setupTestInMemoryCache(t, 100)
 
 	order1 := datagenerators.GenerateOrder()
 	order2 := datagenerators.GenerateOrder()
-	err := cache.SaveOrder(order1)
+	err := cache.SaveOrder(ctx, order1)
 	require.NoError(t, err)
-	err = cache.SaveOrder(order2)
+	err = cache.SaveOrder(ctx, order2)
 	require.NoError(t, err)
 
-	err = cache.Clear()
+	err = cache.Clear(ctx)
 	require.NoError(t, err)
 
-	orders, err := cache.GetAllOrders()
+	orders, err := cache.GetAllOrders(ctx)
 	require.NoError(t, err)
 	assert.Empty(t, orders)
 }
 
+func TestInMemoryCache_CanceledContext(t *testing.T) {
+	cache := setupTestInMemoryCache(t, 100)
+
+	order := datagenerators.GenerateOrder()
+	require.NoError(t, cache.SaveOrder(context.Background(), order))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := cache.SaveOrder(ctx, datagenerators.GenerateOrder())
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, exists, err := cache.GetOrder(ctx, order.OrderUID)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.False(t, exists)
+
+	err = cache.RemoveOrder(ctx, order.OrderUID)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// Отменённые операции не должны менять содержимое кэша
+	_, exists, err = cache.GetOrder(context.Background(), order.OrderUID)
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
 func TestInMemoryCache_TTL_Expiry(t *testing.T) {
+	ctx := context.Background()
 	cache := NewInMemoryCache(100, 100*time.Millisecond)
 
 	order := datagenerators.GenerateOrder()
-	err := cache.SaveOrder(order)
+	err := cache.SaveOrder(ctx, order)
 	require.NoError(t, err)
 
 	// Проверяем, что запись есть
-	_, exists, err := cache.GetOrder(order.OrderUID)
+	_, exists, err := cache.GetOrder(ctx, order.OrderUID)
 	require.NoError(t, err)
 	assert.True(t, exists)
 
@@ -112,43 +143,46 @@ func TestInMemoryCache_TTL_Expiry(t *testing.T) {
 	time.Sleep(150 * time.Millisecond)
 
 	// Теперь запись должна быть удалена
-	_, exists, err = cache.GetOrder(order.OrderUID)
+	_, exists, err = cache.GetOrder(ctx, order.OrderUID)
 	require.NoError(t, err)
 	assert.False(t, exists)
 }
 
 func TestInMemoryCache_BackgroundCleanup(t *testing.T) {
+	ctx := context.Background()
 	cache := NewInMemoryCache(100, 100*time.Millisecond)
 
 	order := datagenerators.GenerateOrder()
-	err := cache.SaveOrder(order)
+	err := cache.SaveOrder(ctx, order)
 	require.NoError(t, err)
 
 	// Ждём, пока фоновая горутина удалит запись
 	time.Sleep(300 * time.Millisecond)
 
-	orders, err := cache.GetAllOrders()
+	orders, err := cache.GetAllOrders(ctx)
 	require.NoError(t, err)
 	assert.Empty(t, orders, "Фоновая очистка должна удалить просроченные записи")
 }
 
 func TestInMemoryCache_Close(t *testing.T) {
+	ctx := context.Background()
 	cache := setupTestInMemoryCache(t, 100)
 
 	order := datagenerators.GenerateOrder()
-	err := cache.SaveOrder(order)
+	err := cache.SaveOrder(ctx, order)
 	require.NoError(t, err)
 
 	err = cache.Close()
 	require.NoError(t, err)
 
 	// После Close кэш должен быть пуст
-	orders, err := cache.GetAllOrders()
+	orders, err := cache.GetAllOrders(ctx)
 	require.NoError(t, err)
 	assert.Empty(t, orders)
 }
 
 func TestInMemoryCache_ConcurrentAccess(t *testing.T) {
+	ctx := context.Background()
 	cache := setupTestInMemoryCache(t, 1000)
 	order := datagenerators.GenerateOrder()
 
@@ -163,15 +197,15 @@ func TestInMemoryCache_ConcurrentAccess(t *testing.T) {
 			defer wg.Done()
 			for j := 0; j < operations; j++ {
 				// Сохраняем
-				err := cache.SaveOrder(order)
+				err := cache.SaveOrder(ctx, order)
 				assert.NoError(t, err)
 
 				// Получаем
-				_, _, err = cache.GetOrder(order.OrderUID)
+				_, _, err = cache.GetOrder(ctx, order.OrderUID)
 				assert.NoError(t, err)
 
 				// Проверяем существование
-				_, err = cache.OrderExists(order.OrderUID)
+				_, err = cache.OrderExists(ctx, order.OrderUID)
 				assert.NoError(t, err)
 			}
 		}()
@@ -180,7 +214,135 @@ func TestInMemoryCache_ConcurrentAccess(t *testing.T) {
 	wg.Wait()
 
 	// Убедимся, что запись всё ещё на месте
-	_, exists, err := cache.GetOrder(order.OrderUID)
+	_, exists, err := cache.GetOrder(ctx, order.OrderUID)
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestInMemoryCache_ListOrders(t *testing.T) {
+	ctx := context.Background()
+	cache := setupTestInMemoryCache(t, 100)
+
+	saved := make(map[string]bool)
+	for i := 0; i < 7; i++ {
+		order := datagenerators.GenerateOrder()
+		require.NoError(t, cache.SaveOrder(ctx, order))
+		saved[order.OrderUID] = true
+	}
+
+	listed := make(map[string]bool)
+	cursor := ""
+	pages := 0
+	for {
+		orders, next, err := cache.ListOrders(ctx, cursor, 3)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(orders), 3)
+		for _, o := range orders {
+			listed[o.OrderUID] = true
+		}
+		pages++
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	assert.Equal(t, saved, listed)
+	assert.Equal(t, 3, pages)
+
+	_, _, err := cache.ListOrders(ctx, "not-a-number", 3)
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestInMemoryCache_MaxBytes(t *testing.T) {
+	ctx := context.Background()
+
+	small := datagenerators.GenerateOrder()
+	small.Items = small.Items[:1:1]
+	large := datagenerators.GenerateOrder()
+	for len(large.Items) < 5 {
+		large.Items = append(large.Items, large.Items[0])
+	}
+
+	first, second := small, small
+	first.OrderUID += "-1"
+	second.OrderUID += "-2"
+
+	// Бюджет вмещает две маленькие записи или одну большую, но не маленькую вместе с большой
+	smallSize := estimateOrderSize(first)
+	budget := max(2*smallSize, estimateOrderSize(large))
+	require.Less(t, budget, smallSize+estimateOrderSize(large))
+
+	cache := NewInMemoryCache(0, time.Minute, WithMaxBytes(budget))
+	t.Cleanup(func() { cache.Close() })
+
+	require.NoError(t, cache.SaveOrder(ctx, first))
+	require.NoError(t, cache.SaveOrder(ctx, second))
+
+	stats, err := cache.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), stats.Size)
+	assert.LessOrEqual(t, stats.MemoryBytes, budget)
+
+	// Большой заказ вытесняет обе маленькие записи (сначала самую старую)
+	require.NoError(t, cache.SaveOrder(ctx, large))
+
+	stats, err = cache.Stats(ctx)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, stats.MemoryBytes, budget)
+	assert.Equal(t, uint64(2), stats.Evictions)
+
+	exists, err := cache.OrderExists(ctx, large.OrderUID)
 	require.NoError(t, err)
 	assert.True(t, exists)
+	exists, err = cache.OrderExists(ctx, first.OrderUID)
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestInMemoryCache_SoftTTL(t *testing.T) {
+	ctx := context.Background()
+	cache := NewInMemoryCache(10, 200*time.Millisecond, WithSoftTTL(50*time.Millisecond))
+	t.Cleanup(func() { cache.Close() })
+
+	order := datagenerators.GenerateOrder()
+	require.NoError(t, cache.SaveOrder(ctx, order))
+
+	_, exists, stale, err := cache.GetOrderStale(ctx, order.OrderUID)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.False(t, stale)
+
+	// После мягкого TTL заказ отдаётся, но помечается устаревшим
+	time.Sleep(100 * time.Millisecond)
+	_, exists, stale, err = cache.GetOrderStale(ctx, order.OrderUID)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.True(t, stale)
+
+	// Перезапись обновляет оба срока
+	require.NoError(t, cache.SaveOrder(ctx, order))
+	_, _, stale, err = cache.GetOrderStale(ctx, order.OrderUID)
+	require.NoError(t, err)
+	assert.False(t, stale)
+
+	// После жёсткого TTL — промах
+	time.Sleep(250 * time.Millisecond)
+	_, exists, _, err = cache.GetOrderStale(ctx, order.OrderUID)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	stats, err := cache.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), stats.Hits)
+	assert.Equal(t, uint64(1), stats.StaleHits)
+	assert.Equal(t, uint64(1), stats.Misses)
+
+	// Мягкий TTL не меньше жёсткого игнорируется
+	plain := NewInMemoryCache(10, 50*time.Millisecond, WithSoftTTL(time.Minute))
+	t.Cleanup(func() { plain.Close() })
+	require.NoError(t, plain.SaveOrder(ctx, order))
+	_, _, stale, err = plain.GetOrderStale(ctx, order.OrderUID)
+	require.NoError(t, err)
+	assert.False(t, stale)
 }
@@ -0,0 +1,188 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/models"
+	"github.com/go-redis/redis/v8"
+)
+
+// DefaultInvalidationChannel — канал Redis pub/sub шины инвалидации по умолчанию
+const DefaultInvalidationChannel = "orders:invalidate"
+
+// Типы событий шины инвалидации
+const (
+	invalidationSave   = "save"
+	invalidationRemove = "remove"
+	invalidationClear  = "clear"
+)
+
+// invalidationEvent — событие шины инвалидации.
+// Seq — номер события в пределах узла NodeID, растёт на 1 с каждой публикацией.
+// Пакетные операции передают UID в OrderUIDs. Сами заказы по шине не передаются.
+type invalidationEvent struct {
+	NodeID    string   `json:"node_id"`
+	Seq       uint64   `json:"seq"`
+	Op        string   `json:"op"`
+	OrderUID  string   `json:"order_uid,omitempty"`
+	OrderUIDs []string `json:"order_uids,omitempty"`
+}
+
+// uids возвращает UID всех заказов события
+func (e invalidationEvent) uids() []string {
+	uids := e.OrderUIDs
+	if e.OrderUID != "" {
+		uids = append(uids[:len(uids):len(uids)], e.OrderUID)
+	}
+	return uids
+}
+
+// orderUIDs возвращает UID заказов пакета
+func orderUIDs(orders []models.Order) []string {
+	uids := make([]string, len(orders))
+	for i, order := range orders {
+		uids[i] = order.OrderUID
+	}
+	return uids
+}
+
+// InvalidationBus — шина событий изменения кэша между репликами поверх Redis pub/sub.
+// Узел публикует свои сохранения, удаления и очистки и применяет к локальному кэшу события остальных.
+// Собственные события (эхо) отбрасываются по NodeID, повторные — по Seq.
+// Pub/sub не гарантирует доставку: при пропуске в последовательности узла (например, после
+// переподключения) локальный кэш очищается, чтобы не отдавать устаревшие заказы.
+type InvalidationBus struct {
+	client  redis.UniversalClient
+	channel string
+	nodeID  string
+	apply   func(ctx context.Context, event invalidationEvent)
+
+	// Публикации выполняются по очереди, чтобы события узла приходили в порядке Seq
+	publishMu sync.Mutex
+	seq       uint64
+
+	pubsub *redis.PubSub
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	lastSeq map[string]uint64 // последний принятый номер события по узлам
+}
+
+// newInvalidationBus подписывается на канал и запускает применение чужих событий через apply
+func newInvalidationBus(client redis.UniversalClient, channel string, apply func(ctx context.Context, event invalidationEvent)) (*InvalidationBus, error) {
+	if channel == "" {
+		channel = DefaultInvalidationChannel
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+
+	pubsub := client.Subscribe(ctx, channel)
+	// Дожидаемся подтверждения подписки, чтобы не потерять события, отправленные сразу после старта
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, fmt.Errorf("failed to subscribe to invalidation channel: %w", err)
+	}
+
+	b := &InvalidationBus{
+		client:  client,
+		channel: channel,
+		nodeID:  newNodeID(),
+		apply:   apply,
+		pubsub:  pubsub,
+		lastSeq: make(map[string]uint64),
+	}
+
+	b.wg.Add(1)
+	go b.listen()
+
+	return b, nil
+}
+
+// listen применяет события остальных узлов до закрытия подписки
+func (b *InvalidationBus) listen() {
+	defer b.wg.Done()
+
+	for msg := range b.pubsub.Channel() {
+		var event invalidationEvent
+		if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+			continue
+		}
+		// Собственные события уже применены локально
+		if event.NodeID == b.nodeID {
+			continue
+		}
+
+		accept, gap := b.track(event)
+		if !accept {
+			continue
+		}
+		ctx := context.Background()
+		if gap {
+			b.apply(ctx, invalidationEvent{NodeID: event.NodeID, Op: invalidationClear})
+		}
+		b.apply(ctx, event)
+	}
+}
+
+// track запоминает номер события узла. accept = false для повторного события,
+// gap = true, если часть событий узла была пропущена.
+func (b *InvalidationBus) track(event invalidationEvent) (accept, gap bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	last, known := b.lastSeq[event.NodeID]
+	if known && event.Seq <= last {
+		return false, false
+	}
+	b.lastSeq[event.NodeID] = event.Seq
+	return true, known && event.Seq > last+1
+}
+
+// publish присваивает событию NodeID и очередной Seq и публикует его.
+// Неудачная публикация тоже расходует номер: остальные узлы увидят пропуск и очистят свой кэш.
+func (b *InvalidationBus) publish(ctx context.Context, event invalidationEvent) error {
+	b.publishMu.Lock()
+	defer b.publishMu.Unlock()
+
+	b.seq++
+	event.NodeID = b.nodeID
+	event.Seq = b.seq
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal invalidation event: %w", err)
+	}
+	if err := b.client.Publish(ctx, b.channel, payload).Err(); err != nil {
+		return fmt.Errorf("failed to publish invalidation event: %w", err)
+	}
+	return nil
+}
+
+// Close отписывается от канала и дожидается остановки обработчика событий
+func (b *InvalidationBus) Close() error {
+	err := b.pubsub.Close()
+	b.wg.Wait()
+	return err
+}
+
+// newNodeID формирует идентификатор узла, уникальный среди реплик сервиса
+func newNodeID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano())
+	}
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix))
+}
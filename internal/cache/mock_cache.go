@@ -1,6 +1,9 @@
 package cache
 
 import (
+	"context"
+	"sort"
+	"strconv"
 	"sync"
 	"time"
 
@@ -20,42 +23,102 @@ func NewMock() *MockCache {
 	}
 }
 
-func (m *MockCache) SaveOrder(order models.Order) error {
+func (m *MockCache) SaveOrder(ctx context.Context, order models.Order) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.orders[order.OrderUID] = order
 	return nil
 }
 
-func (m *MockCache) GetOrder(orderUID string) (models.Order, bool, error) {
+func (m *MockCache) GetOrder(ctx context.Context, orderUID string) (models.Order, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Order{}, false, err
+	}
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	order, exists := m.orders[orderUID]
 	return order, exists, nil
 }
 
-func (m *MockCache) OrderExists(orderUID string) (bool, error) {
+func (m *MockCache) OrderExists(ctx context.Context, orderUID string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	_, exists := m.orders[orderUID]
 	return exists, nil
 }
 
-func (m *MockCache) RemoveOrder(orderUID string) error {
+func (m *MockCache) RemoveOrder(ctx context.Context, orderUID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	delete(m.orders, orderUID)
 	return nil
 }
 
-func (m *MockCache) Clear() error {
+func (m *MockCache) SaveOrders(ctx context.Context, orders []models.Order) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, order := range orders {
+		m.orders[order.OrderUID] = order
+	}
+	return nil
+}
+
+func (m *MockCache) GetOrders(ctx context.Context, orderUIDs []string) ([]models.Order, []string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	found := make([]models.Order, 0, len(orderUIDs))
+	var missing []string
+	for _, uid := range orderUIDs {
+		if order, exists := m.orders[uid]; exists {
+			found = append(found, order)
+		} else {
+			missing = append(missing, uid)
+		}
+	}
+	return found, missing, nil
+}
+
+func (m *MockCache) RemoveOrders(ctx context.Context, orderUIDs []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, uid := range orderUIDs {
+		delete(m.orders, uid)
+	}
+	return nil
+}
+
+func (m *MockCache) Clear(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.orders = make(map[string]models.Order)
 	return nil
 }
 
-func (m *MockCache) GetAllOrders() ([]models.Order, error) {
+func (m *MockCache) GetAllOrders(ctx context.Context) ([]models.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	orders := make([]models.Order, 0, len(m.orders))
@@ -65,22 +128,88 @@ func (m *MockCache) GetAllOrders() ([]models.Order, error) {
 	return orders, nil
 }
 
+func (m *MockCache) FindOrders(ctx context.Context, field IndexField, value string) ([]models.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := field.Validate(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	orders := []models.Order{}
+	for _, order := range m.orders {
+		if value != "" && field.value(order) == value {
+			orders = append(orders, order)
+		}
+	}
+	sortIndexed(orders)
+	return orders, nil
+}
+
+func (m *MockCache) ListOrders(ctx context.Context, cursor string, limit int) ([]models.Order, string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+	offset, err := parseOffsetCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	uids := make([]string, 0, len(m.orders))
+	for uid := range m.orders {
+		uids = append(uids, uid)
+	}
+	sort.Strings(uids)
+	if offset > len(uids) {
+		offset = len(uids)
+	}
+	end := offset + limit
+	if end >= len(uids) {
+		end = len(uids)
+	}
+	orders := make([]models.Order, 0, end-offset)
+	for _, uid := range uids[offset:end] {
+		orders = append(orders, m.orders[uid])
+	}
+	if end == len(uids) {
+		return orders, "", nil
+	}
+	return orders, strconv.Itoa(end), nil
+}
+
+func (m *MockCache) Stats(ctx context.Context) (Stats, error) {
+	if err := ctx.Err(); err != nil {
+		return Stats{}, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return Stats{Backend: "mock", Size: int64(len(m.orders))}, nil
+}
+
 func (m *MockCache) Close() error {
 	return nil
 }
 
-func (m *MockCache) Set(order models.Order) error {
-	return m.SaveOrder(order)
+func (m *MockCache) Set(ctx context.Context, order models.Order) error {
+	return m.SaveOrder(ctx, order)
 }
 
-func (m *MockCache) Exists(orderUID string) (bool, error) {
-	return m.OrderExists(orderUID)
+func (m *MockCache) Exists(ctx context.Context, orderUID string) (bool, error) {
+	return m.OrderExists(ctx, orderUID)
 }
 
-func (m *MockCache) Get(orderUID string) (models.Order, bool, error) {
-	return m.GetOrder(orderUID)
+func (m *MockCache) Get(ctx context.Context, orderUID string) (models.Order, bool, error) {
+	return m.GetOrder(ctx, orderUID)
 }
 
-func (m *MockCache) SaveOrderWithTTL(order models.Order, ttl time.Duration) error {
-	return m.SaveOrder(order)
+func (m *MockCache) SaveOrderWithTTL(ctx context.Context, order models.Order, ttl time.Duration) error {
+	return m.SaveOrder(ctx, order)
 }
+
+// Проверка на соответствие интерфейсам Cache и Indexer.
+var (
+	_ Cache   = (*MockCache)(nil)
+	_ Indexer = (*MockCache)(nil)
+)
@@ -0,0 +1,336 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/models"
+)
+
+const (
+	// defaultNegativeTTL — время, на которое запоминается отсутствие заказа в БД
+	defaultNegativeTTL = 30 * time.Second
+	// negativeCapacity ограничивает количество запомненных отсутствующих UID
+	negativeCapacity = 10000
+	// fillTimeout ограничивает запись загруженного из БД заказа в кэш
+	fillTimeout = 5 * time.Second
+	// batchLoadConcurrency ограничивает число одновременных загрузок из БД при пакетном чтении
+	batchLoadConcurrency = 8
+)
+
+// OrderLoader загружает заказ из первичного хранилища при промахе кэша.
+// Если заказа нет, возвращает (nil, nil). Реализуется repository.OrdersRepo.
+type OrderLoader interface {
+	GetOrder(orderUID string) (*models.Order, error)
+}
+
+// ReadThroughCache — обёртка над Cache, которая при промахе загружает заказ
+// через OrderLoader и кладёт его в кэш.
+// Одновременные промахи по одному UID схлопываются в один запрос к БД,
+// а UID, которых нет в БД, на короткое время запоминаются как отсутствующие.
+// Если оборачиваемый кэш реализует StaleReader, устаревший заказ отдаётся сразу,
+// а в фоне перечитывается из БД (stale-while-revalidate).
+type ReadThroughCache struct {
+	Cache
+	loader   OrderLoader
+	negative *InMemoryCache // UID, отсутствие которых в БД уже подтверждено
+	group    loadGroup
+
+	loads    atomic.Uint64 // количество загрузок из БД
+	loadTime atomic.Int64  // суммарное время загрузок, нс
+}
+
+// NewReadThroughCache создаёт read-through обёртку над кэшем.
+// negativeTTL — время жизни отрицательной записи; если 0, используется значение по умолчанию.
+func NewReadThroughCache(inner Cache, loader OrderLoader, negativeTTL time.Duration) *ReadThroughCache {
+	if negativeTTL <= 0 {
+		negativeTTL = defaultNegativeTTL
+	}
+	return &ReadThroughCache{
+		Cache:    inner,
+		loader:   loader,
+		negative: NewInMemoryCache(negativeCapacity, negativeTTL),
+		group:    loadGroup{calls: make(map[string]*loadCall)},
+	}
+}
+
+// GetOrder возвращает заказ из кэша, а при промахе — из БД с заполнением кэша
+func (c *ReadThroughCache) GetOrder(ctx context.Context, orderUID string) (models.Order, bool, error) {
+	order, ok, _, err := c.GetOrderStale(ctx, orderUID)
+	return order, ok, err
+}
+
+// GetOrderStale работает как GetOrder, но дополнительно сообщает, что отдан устаревший заказ.
+// Для устаревшего заказа запускается фоновое обновление из БД; пока оно не завершится
+// (или если БД недоступна), до жёсткого TTL отдаётся прежняя версия.
+func (c *ReadThroughCache) GetOrderStale(ctx context.Context, orderUID string) (models.Order, bool, bool, error) {
+	order, ok, stale, err := c.lookup(ctx, orderUID)
+	if err != nil {
+		return models.Order{}, false, false, err
+	}
+	if ok {
+		if stale {
+			c.refresh(orderUID)
+		}
+		return order, true, stale, nil
+	}
+
+	missing, err := c.negative.OrderExists(ctx, orderUID)
+	if err != nil || missing {
+		return models.Order{}, false, false, err
+	}
+
+	order, ok, err = c.group.do(ctx, orderUID, func() (models.Order, bool, error) {
+		return c.load(orderUID)
+	})
+	return order, ok, false, err
+}
+
+// lookup читает заказ из оборачиваемого кэша; признак stale доступен, если кэш реализует StaleReader
+func (c *ReadThroughCache) lookup(ctx context.Context, orderUID string) (models.Order, bool, bool, error) {
+	if reader, ok := c.Cache.(StaleReader); ok {
+		return reader.GetOrderStale(ctx, orderUID)
+	}
+	order, ok, err := c.Cache.GetOrder(ctx, orderUID)
+	return order, ok, false, err
+}
+
+// refresh в фоне перечитывает устаревший заказ из БД, не дожидаясь результата.
+// Обновление схлопывается с другими загрузками того же UID.
+// Если заказа в БД больше нет, устаревшая запись удаляется из кэша.
+func (c *ReadThroughCache) refresh(orderUID string) {
+	c.group.start(orderUID, func() (models.Order, bool, error) {
+		order, found, err := c.load(orderUID)
+		if err == nil && !found {
+			ctx, cancel := context.WithTimeout(context.Background(), fillTimeout)
+			defer cancel()
+			_ = c.Cache.RemoveOrder(ctx, orderUID)
+		}
+		return order, found, err
+	})
+}
+
+// load читает заказ из БД и заполняет кэш (или отрицательный кэш).
+// Выполняется вне контекста конкретного запроса, т.к. его результат ждут все схлопнутые вызовы.
+func (c *ReadThroughCache) load(orderUID string) (models.Order, bool, error) {
+	start := time.Now()
+	loaded, err := c.loader.GetOrder(orderUID)
+	c.loads.Add(1)
+	c.loadTime.Add(int64(time.Since(start)))
+	if err != nil {
+		return models.Order{}, false, fmt.Errorf("failed to load order from DB: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), fillTimeout)
+	defer cancel()
+
+	if loaded == nil {
+		_ = c.negative.SaveOrder(ctx, models.Order{OrderUID: orderUID})
+		return models.Order{}, false, nil
+	}
+
+	if err := FillOrders(ctx, c.Cache, []models.Order{*loaded}); err != nil {
+		return models.Order{}, false, fmt.Errorf("failed to fill cache: %w", err)
+	}
+	return *loaded, true, nil
+}
+
+// GetOrders возвращает заказы из кэша, а отсутствующие загружает из БД
+// (не более batchLoadConcurrency одновременно) с заполнением кэша
+func (c *ReadThroughCache) GetOrders(ctx context.Context, orderUIDs []string) ([]models.Order, []string, error) {
+	found, missing, err := c.Cache.GetOrders(ctx, orderUIDs)
+	if err != nil || len(missing) == 0 {
+		return found, missing, err
+	}
+
+	toLoad := make([]string, 0, len(missing))
+	for _, uid := range missing {
+		known, err := c.negative.OrderExists(ctx, uid)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !known {
+			toLoad = append(toLoad, uid)
+		}
+	}
+	loaded, err := c.loadAll(ctx, toLoad)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(loaded) == 0 {
+		return found, missing, nil
+	}
+
+	for _, order := range found {
+		loaded[order.OrderUID] = order
+	}
+	found = make([]models.Order, 0, len(loaded))
+	missing = missing[:0]
+	for _, uid := range orderUIDs {
+		if order, ok := loaded[uid]; ok {
+			found = append(found, order)
+		} else {
+			missing = append(missing, uid)
+		}
+	}
+	return found, missing, nil
+}
+
+// loadAll загружает заказы из БД через loadGroup и возвращает найденные по UID
+func (c *ReadThroughCache) loadAll(ctx context.Context, orderUIDs []string) (map[string]models.Order, error) {
+	type result struct {
+		order models.Order
+		found bool
+		err   error
+	}
+	results := make([]result, len(orderUIDs))
+
+	sem := make(chan struct{}, batchLoadConcurrency)
+	var wg sync.WaitGroup
+	for i, uid := range orderUIDs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			order, found, err := c.group.do(ctx, uid, func() (models.Order, bool, error) {
+				return c.load(uid)
+			})
+			results[i] = result{order: order, found: found, err: err}
+		}()
+	}
+	wg.Wait()
+
+	loaded := make(map[string]models.Order, len(orderUIDs))
+	for _, r := range results {
+		if r.err != nil {
+			return nil, r.err
+		}
+		if r.found {
+			loaded[r.order.OrderUID] = r.order
+		}
+	}
+	return loaded, nil
+}
+
+// SaveOrder сохраняет заказ и снимает отметку об его отсутствии
+func (c *ReadThroughCache) SaveOrder(ctx context.Context, order models.Order) error {
+	if err := c.Cache.SaveOrder(ctx, order); err != nil {
+		return err
+	}
+	return c.negative.RemoveOrder(ctx, order.OrderUID)
+}
+
+// SaveOrders сохраняет заказы пакетом и снимает отметки об их отсутствии
+func (c *ReadThroughCache) SaveOrders(ctx context.Context, orders []models.Order) error {
+	if err := c.Cache.SaveOrders(ctx, orders); err != nil {
+		return err
+	}
+	return c.negative.RemoveOrders(ctx, orderUIDs(orders))
+}
+
+// FillOrders заполняет оборачиваемый кэш заказами из БД и снимает отметки об их отсутствии
+func (c *ReadThroughCache) FillOrders(ctx context.Context, orders []models.Order) error {
+	if err := FillOrders(ctx, c.Cache, orders); err != nil {
+		return err
+	}
+	return c.negative.RemoveOrders(ctx, orderUIDs(orders))
+}
+
+// FindOrders ищет заказы по вторичному индексу оборачиваемого кэша.
+// В БД поиск не идёт: результат содержит только закэшированные заказы.
+func (c *ReadThroughCache) FindOrders(ctx context.Context, field IndexField, value string) ([]models.Order, error) {
+	indexer, ok := c.Cache.(Indexer)
+	if !ok {
+		return nil, ErrIndexNotSupported
+	}
+	return indexer.FindOrders(ctx, field, value)
+}
+
+// Clear очищает кэш вместе с отрицательными записями
+func (c *ReadThroughCache) Clear(ctx context.Context) error {
+	if err := c.Cache.Clear(ctx); err != nil {
+		return err
+	}
+	return c.negative.Clear(ctx)
+}
+
+// Stats дополняет статистику оборачиваемого кэша количеством и временем загрузок из БД
+func (c *ReadThroughCache) Stats(ctx context.Context) (Stats, error) {
+	stats, err := c.Cache.Stats(ctx)
+	if err != nil {
+		return Stats{}, err
+	}
+	stats.Loads = c.loads.Load()
+	stats.LoadTime = time.Duration(c.loadTime.Load())
+	return stats, nil
+}
+
+// Close закрывает отрицательный кэш и оборачиваемый кэш
+func (c *ReadThroughCache) Close() error {
+	_ = c.negative.Close()
+	return c.Cache.Close()
+}
+
+// loadCall — выполняющаяся загрузка одного UID
+type loadCall struct {
+	done  chan struct{}
+	order models.Order
+	found bool
+	err   error
+}
+
+// loadGroup схлопывает одновременные загрузки одного ключа в одну (аналог singleflight).
+type loadGroup struct {
+	mu    sync.Mutex
+	calls map[string]*loadCall
+}
+
+// do выполняет fn один раз на ключ среди одновременных вызовов.
+// Каждый вызывающий ждёт результат, пока не истечёт его собственный контекст.
+func (g *loadGroup) do(ctx context.Context, key string, fn func() (models.Order, bool, error)) (models.Order, bool, error) {
+	call := g.start(key, fn)
+
+	select {
+	case <-call.done:
+		return call.order, call.found, call.err
+	case <-ctx.Done():
+		return models.Order{}, false, ctx.Err()
+	}
+}
+
+// start запускает fn в фоне, если загрузка ключа ещё не выполняется, и возвращает текущую загрузку
+func (g *loadGroup) start(key string, fn func() (models.Order, bool, error)) *loadCall {
+	g.mu.Lock()
+	call, inFlight := g.calls[key]
+	if !inFlight {
+		call = &loadCall{done: make(chan struct{})}
+		g.calls[key] = call
+	}
+	g.mu.Unlock()
+
+	if !inFlight {
+		go func() {
+			call.order, call.found, call.err = fn()
+
+			g.mu.Lock()
+			delete(g.calls, key)
+			g.mu.Unlock()
+			close(call.done)
+		}()
+	}
+	return call
+}
+
+// Проверка на соответствие интерфейсам Cache, Filler, StaleReader и Indexer.
+var (
+	_ Cache       = (*ReadThroughCache)(nil)
+	_ Filler      = (*ReadThroughCache)(nil)
+	_ StaleReader = (*ReadThroughCache)(nil)
+	_ Indexer     = (*ReadThroughCache)(nil)
+)
@@ -0,0 +1,200 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/datagenerators"
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubLoader — загрузчик заказов из "БД" для тестов
+type stubLoader struct {
+	mu      sync.Mutex
+	orders  map[string]models.Order
+	calls   atomic.Int32
+	release chan struct{} // если задан, загрузка ждёт закрытия канала
+	err     error
+}
+
+func newStubLoader(orders ...models.Order) *stubLoader {
+	l := &stubLoader{orders: make(map[string]models.Order)}
+	for _, o := range orders {
+		l.orders[o.OrderUID] = o
+	}
+	return l
+}
+
+func (l *stubLoader) GetOrder(orderUID string) (*models.Order, error) {
+	l.calls.Add(1)
+	if l.release != nil {
+		<-l.release
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return nil, l.err
+	}
+	order, ok := l.orders[orderUID]
+	if !ok {
+		return nil, nil
+	}
+	return &order, nil
+}
+
+func TestReadThroughCache_LoadsOnMiss(t *testing.T) {
+	ctx := context.Background()
+	order := datagenerators.GenerateOrder()
+	inner := NewMock()
+	cache := NewReadThroughCache(inner, newStubLoader(order), time.Minute)
+	defer cache.Close()
+
+	retrieved, exists, err := cache.GetOrder(ctx, order.OrderUID)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, order.OrderUID, retrieved.OrderUID)
+
+	// Заказ должен оказаться в оборачиваемом кэше
+	_, cached, err := inner.GetOrder(ctx, order.OrderUID)
+	require.NoError(t, err)
+	assert.True(t, cached)
+}
+
+func TestReadThroughCache_CollapsesConcurrentMisses(t *testing.T) {
+	ctx := context.Background()
+	order := datagenerators.GenerateOrder()
+	loader := newStubLoader(order)
+	loader.release = make(chan struct{})
+	cache := NewReadThroughCache(NewMock(), loader, time.Minute)
+	defer cache.Close()
+
+	const goroutines = 20
+	var wg sync.WaitGroup
+	wg.Add(goroutines)
+	for i := 0; i < goroutines; i++ {
+		go func() {
+			defer wg.Done()
+			_, exists, err := cache.GetOrder(ctx, order.OrderUID)
+			assert.NoError(t, err)
+			assert.True(t, exists)
+		}()
+	}
+
+	// Даём горутинам дойти до ожидания загрузки
+	assert.Eventually(t, func() bool { return loader.calls.Load() == 1 }, time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	close(loader.release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), loader.calls.Load())
+}
+
+func TestReadThroughCache_NegativeCaching(t *testing.T) {
+	ctx := context.Background()
+	loader := newStubLoader()
+	cache := NewReadThroughCache(NewMock(), loader, time.Minute)
+	defer cache.Close()
+
+	for i := 0; i < 3; i++ {
+		_, exists, err := cache.GetOrder(ctx, "missing-uid")
+		require.NoError(t, err)
+		assert.False(t, exists)
+	}
+	assert.Equal(t, int32(1), loader.calls.Load(), "отсутствующий UID должен запрашиваться из БД один раз")
+
+	// Сохранение заказа снимает отрицательную отметку
+	order := datagenerators.GenerateOrder()
+	order.OrderUID = "missing-uid"
+	require.NoError(t, cache.SaveOrder(ctx, order))
+
+	_, exists, err := cache.GetOrder(ctx, "missing-uid")
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestReadThroughCache_NegativeEntryExpires(t *testing.T) {
+	ctx := context.Background()
+	loader := newStubLoader()
+	cache := NewReadThroughCache(NewMock(), loader, 50*time.Millisecond)
+	defer cache.Close()
+
+	_, _, err := cache.GetOrder(ctx, "missing-uid")
+	require.NoError(t, err)
+
+	time.Sleep(100 * time.Millisecond)
+
+	_, _, err = cache.GetOrder(ctx, "missing-uid")
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), loader.calls.Load())
+}
+
+func TestReadThroughCache_LoaderError(t *testing.T) {
+	loader := newStubLoader()
+	loader.err = errors.New("connection refused")
+	cache := NewReadThroughCache(NewMock(), loader, time.Minute)
+	defer cache.Close()
+
+	_, exists, err := cache.GetOrder(context.Background(), "any-uid")
+	assert.Error(t, err)
+	assert.False(t, exists)
+}
+
+func TestReadThroughCache_StaleWhileRevalidate(t *testing.T) {
+	ctx := context.Background()
+	order := datagenerators.GenerateOrder()
+	loader := newStubLoader(order)
+	inner := NewInMemoryCache(10, time.Minute, WithSoftTTL(30*time.Millisecond))
+	cache := NewReadThroughCache(inner, loader, time.Minute)
+	defer cache.Close()
+
+	stale := order
+	stale.TrackNumber = "OLD"
+	require.NoError(t, cache.SaveOrder(ctx, stale))
+	time.Sleep(50 * time.Millisecond)
+
+	// БД недоступна: устаревший заказ всё равно отдаётся
+	loader.mu.Lock()
+	loader.err = errors.New("connection refused")
+	loader.mu.Unlock()
+	retrieved, exists, isStale, err := cache.GetOrderStale(ctx, order.OrderUID)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.True(t, isStale)
+	assert.Equal(t, "OLD", retrieved.TrackNumber)
+	assert.Eventually(t, func() bool { return loader.calls.Load() == 1 }, time.Second, time.Millisecond)
+
+	// После восстановления БД фоновое обновление подменяет заказ свежей версией
+	loader.mu.Lock()
+	loader.err = nil
+	loader.mu.Unlock()
+	assert.Eventually(t, func() bool {
+		retrieved, _, isStale, err := cache.GetOrderStale(ctx, order.OrderUID)
+		return err == nil && !isStale && retrieved.TrackNumber == order.TrackNumber
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestReadThroughCache_StaleRemovedFromDB(t *testing.T) {
+	ctx := context.Background()
+	inner := NewInMemoryCache(10, time.Minute, WithSoftTTL(10*time.Millisecond))
+	cache := NewReadThroughCache(inner, newStubLoader(), time.Minute)
+	defer cache.Close()
+
+	require.NoError(t, cache.SaveOrder(ctx, models.Order{OrderUID: "deleted"}))
+	time.Sleep(20 * time.Millisecond)
+
+	_, exists, err := cache.GetOrder(ctx, "deleted")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	assert.Eventually(t, func() bool {
+		exists, err := inner.OrderExists(ctx, "deleted")
+		return err == nil && !exists
+	}, time.Second, 5*time.Millisecond)
+}
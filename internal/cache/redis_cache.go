@@ -2,87 +2,223 @@ package cache
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/models"
 	"github.com/go-redis/redis/v8"
 )
 
+// pingTimeout ограничивает время проверки подключения к Redis при создании кэша
+const pingTimeout = 5 * time.Second
+
+const (
+	// allOrdersPageSize — размер страницы SCAN при выгрузке всех заказов
+	allOrdersPageSize = 500
+	// mgetBatchSize — максимальное количество ключей в одной команде MGET
+	mgetBatchSize = 100
+	// pipelineBatchSize — максимальное количество команд в одном пайплайне пакетной записи и удаления
+	pipelineBatchSize = 500
+)
+
 type RedisCache struct {
-	client *redis.Client
-	ctx    context.Context
+	client redis.UniversalClient // одиночный узел, sentinel или cluster
+	opts   options               // TTL, политика TTL по заказу и кодек
+
+	// Текущее поколение ключей (см. Rebuild); периодически перечитывается из Redis,
+	// у представления перестраиваемого поколения закреплено.
+	// building — поколение, которое сейчас перестраивается (0 — перестройки нет)
+	generation atomic.Uint64
+	building   atomic.Uint64
+	pinned     bool
+	stopCh     chan struct{}
+
+	// Паузы Rebuild: опрос поколения другими узлами и задержка удаления старого поколения
+	refreshInterval time.Duration
+	gracePeriod     time.Duration
+
+	// Попадания и промахи GetOrder этого экземпляра
+	hits   atomic.Uint64
+	misses atomic.Uint64
 }
 
-// NewRedisCache создает новый Redis кэш
-func NewRedisCache(redisAddr string, password string, db int) (*RedisCache, error) {
-	client := redis.NewClient(&redis.Options{
+// NewRedisCache создает новый Redis кэш поверх одиночного узла.
+// По умолчанию записи живут 24 часа; opts позволяют задать TTL и политику TTL по заказу.
+func NewRedisCache(redisAddr string, password string, db int, opts ...Option) (*RedisCache, error) {
+	return NewRedisCacheFromConfig(RedisConfig{
+		Mode:     RedisModeStandalone,
 		Addr:     redisAddr,
 		Password: password,
 		DB:       db,
-	})
+	}, opts...)
+}
 
-	ctx := context.Background()
+// NewRedisCacheFromConfig создает Redis кэш в режиме standalone, sentinel или cluster
+func NewRedisCacheFromConfig(cfg RedisConfig, opts ...Option) (*RedisCache, error) {
+	client, err := newRedisClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
 
 	// Проверяем подключение к Redis
-	_, err := client.Ping(ctx).Result()
+	_, err = client.Ping(ctx).Result()
 	if err != nil {
+		_ = client.Close()
 		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
-	return &RedisCache{
+	c := &RedisCache{
 		client: client,
-		ctx:    ctx,
-	}, nil
+		opts:   applyOptions(options{ttl: defaultRedisTTL, codec: DefaultCodec()}, opts),
+		stopCh: make(chan struct{}),
+
+		refreshInterval: generationRefreshInterval,
+		gracePeriod:     generationGracePeriod,
+	}
+	if err := c.refreshGeneration(ctx); err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	go c.watchGeneration()
+
+	return c, nil
 }
 
-// SaveOrder сохраняет заказ в Redis (использует TTL по умолчанию 24 часа)
-func (c *RedisCache) SaveOrder(order models.Order) error {
-	return c.saveOrderWithTTL(order, 24*time.Hour)
+// SaveOrder сохраняет заказ в Redis с TTL, вычисленным по настройкам и политике кэша
+func (c *RedisCache) SaveOrder(ctx context.Context, order models.Order) error {
+	return c.saveOrderWithTTL(ctx, order, c.opts.ttlFor(order))
 }
 
-// saveOrderWithTTL сохраняет заказ в Redis с указанным TTL
-func (c *RedisCache) saveOrderWithTTL(order models.Order, ttl time.Duration) error {
-	orderJSON, err := json.Marshal(order)
+// saveOrderWithTTL сохраняет заказ в Redis с указанным TTL и добавляет его во вторичные индексы
+func (c *RedisCache) saveOrderWithTTL(ctx context.Context, order models.Order, ttl time.Duration) error {
+	data, err := c.opts.codec.Encode(order)
 	if err != nil {
-		return fmt.Errorf("failed to marshal order: %w", err)
+		return err
 	}
 
-	key := c.getOrderKey(order.OrderUID)
-	err = c.client.Set(c.ctx, key, orderJSON, ttl).Err()
-	if err != nil {
+	pipe := c.client.Pipeline()
+	for _, ks := range c.writeKeyspaces() {
+		pipe.Set(ctx, ks.order(order.OrderUID), data, ttl)
+		c.indexOrder(ctx, pipe, ks, order, ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
 		return fmt.Errorf("failed to save order to Redis: %w", err)
 	}
 
 	return nil
 }
 
+// indexOrder добавляет в пайплайн запись заказа в множества вторичных индексов.
+// Множество живёт не меньше базового TTL и продлевается при каждой записи, поэтому
+// не переживает свои заказы надолго. Устаревшие UID (удалённые, истёкшие или
+// сменившие значение поля) вычищаются при чтении в FindOrders.
+func (c *RedisCache) indexOrder(ctx context.Context, pipe redis.Pipeliner, ks keyspace, order models.Order, ttl time.Duration) {
+	indexTTL := max(ttl, c.opts.ttl)
+	for _, field := range indexFields {
+		value := field.value(order)
+		if value == "" {
+			continue
+		}
+		key := ks.index(field, value)
+		pipe.SAdd(ctx, key, order.OrderUID)
+		if indexTTL > 0 {
+			pipe.Expire(ctx, key, indexTTL)
+		}
+	}
+}
+
+// SaveOrders сохраняет заказы пайплайнами SET с TTL (MSET не умеет задавать TTL).
+// В кластере пайплайн сам раскладывает команды по узлам. При ошибке часть заказов может быть уже сохранена.
+func (c *RedisCache) SaveOrders(ctx context.Context, orders []models.Order) error {
+	keyspaces := c.writeKeyspaces()
+	for start := 0; start < len(orders); start += pipelineBatchSize {
+		end := min(start+pipelineBatchSize, len(orders))
+
+		pipe := c.client.Pipeline()
+		for _, order := range orders[start:end] {
+			data, err := c.opts.codec.Encode(order)
+			if err != nil {
+				return err
+			}
+			ttl := c.opts.ttlFor(order)
+			for _, ks := range keyspaces {
+				pipe.Set(ctx, ks.order(order.OrderUID), data, ttl)
+				c.indexOrder(ctx, pipe, ks, order, ttl)
+			}
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return fmt.Errorf("failed to save orders to Redis: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetOrders читает заказы одним пайплайном (MGET, в кластере — GET по ключу)
+func (c *RedisCache) GetOrders(ctx context.Context, orderUIDs []string) ([]models.Order, []string, error) {
+	if len(orderUIDs) == 0 {
+		return []models.Order{}, nil, nil
+	}
+
+	ks := c.keyspace()
+	keys := make([]string, len(orderUIDs))
+	for i, uid := range orderUIDs {
+		keys[i] = ks.order(uid)
+	}
+	values, err := c.readValues(ctx, keys)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get orders from Redis: %w", err)
+	}
+
+	found := make([]models.Order, 0, len(orderUIDs))
+	var missing []string
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			c.misses.Add(1)
+			missing = append(missing, orderUIDs[i])
+			continue
+		}
+		c.hits.Add(1)
+
+		order, err := c.opts.codec.Decode([]byte(data))
+		if err != nil {
+			return nil, nil, err
+		}
+		found = append(found, order)
+	}
+	return found, missing, nil
+}
+
 // GetOrder получает заказ из Redis по UID
-func (c *RedisCache) GetOrder(orderUID string) (models.Order, bool, error) {
+func (c *RedisCache) GetOrder(ctx context.Context, orderUID string) (models.Order, bool, error) {
 	key := c.getOrderKey(orderUID)
-	orderJSON, err := c.client.Get(c.ctx, key).Result()
+	data, err := c.client.Get(ctx, key).Bytes()
 
 	if err == redis.Nil {
+		c.misses.Add(1)
 		return models.Order{}, false, nil
 	}
 	if err != nil {
 		return models.Order{}, false, fmt.Errorf("failed to get order from Redis: %w", err)
 	}
+	c.hits.Add(1)
 
-	var order models.Order
-	err = json.Unmarshal([]byte(orderJSON), &order)
+	order, err := c.opts.codec.Decode(data)
 	if err != nil {
-		return models.Order{}, false, fmt.Errorf("failed to unmarshal order: %w", err)
+		return models.Order{}, false, err
 	}
 
 	return order, true, nil
 }
 
 // OrderExists проверяет существование заказа в Redis
-func (c *RedisCache) OrderExists(orderUID string) (bool, error) {
+func (c *RedisCache) OrderExists(ctx context.Context, orderUID string) (bool, error) {
 	key := c.getOrderKey(orderUID)
-	exists, err := c.client.Exists(c.ctx, key).Result()
+	exists, err := c.client.Exists(ctx, key).Result()
 	if err != nil {
 		return false, fmt.Errorf("failed to check order existence: %w", err)
 	}
@@ -91,70 +227,320 @@ func (c *RedisCache) OrderExists(orderUID string) (bool, error) {
 }
 
 // RemoveOrder удаляет заказ из Redis по UID
-func (c *RedisCache) RemoveOrder(orderUID string) error {
-	key := c.getOrderKey(orderUID)
-	err := c.client.Del(c.ctx, key).Err()
+func (c *RedisCache) RemoveOrder(ctx context.Context, orderUID string) error {
+	keyspaces := c.writeKeyspaces()
+	if len(keyspaces) > 1 {
+		return c.RemoveOrders(ctx, []string{orderUID})
+	}
+	err := c.client.Del(ctx, keyspaces[0].order(orderUID)).Err()
 	if err != nil {
 		return fmt.Errorf("failed to remove order from Redis: %w", err)
 	}
 	return nil
 }
 
-// Clear очищает все ключи заказов из Redis
-func (c *RedisCache) Clear() error {
-	pattern := c.getOrderKey("*")
-	iter := c.client.Scan(c.ctx, 0, pattern, 0).Iterator()
+// RemoveOrders удаляет заказы пайплайнами DEL по одному ключу, чтобы не упираться в CROSSSLOT в кластере
+func (c *RedisCache) RemoveOrders(ctx context.Context, orderUIDs []string) error {
+	keyspaces := c.writeKeyspaces()
+	for start := 0; start < len(orderUIDs); start += pipelineBatchSize {
+		end := min(start+pipelineBatchSize, len(orderUIDs))
 
-	for iter.Next(c.ctx) {
-		err := c.client.Del(c.ctx, iter.Val()).Err()
-		if err != nil {
-			return fmt.Errorf("failed to delete key %s: %w", iter.Val(), err)
+		pipe := c.client.Pipeline()
+		for _, uid := range orderUIDs[start:end] {
+			for _, ks := range keyspaces {
+				pipe.Del(ctx, ks.order(uid))
+			}
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return fmt.Errorf("failed to remove orders from Redis: %w", err)
 		}
 	}
+	return nil
+}
 
-	if err := iter.Err(); err != nil {
-		return fmt.Errorf("failed to scan keys: %w", err)
+// Clear удаляет заказы и вторичные индексы текущего поколения (в кластере — на каждом мастере).
+// Идущую перестройку очистка не прерывает: новое поколение заполняется из БД и заменит очищенное.
+func (c *RedisCache) Clear(ctx context.Context) error {
+	return c.deleteKeys(ctx, c.keyspace())
+}
+
+// deleteKeys удаляет ключи заказов и индексов поколения, обходя их SCAN на каждом мастере
+func (c *RedisCache) deleteKeys(ctx context.Context, ks keyspace) error {
+	nodes, err := c.scanNodes(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, pattern := range []string{ks.orderPattern(), ks.indexPattern()} {
+		for _, node := range nodes {
+			iter := node.Scan(ctx, 0, pattern, 0).Iterator()
+
+			for iter.Next(ctx) {
+				if !ks.owns(iter.Val()) {
+					continue
+				}
+				err := c.client.Del(ctx, iter.Val()).Err()
+				if err != nil {
+					return fmt.Errorf("failed to delete key %s: %w", iter.Val(), err)
+				}
+			}
+
+			if err := iter.Err(); err != nil {
+				return fmt.Errorf("failed to scan keys: %w", err)
+			}
+		}
 	}
 
 	return nil
 }
 
-// GetAllOrders возвращает список всех заказов из Redis
-func (c *RedisCache) GetAllOrders() ([]models.Order, error) {
-	pattern := c.getOrderKey("*")
-	keys, err := c.client.Keys(c.ctx, pattern).Result()
+// FindOrders ищет заказы по множеству вторичного индекса.
+// UID, заказы которых уже удалены, истекли или сменили значение поля, удаляются из множества.
+func (c *RedisCache) FindOrders(ctx context.Context, field IndexField, value string) ([]models.Order, error) {
+	if err := field.Validate(); err != nil {
+		return nil, err
+	}
+
+	ks := c.keyspace()
+	indexKey := ks.index(field, value)
+	uids, err := c.client.SMembers(ctx, indexKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index from Redis: %w", err)
+	}
+	if len(uids) == 0 {
+		return []models.Order{}, nil
+	}
+
+	keys := make([]string, len(uids))
+	for i, uid := range uids {
+		keys[i] = ks.order(uid)
+	}
+	values, err := c.readValues(ctx, keys)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get keys: %w", err)
+		return nil, fmt.Errorf("failed to get orders from Redis: %w", err)
 	}
 
+	orders := make([]models.Order, 0, len(uids))
+	var stale []interface{}
+	for i, v := range values {
+		data, ok := v.(string)
+		if !ok {
+			stale = append(stale, uids[i])
+			continue
+		}
+		order, err := c.opts.codec.Decode([]byte(data))
+		if err != nil {
+			return nil, err
+		}
+		if field.value(order) != value {
+			stale = append(stale, uids[i])
+			continue
+		}
+		orders = append(orders, order)
+	}
+
+	if len(stale) > 0 {
+		// Очистка индекса не влияет на результат: при ошибке повторится при следующем поиске
+		_ = c.client.SRem(ctx, indexKey, stale...).Err()
+	}
+	sortIndexed(orders)
+	return orders, nil
+}
+
+// GetAllOrders возвращает список всех заказов из Redis.
+// Ключи перебираются постранично через SCAN, чтобы не блокировать Redis командой KEYS.
+func (c *RedisCache) GetAllOrders(ctx context.Context) ([]models.Order, error) {
 	var orders []models.Order
-	for _, key := range keys {
-		orderJSON, err := c.client.Get(c.ctx, key).Result()
+	cursor := ""
+	for {
+		page, next, err := c.ListOrders(ctx, cursor, allOrdersPageSize)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get order %s: %w", key, err)
+			return nil, err
+		}
+		orders = append(orders, page...)
+		if next == "" {
+			return orders, nil
 		}
+		cursor = next
+	}
+}
 
-		var order models.Order
-		err = json.Unmarshal([]byte(orderJSON), &order)
+// ListOrders возвращает страницу заказов, начиная с курсора SCAN.
+// SCAN гарантирует лишь примерный размер страницы и может повторно вернуть
+// ключ, если keyspace менялся во время обхода. В кластере мастера обходятся по очереди.
+func (c *RedisCache) ListOrders(ctx context.Context, cursor string, limit int) ([]models.Order, string, error) {
+	if limit <= 0 {
+		return nil, "", fmt.Errorf("limit must be > 0")
+	}
+
+	node, scanCursor, err := parseScanCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	nodes, err := c.scanNodes(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	if node >= len(nodes) {
+		return nil, "", ErrInvalidCursor
+	}
+
+	ks := c.keyspace()
+	keys := make([]string, 0, limit)
+	for node < len(nodes) && len(keys) < limit {
+		batch, next, err := nodes[node].Scan(ctx, scanCursor, ks.orderPattern(), int64(limit-len(keys))).Result()
 		if err != nil {
-			return nil, fmt.Errorf("failed to unmarshal order %s: %w", key, err)
+			return nil, "", fmt.Errorf("failed to scan keys: %w", err)
+		}
+		for _, key := range batch {
+			if ks.owns(key) {
+				keys = append(keys, key)
+			}
+		}
+		scanCursor = next
+		if scanCursor == 0 {
+			// Узел обойдён полностью — переходим к следующему мастеру
+			node++
+		}
+	}
+
+	orders, err := c.getOrders(ctx, keys)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if node >= len(nodes) {
+		return orders, "", nil
+	}
+	return orders, formatScanCursor(node, scanCursor, len(nodes)), nil
+}
+
+// getOrders читает заказы по ключам пачками MGET, отправленными одним пайплайном.
+// В кластере ключи лежат в разных слотах, поэтому вместо MGET в пайплайн идут отдельные GET.
+// Ключи, истёкшие между SCAN и чтением, пропускаются.
+func (c *RedisCache) getOrders(ctx context.Context, keys []string) ([]models.Order, error) {
+	if len(keys) == 0 {
+		return []models.Order{}, nil
+	}
+
+	values, err := c.readValues(ctx, keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get orders: %w", err)
+	}
+
+	orders := make([]models.Order, 0, len(keys))
+	for _, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
 		}
 
+		order, err := c.opts.codec.Decode([]byte(data))
+		if err != nil {
+			return nil, err
+		}
 		orders = append(orders, order)
 	}
 
 	return orders, nil
 }
 
-// Close закрывает соединение с Redis
+// readValues читает значения ключей одним пайплайном; отсутствующие ключи дают nil
+func (c *RedisCache) readValues(ctx context.Context, keys []string) ([]interface{}, error) {
+	pipe := c.client.Pipeline()
+
+	if c.isCluster() {
+		cmds := make([]*redis.StringCmd, len(keys))
+		for i, key := range keys {
+			cmds[i] = pipe.Get(ctx, key)
+		}
+		if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+			return nil, err
+		}
+
+		values := make([]interface{}, len(keys))
+		for i, cmd := range cmds {
+			if val, err := cmd.Result(); err == nil {
+				values[i] = val
+			}
+		}
+		return values, nil
+	}
+
+	cmds := make([]*redis.SliceCmd, 0, len(keys)/mgetBatchSize+1)
+	for start := 0; start < len(keys); start += mgetBatchSize {
+		end := start + mgetBatchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		cmds = append(cmds, pipe.MGet(ctx, keys[start:end]...))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	values := make([]interface{}, 0, len(keys))
+	for _, cmd := range cmds {
+		values = append(values, cmd.Val()...)
+	}
+	return values, nil
+}
+
+// Stats возвращает статистику кэша и текущее поколение ключей.
+// Попадания и промахи считаются этим экземпляром; размер, память, вытеснения и истечения TTL
+// берутся из DBSIZE и INFO сервера (в кластере — суммой по мастерам), поэтому
+// предполагается, что база Redis отведена под кэш заказов. Размер включает и множества вторичных индексов.
+func (c *RedisCache) Stats(ctx context.Context) (Stats, error) {
+	stats := Stats{
+		Backend:    string(CacheTypeRedis),
+		Hits:       c.hits.Load(),
+		Misses:     c.misses.Load(),
+		Generation: c.generation.Load(),
+	}
+
+	nodes, err := c.scanNodes(ctx)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	for _, node := range nodes {
+		size, err := node.DBSize(ctx).Result()
+		if err != nil {
+			return Stats{}, fmt.Errorf("failed to get Redis DB size: %w", err)
+		}
+		info, err := node.Info(ctx).Result()
+		if err != nil {
+			return Stats{}, fmt.Errorf("failed to get Redis info: %w", err)
+		}
+
+		fields := parseRedisInfo(info)
+		stats.Size += size
+		stats.MemoryBytes += int64(infoUint(fields, "used_memory"))
+		stats.Evictions += infoUint(fields, "evicted_keys")
+		stats.Expirations += infoUint(fields, "expired_keys")
+	}
+
+	return stats, nil
+}
+
+// Close закрывает соединение с Redis. Представление поколения, переданное в Rebuild, соединение не закрывает.
 func (c *RedisCache) Close() error {
+	if c.pinned {
+		return nil
+	}
+	close(c.stopCh)
 	return c.client.Close()
 }
 
-// getOrderKey формирует ключ для хранения заказа в Redis
+// getOrderKey формирует ключ для хранения заказа в Redis в текущем поколении
 func (c *RedisCache) getOrderKey(orderUID string) string {
-	return fmt.Sprintf("order:%s", orderUID)
+	return c.keyspace().order(orderUID)
 }
 
-// Ensure RedisCache implements Cache interface
-var _ Cache = (*RedisCache)(nil)
+// Ensure RedisCache implements Cache, Indexer and Rebuilder interfaces
+var (
+	_ Cache     = (*RedisCache)(nil)
+	_ Indexer   = (*RedisCache)(nil)
+	_ Rebuilder = (*RedisCache)(nil)
+)
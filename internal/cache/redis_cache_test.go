@@ -28,6 +28,7 @@ func isRedisAvailable() bool {
 
 // setupTestRedisCache создает тестовый Redis кэш
 func setupTestRedisCache(t *testing.T) *RedisCache {
+	ctx := context.Background()
 	if !isRedisAvailable() {
 		t.Skip("Redis is not available")
 	}
@@ -36,12 +37,12 @@ func setupTestRedisCache(t *testing.T) *RedisCache {
 	require.NoError(t, err)
 	require.NotNil(t, cache)
 
-	err = cache.Clear()
+	err = cache.Clear(ctx)
 	require.NoError(t, err)
 
 	t.Cleanup(func() {
 		if cache != nil {
-			cache.Clear()
+			cache.Clear(ctx)
 			cache.Close()
 		}
 	})
@@ -64,14 +65,15 @@ func TestNewRedisCache(t *testing.T) {
 }
 
 func TestRedisCache_SaveAndGetOrder(t *testing.T) {
+	ctx := context.Background()
 	cache := setupTestRedisCache(t)
 
 	order := datagenerators.GenerateOrder()
 
-	err := cache.SaveOrder(order)
+	err := cache.SaveOrder(ctx, order)
 	assert.NoError(t, err)
 
-	retrievedOrder, exists, err := cache.GetOrder(order.OrderUID)
+	retrievedOrder, exists, err := cache.GetOrder(ctx, order.OrderUID)
 	assert.NoError(t, err)
 	assert.True(t, exists)
 
@@ -99,77 +101,81 @@ func TestRedisCache_SaveAndGetOrder(t *testing.T) {
 func TestRedisCache_GetOrder_NonExistent(t *testing.T) {
 	cache := setupTestRedisCache(t)
 
-	_, exists, err := cache.GetOrder("non-existent-order-uid")
+	_, exists, err := cache.GetOrder(context.Background(), "non-existent-order-uid")
 	assert.NoError(t, err)
 	assert.False(t, exists)
 }
 
 func TestRedisCache_OrderExists(t *testing.T) {
+	ctx := context.Background()
 	cache := setupTestRedisCache(t)
 
 	order := datagenerators.GenerateOrder()
 
-	exists, err := cache.OrderExists(order.OrderUID)
+	exists, err := cache.OrderExists(ctx, order.OrderUID)
 	assert.NoError(t, err)
 	assert.False(t, exists)
 
-	err = cache.SaveOrder(order)
+	err = cache.SaveOrder(ctx, order)
 	assert.NoError(t, err)
 
-	exists, err = cache.OrderExists(order.OrderUID)
+	exists, err = cache.OrderExists(ctx, order.OrderUID)
 	assert.NoError(t, err)
 	assert.True(t, exists)
 }
 
 func TestRedisCache_RemoveOrder(t *testing.T) {
+	ctx := context.Background()
 	cache := setupTestRedisCache(t)
 
 	order := datagenerators.GenerateOrder()
 
-	err := cache.SaveOrder(order)
+	err := cache.SaveOrder(ctx, order)
 	assert.NoError(t, err)
 
-	exists, err := cache.OrderExists(order.OrderUID)
+	exists, err := cache.OrderExists(ctx, order.OrderUID)
 	assert.NoError(t, err)
 	assert.True(t, exists)
 
-	err = cache.RemoveOrder(order.OrderUID)
+	err = cache.RemoveOrder(ctx, order.OrderUID)
 	assert.NoError(t, err)
 
-	exists, err = cache.OrderExists(order.OrderUID)
+	exists, err = cache.OrderExists(ctx, order.OrderUID)
 	assert.NoError(t, err)
 	assert.False(t, exists)
 }
 
 func TestRedisCache_Clear(t *testing.T) {
+	ctx := context.Background()
 	cache := setupTestRedisCache(t)
 
 	for i := 0; i < 3; i++ {
 		order := datagenerators.GenerateOrder()
-		err := cache.SaveOrder(order)
+		err := cache.SaveOrder(ctx, order)
 		assert.NoError(t, err)
 	}
 
-	err := cache.Clear()
+	err := cache.Clear(ctx)
 	assert.NoError(t, err)
 
-	orders, err := cache.GetAllOrders()
+	orders, err := cache.GetAllOrders(ctx)
 	assert.NoError(t, err)
 	assert.Empty(t, orders)
 }
 
 func TestRedisCache_GetAllOrders(t *testing.T) {
+	ctx := context.Background()
 	cache := setupTestRedisCache(t)
 
 	expectedOrders := make([]models.Order, 3)
 	for i := 0; i < 3; i++ {
 		order := datagenerators.GenerateOrder()
 		expectedOrders[i] = order
-		err := cache.SaveOrder(order)
+		err := cache.SaveOrder(ctx, order)
 		assert.NoError(t, err)
 	}
 
-	retrievedOrders, err := cache.GetAllOrders()
+	retrievedOrders, err := cache.GetAllOrders(ctx)
 	assert.NoError(t, err)
 	assert.Len(t, retrievedOrders, 3)
 
@@ -187,6 +193,7 @@ func TestRedisCache_GetAllOrders(t *testing.T) {
 }
 
 func TestRedisCache_SaveOrderWithTTL(t *testing.T) {
+	ctx := context.Background()
 	if !isRedisAvailable() {
 		t.Skip("Redis is not available")
 	}
@@ -195,25 +202,26 @@ func TestRedisCache_SaveOrderWithTTL(t *testing.T) {
 	require.NoError(t, err)
 	defer cache.Close()
 
-	cache.Clear()
+	cache.Clear(ctx)
 
 	order := datagenerators.GenerateOrder()
 
-	err = cache.saveOrderWithTTL(order, 1*time.Second)
+	err = cache.saveOrderWithTTL(ctx, order, 1*time.Second)
 	assert.NoError(t, err)
 
-	exists, err := cache.OrderExists(order.OrderUID)
+	exists, err := cache.OrderExists(ctx, order.OrderUID)
 	assert.NoError(t, err)
 	assert.True(t, exists)
 
 	time.Sleep(2 * time.Second)
 
-	exists, err = cache.OrderExists(order.OrderUID)
+	exists, err = cache.OrderExists(ctx, order.OrderUID)
 	assert.NoError(t, err)
 	assert.False(t, exists)
 }
 
 func TestRedisCache_Close(t *testing.T) {
+	ctx := context.Background()
 	if !isRedisAvailable() {
 		t.Skip("Redis is not available")
 	}
@@ -222,26 +230,27 @@ func TestRedisCache_Close(t *testing.T) {
 	require.NoError(t, err)
 
 	order := datagenerators.GenerateOrder()
-	err = cache.SaveOrder(order)
+	err = cache.SaveOrder(ctx, order)
 	assert.NoError(t, err)
 
 	err = cache.Close()
 	assert.NoError(t, err)
 
-	_, _, err = cache.GetOrder(order.OrderUID)
+	_, _, err = cache.GetOrder(ctx, order.OrderUID)
 	assert.Error(t, err)
 }
 
 func TestRedisCache_ComplexOrderStructure(t *testing.T) {
+	ctx := context.Background()
 	cache := setupTestRedisCache(t)
 
 	for i := 0; i < 10; i++ {
 		order := datagenerators.GenerateOrder()
 
-		err := cache.SaveOrder(order)
+		err := cache.SaveOrder(ctx, order)
 		assert.NoError(t, err, "Failed to save order %s", order.OrderUID)
 
-		retrieved, exists, err := cache.GetOrder(order.OrderUID)
+		retrieved, exists, err := cache.GetOrder(ctx, order.OrderUID)
 		assert.NoError(t, err, "Failed to get order %s", order.OrderUID)
 		assert.True(t, exists, "Order %s should exist", order.OrderUID)
 
@@ -261,3 +270,77 @@ func TestRedisCache_ComplexOrderStructure(t *testing.T) {
 		assert.Equal(t, order.Payment.AmountTotal, retrieved.Payment.AmountTotal)
 	}
 }
+
+func TestRedisCache_CanceledContext(t *testing.T) {
+	cache := setupTestRedisCache(t)
+
+	order := datagenerators.GenerateOrder()
+	require.NoError(t, cache.SaveOrder(context.Background(), order))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, _, err := cache.GetOrder(ctx, order.OrderUID)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	err = cache.SaveOrder(ctx, datagenerators.GenerateOrder())
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRedisCache_ListOrders(t *testing.T) {
+	ctx := context.Background()
+	cache := setupTestRedisCache(t)
+
+	saved := make(map[string]bool)
+	for i := 0; i < 25; i++ {
+		order := datagenerators.GenerateOrder()
+		require.NoError(t, cache.SaveOrder(ctx, order))
+		saved[order.OrderUID] = true
+	}
+
+	listed := make(map[string]bool)
+	cursor := ""
+	for {
+		orders, next, err := cache.ListOrders(ctx, cursor, 10)
+		require.NoError(t, err)
+		for _, o := range orders {
+			listed[o.OrderUID] = true
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	assert.Equal(t, saved, listed)
+
+	_, _, err := cache.ListOrders(ctx, "not-a-number", 10)
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestRedisCache_ConfiguredTTLAndPolicy(t *testing.T) {
+	if !isRedisAvailable() {
+		t.Skip("Redis is not available")
+	}
+
+	aged := datagenerators.GenerateOrder()
+	aged.DateCreated = time.Now().Add(-48 * time.Hour)
+	fresh := datagenerators.GenerateOrder()
+	fresh.DateCreated = time.Now()
+
+	policy := OrderTTLRules{AgedAfter: 24 * time.Hour, AgedTTL: time.Minute}.Policy()
+	cache, err := NewRedisCache("localhost:6379", "", 5, WithTTL(time.Hour), WithTTLPolicy(policy))
+	require.NoError(t, err)
+	defer cache.Close()
+
+	ctx := context.Background()
+	require.NoError(t, cache.SaveOrder(ctx, aged))
+	require.NoError(t, cache.SaveOrder(ctx, fresh))
+
+	freshTTL, err := cache.client.TTL(ctx, cache.getOrderKey(fresh.OrderUID)).Result()
+	require.NoError(t, err)
+	assert.InDelta(t, time.Hour.Seconds(), freshTTL.Seconds(), 5)
+
+	agedTTL, err := cache.client.TTL(ctx, cache.getOrderKey(aged.OrderUID)).Result()
+	require.NoError(t, err)
+	assert.InDelta(t, time.Minute.Seconds(), agedTTL.Seconds(), 5)
+}
@@ -0,0 +1,194 @@
+package cache
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisMode режим подключения к Redis
+type RedisMode string
+
+const (
+	RedisModeStandalone RedisMode = "standalone"
+	RedisModeSentinel   RedisMode = "sentinel"
+	RedisModeCluster    RedisMode = "cluster"
+)
+
+// RedisTLSConfig настройки TLS-подключения к Redis
+type RedisTLSConfig struct {
+	Enabled            bool
+	CAFile             string // PEM-файл с корневыми сертификатами (пусто — системные)
+	ServerName         string // имя сервера для проверки сертификата
+	InsecureSkipVerify bool   // не проверять сертификат сервера (только для тестовых стендов)
+}
+
+// Validate проверяет настройки подключения с учётом режима, не подключаясь к Redis
+func (cfg RedisConfig) Validate() error {
+	addrs := cfg.addrs()
+	if len(addrs) == 0 {
+		return fmt.Errorf("redis address is required")
+	}
+
+	switch cfg.Mode {
+	case RedisModeStandalone, "":
+		if len(addrs) > 1 {
+			return fmt.Errorf("standalone redis mode expects a single address, got %d", len(addrs))
+		}
+	case RedisModeSentinel:
+		if cfg.MasterName == "" {
+			return fmt.Errorf("master name is required for sentinel redis mode")
+		}
+	case RedisModeCluster:
+		if cfg.DB != 0 {
+			return fmt.Errorf("redis cluster supports only DB 0")
+		}
+	default:
+		return fmt.Errorf("unknown redis mode: %s", cfg.Mode)
+	}
+	return nil
+}
+
+// addrs возвращает адреса узлов: Addrs, а если они не заданы — Addr
+func (cfg RedisConfig) addrs() []string {
+	if len(cfg.Addrs) == 0 && cfg.Addr != "" {
+		return []string{cfg.Addr}
+	}
+	return cfg.Addrs
+}
+
+// newRedisClient создаёт клиент Redis в соответствии с режимом.
+// Для всех режимов возвращается redis.UniversalClient, поэтому RedisCache не зависит от топологии.
+func newRedisClient(cfg RedisConfig) (redis.UniversalClient, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := cfg.TLS.build()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &redis.UniversalOptions{
+		Addrs:            cfg.addrs(),
+		DB:               cfg.DB,
+		Username:         cfg.Username,
+		Password:         cfg.Password,
+		SentinelPassword: cfg.SentinelPassword,
+		MasterName:       cfg.MasterName,
+		TLSConfig:        tlsConfig,
+	}
+
+	switch cfg.Mode {
+	case RedisModeSentinel:
+		return redis.NewFailoverClient(opts.Failover()), nil
+	case RedisModeCluster:
+		return redis.NewClusterClient(opts.Cluster()), nil
+	default:
+		return redis.NewClient(opts.Simple()), nil
+	}
+}
+
+// build формирует tls.Config; если TLS выключен, возвращает nil
+func (t RedisTLSConfig) build() (*tls.Config, error) {
+	if !t.Enabled {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read redis CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in redis CA file %s", t.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+// scanNodes возвращает узлы, keyspace которых нужно обходить через SCAN:
+// мастера кластера в стабильном порядке либо единственный клиент.
+func (c *RedisCache) scanNodes(ctx context.Context) ([]redis.Cmdable, error) {
+	cluster, ok := c.client.(*redis.ClusterClient)
+	if !ok {
+		return []redis.Cmdable{c.client}, nil
+	}
+
+	var mu sync.Mutex
+	var masters []*redis.Client
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, master *redis.Client) error {
+		mu.Lock()
+		masters = append(masters, master)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cluster masters: %w", err)
+	}
+
+	sort.Slice(masters, func(i, j int) bool {
+		return masters[i].Options().Addr < masters[j].Options().Addr
+	})
+
+	nodes := make([]redis.Cmdable, len(masters))
+	for i, master := range masters {
+		nodes[i] = master
+	}
+	return nodes, nil
+}
+
+// isCluster сообщает, работает ли кэш поверх Redis Cluster
+func (c *RedisCache) isCluster() bool {
+	_, ok := c.client.(*redis.ClusterClient)
+	return ok
+}
+
+// parseScanCursor разбирает курсор ListOrders.
+// Для одного узла курсор — число SCAN, для кластера — "<номер мастера>:<курсор SCAN>".
+func parseScanCursor(cursor string) (int, uint64, error) {
+	if cursor == "" {
+		return 0, 0, nil
+	}
+
+	node := 0
+	pos := cursor
+	if i := strings.IndexByte(cursor, ':'); i >= 0 {
+		n, err := strconv.Atoi(cursor[:i])
+		if err != nil || n < 0 {
+			return 0, 0, ErrInvalidCursor
+		}
+		node, pos = n, cursor[i+1:]
+	}
+
+	scan, err := strconv.ParseUint(pos, 10, 64)
+	if err != nil {
+		return 0, 0, ErrInvalidCursor
+	}
+	return node, scan, nil
+}
+
+// formatScanCursor формирует курсор ListOrders (см. parseScanCursor)
+func formatScanCursor(node int, scan uint64, nodes int) string {
+	if nodes == 1 {
+		return strconv.FormatUint(scan, 10)
+	}
+	return fmt.Sprintf("%d:%d", node, scan)
+}
@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRedisClient_Modes(t *testing.T) {
+	client, err := newRedisClient(RedisConfig{Addr: "localhost:6379"})
+	require.NoError(t, err)
+	assert.IsType(t, &redis.Client{}, client)
+	client.Close()
+
+	client, err = newRedisClient(RedisConfig{
+		Mode:       RedisModeSentinel,
+		Addrs:      []string{"sentinel-1:26379", "sentinel-2:26379"},
+		MasterName: "mymaster",
+	})
+	require.NoError(t, err)
+	assert.IsType(t, &redis.Client{}, client) // failover-клиент — это *redis.Client
+	client.Close()
+
+	client, err = newRedisClient(RedisConfig{
+		Mode:  RedisModeCluster,
+		Addrs: []string{"node-1:6379", "node-2:6379"},
+	})
+	require.NoError(t, err)
+	assert.IsType(t, &redis.ClusterClient{}, client)
+	client.Close()
+}
+
+func TestNewRedisClient_InvalidConfig(t *testing.T) {
+	_, err := newRedisClient(RedisConfig{})
+	assert.Error(t, err, "адрес обязателен")
+
+	_, err = newRedisClient(RedisConfig{Mode: RedisModeSentinel, Addrs: []string{"sentinel:26379"}})
+	assert.Error(t, err, "для sentinel нужно имя мастера")
+
+	_, err = newRedisClient(RedisConfig{Mode: RedisModeCluster, Addrs: []string{"node:6379"}, DB: 1})
+	assert.Error(t, err, "кластер поддерживает только DB 0")
+
+	_, err = newRedisClient(RedisConfig{Mode: "ring", Addr: "localhost:6379"})
+	assert.Error(t, err)
+
+	_, err = newRedisClient(RedisConfig{Addr: "localhost:6379", TLS: RedisTLSConfig{Enabled: true, CAFile: "/nonexistent.pem"}})
+	assert.Error(t, err)
+}
+
+func TestScanCursor(t *testing.T) {
+	node, pos, err := parseScanCursor("")
+	require.NoError(t, err)
+	assert.Equal(t, 0, node)
+	assert.Equal(t, uint64(0), pos)
+
+	node, pos, err = parseScanCursor(formatScanCursor(0, 42, 1))
+	require.NoError(t, err)
+	assert.Equal(t, 0, node)
+	assert.Equal(t, uint64(42), pos)
+
+	node, pos, err = parseScanCursor(formatScanCursor(2, 17, 3))
+	require.NoError(t, err)
+	assert.Equal(t, 2, node)
+	assert.Equal(t, uint64(17), pos)
+
+	for _, bad := range []string{"abc", "x:1", "1:y", "-1:5"} {
+		_, _, err = parseScanCursor(bad)
+		assert.ErrorIs(t, err, ErrInvalidCursor, bad)
+	}
+}
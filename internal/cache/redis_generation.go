@@ -0,0 +1,327 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Служебные ключи перестройки. Общий hash tag держит их в одном слоте кластера,
+// чтобы Lua-скрипты могли работать с ними атомарно; под шаблоны SCAN заказов они не попадают.
+const (
+	generationKey   = "{orders}:generation"    // номер текущего поколения ключей
+	rebuildLockKey  = "{orders}:rebuild_lock"  // блокировка перестройки и очистки
+	rebuildFenceKey = "{orders}:rebuild_fence" // счётчик fencing-токенов
+)
+
+const (
+	// rebuildLockLease — срок аренды блокировки; продлевается, пока перестройка жива
+	rebuildLockLease = 30 * time.Second
+	// generationRefreshInterval — как часто узлы перечитывают номер текущего поколения
+	generationRefreshInterval = time.Second
+	// generationGracePeriod — сколько старое поколение живёт после переключения,
+	// чтобы узлы, ещё не заметившие переключение, не читали из пустого поколения
+	generationGracePeriod = 5 * generationRefreshInterval
+)
+
+var (
+	// ErrRebuildInProgress — блокировку перестройки держит другой процесс
+	ErrRebuildInProgress = errors.New("cache rebuild is already in progress")
+	// ErrRebuildLockLost — аренда блокировки истекла до переключения поколения
+	ErrRebuildLockLost = errors.New("cache rebuild lock lost")
+)
+
+// Rebuilder — общий для реплик кэш, который перестраивается целиком без окна с пустым кэшем
+type Rebuilder interface {
+	// Rebuild заполняет новое поколение кэша через fill и атомарно переключает на него читателей.
+	// fill получает кэш, пишущий только в новое поколение; до переключения читатели его не видят.
+	// Если перестройку уже выполняет другой процесс, возвращается ErrRebuildInProgress.
+	Rebuild(ctx context.Context, fill func(ctx context.Context, c Cache) error) error
+}
+
+// keyspace — поколение ключей Redis: заказы order:v{n}:{uid}, индексы order_idx:v{n}:{поле}:{значение}.
+// Поколение 0 — ключи, записанные до появления поколений (order:{uid}, order_idx:{поле}:{значение}):
+// они читаются до первой перестройки и удаляются вместе с поколением 0 после переключения.
+type keyspace uint64
+
+// versionedKey распознаёт ключи поколений с номером больше 0
+var versionedKey = regexp.MustCompile(`^order(_idx)?:v\d+:`)
+
+func (g keyspace) order(orderUID string) string {
+	if g == 0 {
+		return "order:" + orderUID
+	}
+	return fmt.Sprintf("order:v%d:%s", uint64(g), orderUID)
+}
+
+func (g keyspace) index(field IndexField, value string) string {
+	if g == 0 {
+		return fmt.Sprintf("order_idx:%s:%s", field, value)
+	}
+	return fmt.Sprintf("order_idx:v%d:%s:%s", uint64(g), field, value)
+}
+
+func (g keyspace) orderPattern() string {
+	if g == 0 {
+		return "order:*"
+	}
+	return fmt.Sprintf("order:v%d:*", uint64(g))
+}
+
+func (g keyspace) indexPattern() string {
+	if g == 0 {
+		return "order_idx:*"
+	}
+	return fmt.Sprintf("order_idx:v%d:*", uint64(g))
+}
+
+// owns сообщает, принадлежит ли найденный по шаблону ключ поколению:
+// шаблоны поколения 0 захватывают и ключи остальных поколений
+func (g keyspace) owns(key string) bool {
+	return g != 0 || !versionedKey.MatchString(key)
+}
+
+// Lua-скрипты блокировки: освобождение и продление только владельцем,
+// переключение поколения — только владельцем и только на больший номер (fencing).
+var (
+	releaseLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0`)
+
+	extendLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0`)
+
+	switchGenerationScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return -1
+end
+local current = tonumber(redis.call('GET', KEYS[2]) or '0')
+if tonumber(ARGV[1]) <= current then
+	return -1
+end
+redis.call('SET', KEYS[2], ARGV[1])
+return current`)
+)
+
+// keyspace возвращает текущее поколение ключей
+func (c *RedisCache) keyspace() keyspace {
+	return keyspace(c.generation.Load())
+}
+
+// writeKeyspaces возвращает поколения, в которые идут записи: текущее, а во время перестройки
+// ещё и перестраиваемое, чтобы записанное после начала перестройки не пропало при переключении
+func (c *RedisCache) writeKeyspaces() []keyspace {
+	current := c.keyspace()
+	if building := c.building.Load(); building > uint64(current) {
+		return []keyspace{current, keyspace(building)}
+	}
+	return []keyspace{current}
+}
+
+// refreshGeneration перечитывает номер текущего поколения (нет ключа — поколение 0)
+// и номер перестраиваемого — это fencing-токен в блокировке перестройки
+func (c *RedisCache) refreshGeneration(ctx context.Context) error {
+	values, err := c.client.MGet(ctx, generationKey, rebuildLockKey).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read cache generation: %w", err)
+	}
+
+	var gen uint64
+	if value, ok := values[0].(string); ok {
+		if gen, err = strconv.ParseUint(value, 10, 64); err != nil {
+			return fmt.Errorf("failed to parse cache generation %q: %w", value, err)
+		}
+	}
+	var building uint64
+	if value, ok := values[1].(string); ok {
+		// Блокировка без номера поколения (например, чужого формата) не означает перестройку
+		building, _ = strconv.ParseUint(value, 10, 64)
+	}
+	c.generation.Store(gen)
+	c.building.Store(building)
+	return nil
+}
+
+// watchGeneration периодически перечитывает поколение, чтобы узел заметил перестройку на другом узле.
+// Ошибка чтения оставляет прежнее поколение до следующей попытки.
+func (c *RedisCache) watchGeneration() {
+	ticker := time.NewTicker(c.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+			_ = c.refreshGeneration(ctx)
+			cancel()
+		case <-c.stopCh:
+			return
+		}
+	}
+}
+
+// acquireRebuildLock берёт блокировку перестройки с арендой rebuildLockLease
+func (c *RedisCache) acquireRebuildLock(ctx context.Context, token string) error {
+	ok, err := c.client.SetNX(ctx, rebuildLockKey, token, rebuildLockLease).Result()
+	if err != nil {
+		return fmt.Errorf("failed to acquire cache rebuild lock: %w", err)
+	}
+	if !ok {
+		return ErrRebuildInProgress
+	}
+	return nil
+}
+
+// releaseRebuildLock освобождает блокировку, если она всё ещё принадлежит token
+func (c *RedisCache) releaseRebuildLock(token string) {
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+	_ = releaseLockScript.Run(ctx, c.client, []string{rebuildLockKey}, token).Err()
+}
+
+// keepRebuildLock продлевает аренду до отмены ctx. Если блокировка потеряна, вызывает lost.
+func (c *RedisCache) keepRebuildLock(ctx context.Context, token string, lost func()) {
+	ticker := time.NewTicker(rebuildLockLease / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			extended, err := extendLockScript.Run(ctx, c.client, []string{rebuildLockKey},
+				token, rebuildLockLease.Milliseconds()).Int()
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil || extended == 0 {
+				lost()
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Rebuild заполняет новое поколение ключей и переключает на него всех читателей.
+// Номер поколения — fencing-токен блокировки: переключение выполняется Lua-скриптом, только пока
+// блокировка принадлежит этому токену и только на поколение новее текущего, поэтому процесс
+// с истёкшей арендой не может переключить читателей на своё поколение.
+// Заполнение начинается через gracePeriod после взятия блокировки, когда остальные узлы уже заметили
+// перестройку: с этого момента все узлы дублируют сохранения и удаления в новое поколение,
+// а записанное раньше уже есть в БД, из которой читает fill.
+// Старое поколение удаляется через gracePeriod после переключения, когда его перестали читать
+// остальные узлы.
+func (c *RedisCache) Rebuild(ctx context.Context, fill func(ctx context.Context, c Cache) error) error {
+	return c.rebuild(ctx, fill, nil)
+}
+
+// rebuild выполняет Rebuild; onSwitch вызывается сразу после переключения поколения
+func (c *RedisCache) rebuild(ctx context.Context, fill func(ctx context.Context, c Cache) error, onSwitch func()) error {
+	if c.pinned {
+		return fmt.Errorf("cache generation view cannot be rebuilt")
+	}
+
+	gen, err := c.client.Incr(ctx, rebuildFenceKey).Uint64()
+	if err != nil {
+		return fmt.Errorf("failed to get cache rebuild token: %w", err)
+	}
+	token := fmt.Sprint(gen)
+	if err := c.acquireRebuildLock(ctx, token); err != nil {
+		return err
+	}
+	defer c.releaseRebuildLock(token)
+	c.building.Store(gen)
+	defer c.building.CompareAndSwap(gen, 0)
+
+	view := c.generationView(gen)
+	if err := c.fillGeneration(ctx, token, view, fill); err != nil {
+		_ = c.deleteKeys(context.Background(), keyspace(gen))
+		return err
+	}
+
+	previous, err := switchGenerationScript.Run(ctx, c.client, []string{rebuildLockKey, generationKey}, token).Int64()
+	if err != nil {
+		return fmt.Errorf("failed to switch cache generation: %w", err)
+	}
+	if previous < 0 {
+		_ = c.deleteKeys(context.Background(), keyspace(gen))
+		return ErrRebuildLockLost
+	}
+	c.generation.Store(gen)
+	c.releaseRebuildLock(token)
+	if onSwitch != nil {
+		onSwitch()
+	}
+
+	// Узлы замечают новое поколение в течение refreshInterval; до этого они читают старое
+	select {
+	case <-time.After(c.gracePeriod):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if err := c.deleteKeys(ctx, keyspace(previous)); err != nil {
+		return fmt.Errorf("failed to delete previous cache generation: %w", err)
+	}
+	return nil
+}
+
+// fillGeneration выжидает gracePeriod и вызывает fill, продлевая аренду блокировки;
+// потеря блокировки отменяет заполнение
+func (c *RedisCache) fillGeneration(ctx context.Context, token string, view *RedisCache, fill func(ctx context.Context, c Cache) error) error {
+	fillCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg   sync.WaitGroup
+		lost bool
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c.keepRebuildLock(fillCtx, token, func() {
+			lost = true
+			cancel()
+		})
+	}()
+
+	// Остальные узлы узнают о перестройке при очередном опросе поколения
+	var err error
+	select {
+	case <-time.After(c.gracePeriod):
+		err = fill(fillCtx, view)
+	case <-fillCtx.Done():
+		err = fillCtx.Err()
+	}
+	cancel()
+	wg.Wait()
+
+	if lost {
+		return ErrRebuildLockLost
+	}
+	if err != nil {
+		return fmt.Errorf("failed to fill cache generation: %w", err)
+	}
+	return nil
+}
+
+// generationView возвращает кэш поверх того же соединения, закреплённый за поколением gen
+func (c *RedisCache) generationView(gen uint64) *RedisCache {
+	view := &RedisCache{
+		client: c.client,
+		opts:   c.opts,
+		pinned: true,
+	}
+	view.generation.Store(gen)
+	return view
+}
@@ -0,0 +1,228 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/datagenerators"
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisCache_RebuildSwitchesGeneration(t *testing.T) {
+	ctx := context.Background()
+	cache := setupTestRedisCache(t)
+	cache.gracePeriod = 50 * time.Millisecond
+
+	other, err := NewRedisCache("localhost:6379", "", 1)
+	require.NoError(t, err)
+	t.Cleanup(func() { other.Close() })
+
+	stale := datagenerators.GenerateOrder()
+	require.NoError(t, cache.SaveOrder(ctx, stale))
+	before := cache.generation.Load()
+
+	fresh := datagenerators.GenerateOrder()
+	err = cache.Rebuild(ctx, func(ctx context.Context, c Cache) error {
+		require.NoError(t, c.SaveOrders(ctx, []models.Order{fresh}))
+
+		// До переключения читатели видят старое поколение целиком
+		for _, reader := range []*RedisCache{cache, other} {
+			exists, err := reader.OrderExists(ctx, stale.OrderUID)
+			require.NoError(t, err)
+			assert.True(t, exists)
+			exists, err = reader.OrderExists(ctx, fresh.OrderUID)
+			require.NoError(t, err)
+			assert.False(t, exists)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	stats, err := cache.Stats(ctx)
+	require.NoError(t, err)
+	assert.Greater(t, stats.Generation, before)
+
+	exists, err := cache.OrderExists(ctx, fresh.OrderUID)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = cache.OrderExists(ctx, stale.OrderUID)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	// Другой узел замечает переключение при очередном опросе поколения
+	require.Eventually(t, func() bool {
+		exists, err := other.OrderExists(ctx, fresh.OrderUID)
+		return err == nil && exists
+	}, 3*time.Second, 20*time.Millisecond)
+
+	// Старое поколение удалено
+	assert.Empty(t, generationKeys(t, cache, keyspace(before)))
+}
+
+// generationKeys возвращает ключи заказов поколения
+func generationKeys(t *testing.T, cache *RedisCache, ks keyspace) []string {
+	keys, err := cache.client.Keys(context.Background(), ks.orderPattern()).Result()
+	require.NoError(t, err)
+
+	owned := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if ks.owns(key) {
+			owned = append(owned, key)
+		}
+	}
+	return owned
+}
+
+func TestRedisCache_LegacyKeyspace(t *testing.T) {
+	ctx := context.Background()
+	cache := setupTestRedisCache(t)
+	cache.gracePeriod = 0
+
+	// Кэш до появления поколений: номера поколения нет, заказы лежат под order:{uid} в JSON
+	require.NoError(t, cache.client.Del(ctx, generationKey).Err())
+	require.NoError(t, cache.refreshGeneration(ctx))
+	require.Equal(t, uint64(0), cache.generation.Load())
+	require.NoError(t, cache.Clear(ctx))
+
+	legacy := datagenerators.GenerateOrder()
+	data, err := json.Marshal(legacy)
+	require.NoError(t, err)
+	require.NoError(t, cache.client.Set(ctx, "order:"+legacy.OrderUID, data, time.Minute).Err())
+
+	got, exists, err := cache.GetOrder(ctx, legacy.OrderUID)
+	require.NoError(t, err)
+	require.True(t, exists)
+	assert.Equal(t, legacy.OrderUID, got.OrderUID)
+
+	orders, err := cache.GetAllOrders(ctx)
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+
+	// Первая перестройка заменяет поколение 0 и удаляет только его ключи
+	fresh := datagenerators.GenerateOrder()
+	err = cache.Rebuild(ctx, func(ctx context.Context, c Cache) error {
+		return c.SaveOrder(ctx, fresh)
+	})
+	require.NoError(t, err)
+
+	assert.Empty(t, generationKeys(t, cache, 0))
+	assert.Len(t, generationKeys(t, cache, cache.keyspace()), 1)
+	exists, err = cache.OrderExists(ctx, fresh.OrderUID)
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestRedisCache_RebuildMirrorsLiveWrites(t *testing.T) {
+	ctx := context.Background()
+	cache := setupTestRedisCache(t)
+	cache.gracePeriod = 50 * time.Millisecond
+
+	other, err := NewRedisCache("localhost:6379", "", 1)
+	require.NoError(t, err)
+	t.Cleanup(func() { other.Close() })
+
+	removed := datagenerators.GenerateOrder()
+	written := datagenerators.GenerateOrder()
+	writtenByOther := datagenerators.GenerateOrder()
+
+	err = cache.Rebuild(ctx, func(ctx context.Context, c Cache) error {
+		require.NoError(t, c.SaveOrder(ctx, removed))
+
+		// Другой узел замечает перестройку при опросе поколения
+		require.NoError(t, other.refreshGeneration(ctx))
+
+		// Записи и удаления в текущем поколении дублируются в перестраиваемое
+		require.NoError(t, cache.SaveOrder(ctx, written))
+		require.NoError(t, other.SaveOrders(ctx, []models.Order{writtenByOther}))
+		require.NoError(t, other.RemoveOrder(ctx, removed.OrderUID))
+
+		// Очистка не мешает перестройке
+		require.NoError(t, cache.Clear(ctx))
+		return nil
+	})
+	require.NoError(t, err)
+
+	for _, order := range []models.Order{written, writtenByOther} {
+		exists, err := cache.OrderExists(ctx, order.OrderUID)
+		require.NoError(t, err)
+		assert.True(t, exists, order.OrderUID)
+	}
+	exists, err := cache.OrderExists(ctx, removed.OrderUID)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	found, err := cache.FindOrders(ctx, IndexTrackNumber, written.TrackNumber)
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, written.OrderUID, found[0].OrderUID)
+}
+
+func TestRedisCache_RebuildLock(t *testing.T) {
+	ctx := context.Background()
+	cache := setupTestRedisCache(t)
+	cache.gracePeriod = 0
+
+	err := cache.Rebuild(ctx, func(ctx context.Context, c Cache) error {
+		// Пока идёт перестройка, вторая перестройка отклоняется
+		err := cache.Rebuild(ctx, func(context.Context, Cache) error { return nil })
+		assert.ErrorIs(t, err, ErrRebuildInProgress)
+		return nil
+	})
+	require.NoError(t, err)
+
+	// После перестройки блокировка освобождена
+	err = cache.Rebuild(ctx, func(context.Context, Cache) error { return nil })
+	require.NoError(t, err)
+}
+
+func TestRedisCache_RebuildFailureKeepsGeneration(t *testing.T) {
+	ctx := context.Background()
+	cache := setupTestRedisCache(t)
+	cache.gracePeriod = 0
+
+	order := datagenerators.GenerateOrder()
+	require.NoError(t, cache.SaveOrder(ctx, order))
+	before := cache.generation.Load()
+
+	var building keyspace
+	fillErr := errors.New("database is unavailable")
+	err := cache.Rebuild(ctx, func(ctx context.Context, c Cache) error {
+		building = c.(*RedisCache).keyspace()
+		require.NoError(t, c.SaveOrder(ctx, datagenerators.GenerateOrder()))
+		return fillErr
+	})
+	assert.ErrorIs(t, err, fillErr)
+	assert.Equal(t, before, cache.generation.Load())
+
+	exists, err := cache.OrderExists(ctx, order.OrderUID)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	// Недостроенное поколение удалено
+	assert.Empty(t, generationKeys(t, cache, building))
+}
+
+func TestRedisCache_RebuildFencing(t *testing.T) {
+	ctx := context.Background()
+	cache := setupTestRedisCache(t)
+	cache.gracePeriod = 0
+	before := cache.generation.Load()
+	t.Cleanup(func() { cache.client.Del(ctx, rebuildLockKey) })
+
+	err := cache.Rebuild(ctx, func(ctx context.Context, c Cache) error {
+		// Аренда истекла, и блокировку взял другой процесс
+		return cache.client.Set(ctx, rebuildLockKey, "intruder", 0).Err()
+	})
+	assert.ErrorIs(t, err, ErrRebuildLockLost)
+	assert.Equal(t, before, cache.generation.Load())
+
+	// Блокировка другого процесса не снята
+	owner, err := cache.client.Get(ctx, rebuildLockKey).Result()
+	require.NoError(t, err)
+	assert.Equal(t, "intruder", owner)
+}
@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Constructor создаёт кэш зарегистрированного типа по конфигурации
+type Constructor func(config Config) (Cache, error)
+
+// Validator проверяет конфигурацию кэша своего типа, не создавая соединений и файлов
+type Validator func(config Config) error
+
+// backend — зарегистрированный тип кэша
+type backend struct {
+	newCache Constructor
+	validate Validator
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[CacheType]backend)
+)
+
+// Register регистрирует тип кэша для фабрики New. validate может быть nil.
+// Вызывается из init пакета бэкенда; повторная регистрация типа — ошибка программы, поэтому паникует.
+func Register(name CacheType, constructor Constructor, validate Validator) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if name == "" {
+		panic("cache: Register with empty cache type")
+	}
+	if constructor == nil {
+		panic("cache: Register constructor is nil for " + string(name))
+	}
+	if _, dup := registry[name]; dup {
+		panic("cache: Register called twice for " + string(name))
+	}
+	registry[name] = backend{newCache: constructor, validate: validate}
+}
+
+// Registered возвращает зарегистрированные типы кэша в алфавитном порядке
+func Registered() []CacheType {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	types := make([]CacheType, 0, len(registry))
+	for name := range registry {
+		types = append(types, name)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+// lookupBackend находит зарегистрированный тип кэша
+func lookupBackend(name CacheType) (backend, error) {
+	registryMu.RLock()
+	b, ok := registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		var names []string
+		for _, t := range Registered() {
+			names = append(names, string(t))
+		}
+		return backend{}, fmt.Errorf("unknown cache type: %s (registered: %s)", name, strings.Join(names, ", "))
+	}
+	return b, nil
+}
+
+// check проверяет конфигурацию валидатором типа, если он задан
+func (b backend) check(config Config) error {
+	if b.validate == nil {
+		return nil
+	}
+	if err := b.validate(config); err != nil {
+		return fmt.Errorf("invalid %s cache config: %w", config.Type, err)
+	}
+	return nil
+}
+
+// Validate проверяет конфигурацию валидатором её типа кэша
+func Validate(config Config) error {
+	b, err := lookupBackend(config.Type)
+	if err != nil {
+		return err
+	}
+	return b.check(config)
+}
+
+// New проверяет конфигурацию и создаёт кэш зарегистрированного типа
+func New(config Config) (Cache, error) {
+	b, err := lookupBackend(config.Type)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.check(config); err != nil {
+		return nil, err
+	}
+	return b.newCache(config)
+}
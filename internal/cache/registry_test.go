@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegister_CustomBackend(t *testing.T) {
+	const name CacheType = "test-mock"
+	errNoCapacity := errors.New("capacity is required")
+
+	Register(name, func(config Config) (Cache, error) {
+		return NewMock(), nil
+	}, func(config Config) error {
+		if config.Capacity <= 0 {
+			return errNoCapacity
+		}
+		return nil
+	})
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registry, name)
+		registryMu.Unlock()
+	})
+
+	assert.Contains(t, Registered(), name)
+
+	c, err := New(Config{Type: name, Capacity: 1})
+	require.NoError(t, err)
+	assert.IsType(t, &MockCache{}, c)
+
+	_, err = New(Config{Type: name})
+	assert.ErrorIs(t, err, errNoCapacity)
+
+	assert.Panics(t, func() {
+		Register(name, func(Config) (Cache, error) { return NewMock(), nil }, nil)
+	})
+}
+
+func TestNew_UnknownType(t *testing.T) {
+	_, err := New(Config{Type: "memcached"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown cache type: memcached")
+	assert.Contains(t, err.Error(), string(CacheTypeInMemory))
+}
+
+func TestValidate_BuiltinBackends(t *testing.T) {
+	redis := RedisConfig{Addr: "localhost:6379"}
+
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{"inmemory", Config{Type: CacheTypeInMemory, Capacity: 10}, false},
+		{"inmemory by bytes", Config{Type: CacheTypeInMemory, MaxBytes: 1 << 20}, false},
+		{"inmemory without capacity", Config{Type: CacheTypeInMemory}, true},
+		{"inmemory bad eviction", Config{Type: CacheTypeInMemory, Capacity: 10, Eviction: "fifo"}, true},
+		{"inmemory soft ttl above ttl", Config{Type: CacheTypeInMemory, Capacity: 10, TTL: time.Minute, SoftTTL: time.Hour}, true},
+		{"inmemory bus without redis", Config{Type: CacheTypeInMemory, Capacity: 10, InvalidationBus: true}, true},
+		{"inmemory bus", Config{Type: CacheTypeInMemory, Capacity: 10, InvalidationBus: true, Redis: redis}, false},
+		{"redis", Config{Type: CacheTypeRedis, Redis: redis}, false},
+		{"redis without address", Config{Type: CacheTypeRedis}, true},
+		{"redis cluster with db", Config{Type: CacheTypeRedis, Redis: RedisConfig{Mode: RedisModeCluster, Addrs: []string{"a:1"}, DB: 2}}, true},
+		{"redis bad codec", Config{Type: CacheTypeRedis, Redis: redis, Codec: "xml"}, true},
+		{"tiered", Config{Type: CacheTypeTiered, Redis: redis, Capacity: 10}, false},
+		{"tiered without capacity", Config{Type: CacheTypeTiered, Redis: redis}, true},
+		{"bolt", Config{Type: CacheTypeBolt, Path: "cache.db"}, false},
+		{"bolt without path", Config{Type: CacheTypeBolt}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.config)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
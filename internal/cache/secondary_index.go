@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/models"
+)
+
+// IndexField — поле заказа, по которому кэш ведёт вторичный индекс
+type IndexField string
+
+const (
+	IndexTrackNumber IndexField = "track_number"
+	IndexCustomerID  IndexField = "customer_id"
+)
+
+// indexFields — все индексируемые поля
+var indexFields = []IndexField{IndexTrackNumber, IndexCustomerID}
+
+// ErrIndexNotSupported возвращается, если бэкенд кэша не ведёт вторичные индексы
+var ErrIndexNotSupported = errors.New("cache backend does not support secondary indexes")
+
+// Validate проверяет, что по полю ведётся индекс
+func (f IndexField) Validate() error {
+	switch f {
+	case IndexTrackNumber, IndexCustomerID:
+		return nil
+	default:
+		return fmt.Errorf("unknown index field: %s", f)
+	}
+}
+
+// value возвращает значение индексируемого поля заказа
+func (f IndexField) value(order models.Order) string {
+	switch f {
+	case IndexTrackNumber:
+		return order.TrackNumber
+	case IndexCustomerID:
+		return order.CustomerId
+	default:
+		return ""
+	}
+}
+
+// Indexer — кэш со вторичными индексами. Индекс покрывает только заказы, которые сейчас в кэше:
+// вытесненные и просроченные заказы из результатов пропадают.
+type Indexer interface {
+	// FindOrders возвращает заказы с заданным значением поля, от новых к старым по DateCreated
+	FindOrders(ctx context.Context, field IndexField, value string) ([]models.Order, error)
+}
+
+// orderIndex — вторичные индексы in-memory кэша: поле → значение → множество UID.
+// Не потокобезопасен, защищается мьютексом кэша.
+type orderIndex map[IndexField]map[string]map[string]struct{}
+
+func newOrderIndex() orderIndex {
+	idx := make(orderIndex, len(indexFields))
+	for _, field := range indexFields {
+		idx[field] = make(map[string]map[string]struct{})
+	}
+	return idx
+}
+
+// add добавляет заказ во все индексы; пустые значения не индексируются
+func (idx orderIndex) add(order models.Order) {
+	for _, field := range indexFields {
+		value := field.value(order)
+		if value == "" {
+			continue
+		}
+		uids, ok := idx[field][value]
+		if !ok {
+			uids = make(map[string]struct{}, 1)
+			idx[field][value] = uids
+		}
+		uids[order.OrderUID] = struct{}{}
+	}
+}
+
+// remove удаляет заказ из всех индексов и освобождает опустевшие множества
+func (idx orderIndex) remove(order models.Order) {
+	for _, field := range indexFields {
+		value := field.value(order)
+		uids, ok := idx[field][value]
+		if !ok {
+			continue
+		}
+		delete(uids, order.OrderUID)
+		if len(uids) == 0 {
+			delete(idx[field], value)
+		}
+	}
+}
+
+// lookup возвращает UID заказов с заданным значением поля
+func (idx orderIndex) lookup(field IndexField, value string) []string {
+	uids := make([]string, 0, len(idx[field][value]))
+	for uid := range idx[field][value] {
+		uids = append(uids, uid)
+	}
+	return uids
+}
+
+// sortIndexed упорядочивает результат поиска по индексу: от новых заказов к старым, затем по UID
+func sortIndexed(orders []models.Order) {
+	sort.Slice(orders, func(i, j int) bool {
+		if !orders[i].DateCreated.Equal(orders[j].DateCreated) {
+			return orders[i].DateCreated.After(orders[j].DateCreated)
+		}
+		return orders[i].OrderUID < orders[j].OrderUID
+	})
+}
@@ -0,0 +1,302 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/models"
+)
+
+// ShardedInMemoryCache — in-memory кэш, разбитый на независимые сегменты.
+// Заказ попадает в сегмент по хэшу OrderUID; у каждого сегмента свой мьютекс и порядок вытеснения,
+// поэтому операции с разными заказами не конкурируют за одну блокировку.
+// Порядок вытеснения и ограничения размера соблюдаются в пределах сегмента.
+type ShardedInMemoryCache struct {
+	shards   []*InMemoryCache
+	capacity int
+
+	snapshotPath string        // общий файл снимка всех сегментов (пусто — снимки выключены)
+	stopCh       chan struct{} // остановка периодических снимков
+}
+
+// NewShardedInMemoryCache создаёт кэш из shards сегментов.
+// capacity и бюджет памяти (WithMaxBytes) делятся между сегментами поровну с округлением вверх.
+// Снимок (WithSnapshot) делается один на весь кэш, а не по сегментам.
+func NewShardedInMemoryCache(shards, capacity int, ttl time.Duration, opts ...Option) (*ShardedInMemoryCache, error) {
+	if shards <= 0 {
+		return nil, fmt.Errorf("shard count must be > 0")
+	}
+
+	o := applyOptions(options{}, opts)
+	shardOpts := append(opts[:len(opts):len(opts)],
+		WithMaxBytes(ceilDiv(o.maxBytes, int64(shards))),
+		WithSnapshot("", 0),
+	)
+	shardCapacity := int(ceilDiv(int64(capacity), int64(shards)))
+
+	c := &ShardedInMemoryCache{
+		shards:   make([]*InMemoryCache, shards),
+		capacity: capacity,
+
+		snapshotPath: o.snapshotPath,
+		stopCh:       make(chan struct{}),
+	}
+	for i := range c.shards {
+		c.shards[i] = NewInMemoryCache(shardCapacity, ttl, shardOpts...)
+	}
+	if o.snapshotPath != "" && o.snapshotInterval > 0 {
+		go runSnapshots(c, o.snapshotPath, o.snapshotInterval, c.stopCh)
+	}
+	return c, nil
+}
+
+// ceilDiv делит с округлением вверх; неположительное значение остаётся как есть
+func ceilDiv(value, parts int64) int64 {
+	if value <= 0 {
+		return value
+	}
+	return (value + parts - 1) / parts
+}
+
+// shard возвращает сегмент, отвечающий за заказ
+func (c *ShardedInMemoryCache) shard(orderUID string) *InMemoryCache {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(orderUID))
+	return c.shards[h.Sum32()%uint32(len(c.shards))]
+}
+
+// SaveOrder сохраняет заказ в его сегмент
+func (c *ShardedInMemoryCache) SaveOrder(ctx context.Context, order models.Order) error {
+	return c.shard(order.OrderUID).SaveOrder(ctx, order)
+}
+
+// GetOrder ищет заказ в его сегменте
+func (c *ShardedInMemoryCache) GetOrder(ctx context.Context, orderUID string) (models.Order, bool, error) {
+	return c.shard(orderUID).GetOrder(ctx, orderUID)
+}
+
+// GetOrderStale ищет заказ в его сегменте с признаком устаревания
+func (c *ShardedInMemoryCache) GetOrderStale(ctx context.Context, orderUID string) (models.Order, bool, bool, error) {
+	return c.shard(orderUID).GetOrderStale(ctx, orderUID)
+}
+
+// OrderExists проверяет наличие заказа в его сегменте
+func (c *ShardedInMemoryCache) OrderExists(ctx context.Context, orderUID string) (bool, error) {
+	return c.shard(orderUID).OrderExists(ctx, orderUID)
+}
+
+// RemoveOrder удаляет заказ из его сегмента
+func (c *ShardedInMemoryCache) RemoveOrder(ctx context.Context, orderUID string) error {
+	return c.shard(orderUID).RemoveOrder(ctx, orderUID)
+}
+
+// SaveOrders раскладывает заказы по сегментам и сохраняет их по одной блокировке на сегмент
+func (c *ShardedInMemoryCache) SaveOrders(ctx context.Context, orders []models.Order) error {
+	perShard := make(map[*InMemoryCache][]models.Order, len(c.shards))
+	for _, order := range orders {
+		shard := c.shard(order.OrderUID)
+		perShard[shard] = append(perShard[shard], order)
+	}
+	for shard, batch := range perShard {
+		if err := shard.SaveOrders(ctx, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetOrders ищет заказы по сегментам и возвращает их в порядке orderUIDs
+func (c *ShardedInMemoryCache) GetOrders(ctx context.Context, orderUIDs []string) ([]models.Order, []string, error) {
+	byUID := make(map[string]models.Order, len(orderUIDs))
+	for shard, uids := range c.groupByShard(orderUIDs) {
+		found, _, err := shard.GetOrders(ctx, uids)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, order := range found {
+			byUID[order.OrderUID] = order
+		}
+	}
+
+	found := make([]models.Order, 0, len(byUID))
+	var missing []string
+	for _, uid := range orderUIDs {
+		if order, ok := byUID[uid]; ok {
+			found = append(found, order)
+		} else {
+			missing = append(missing, uid)
+		}
+	}
+	return found, missing, nil
+}
+
+// RemoveOrders удаляет заказы по одной блокировке на сегмент
+func (c *ShardedInMemoryCache) RemoveOrders(ctx context.Context, orderUIDs []string) error {
+	for shard, uids := range c.groupByShard(orderUIDs) {
+		if err := shard.RemoveOrders(ctx, uids); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// groupByShard раскладывает UID по сегментам
+func (c *ShardedInMemoryCache) groupByShard(orderUIDs []string) map[*InMemoryCache][]string {
+	perShard := make(map[*InMemoryCache][]string, len(c.shards))
+	for _, uid := range orderUIDs {
+		shard := c.shard(uid)
+		perShard[shard] = append(perShard[shard], uid)
+	}
+	return perShard
+}
+
+// Clear очищает все сегменты
+func (c *ShardedInMemoryCache) Clear(ctx context.Context) error {
+	for _, shard := range c.shards {
+		if err := shard.Clear(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetAllOrders возвращает не просроченные заказы всех сегментов
+func (c *ShardedInMemoryCache) GetAllOrders(ctx context.Context) ([]models.Order, error) {
+	var orders []models.Order
+	for _, shard := range c.shards {
+		shardOrders, err := shard.GetAllOrders(ctx)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, shardOrders...)
+	}
+	if orders == nil {
+		orders = []models.Order{}
+	}
+	return orders, nil
+}
+
+// FindOrders ищет заказы по вторичному индексу во всех сегментах
+func (c *ShardedInMemoryCache) FindOrders(ctx context.Context, field IndexField, value string) ([]models.Order, error) {
+	orders := []models.Order{}
+	for _, shard := range c.shards {
+		shardOrders, err := shard.FindOrders(ctx, field, value)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, shardOrders...)
+	}
+	sortIndexed(orders)
+	return orders, nil
+}
+
+// ListOrders обходит сегменты по очереди.
+// Курсор имеет тот же вид, что и у Redis Cluster: "<номер сегмента>:<смещение в сегменте>".
+func (c *ShardedInMemoryCache) ListOrders(ctx context.Context, cursor string, limit int) ([]models.Order, string, error) {
+	if limit <= 0 {
+		return nil, "", fmt.Errorf("limit must be > 0")
+	}
+	shard, offset, err := parseScanCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	if shard >= len(c.shards) {
+		return nil, "", ErrInvalidCursor
+	}
+
+	orders := make([]models.Order, 0, limit)
+	for shard < len(c.shards) && len(orders) < limit {
+		page, next, err := c.shards[shard].ListOrders(ctx, strconv.FormatUint(offset, 10), limit-len(orders))
+		if err != nil {
+			return nil, "", err
+		}
+		orders = append(orders, page...)
+
+		if next != "" {
+			n, _ := strconv.ParseUint(next, 10, 64)
+			return orders, formatScanCursor(shard, n, len(c.shards)), nil
+		}
+		shard, offset = shard+1, 0
+	}
+
+	if shard >= len(c.shards) {
+		return orders, "", nil
+	}
+	return orders, formatScanCursor(shard, 0, len(c.shards)), nil
+}
+
+// Stats суммирует статистику сегментов
+func (c *ShardedInMemoryCache) Stats(ctx context.Context) (Stats, error) {
+	total := Stats{Backend: string(CacheTypeInMemory), Capacity: c.capacity}
+	for _, shard := range c.shards {
+		stats, err := shard.Stats(ctx)
+		if err != nil {
+			return Stats{}, err
+		}
+		total.Policy = stats.Policy
+		total.Hits += stats.Hits
+		total.StaleHits += stats.StaleHits
+		total.Misses += stats.Misses
+		total.Evictions += stats.Evictions
+		total.Expirations += stats.Expirations
+		total.Size += stats.Size
+		total.MaxBytes += stats.MaxBytes
+		total.MemoryBytes += stats.MemoryBytes
+	}
+	return total, nil
+}
+
+// WriteSnapshot записывает записи всех сегментов в один снимок
+func (c *ShardedInMemoryCache) WriteSnapshot(w io.Writer) error {
+	now := time.Now()
+	data := snapshotData{CreatedAt: now}
+	for _, shard := range c.shards {
+		data.Entries = append(data.Entries, shard.snapshotEntries(now)...)
+	}
+	return encodeSnapshot(w, data)
+}
+
+// ReadSnapshot раскладывает записи снимка по сегментам, сохраняя их порядок внутри сегмента.
+// Снимок может быть сделан при другом количестве сегментов.
+func (c *ShardedInMemoryCache) ReadSnapshot(r io.Reader) (time.Time, error) {
+	data, err := decodeSnapshot(r)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	perShard := make(map[*InMemoryCache][]snapshotEntry, len(c.shards))
+	for _, e := range data.Entries {
+		shard := c.shard(e.Order.OrderUID)
+		perShard[shard] = append(perShard[shard], e)
+	}
+	for shard, entries := range perShard {
+		shard.restoreEntries(data.CreatedAt, entries)
+	}
+	return data.CreatedAt, nil
+}
+
+// Close сохраняет снимок (если включён), останавливает и очищает все сегменты
+func (c *ShardedInMemoryCache) Close() error {
+	close(c.stopCh)
+
+	var err error
+	if c.snapshotPath != "" {
+		err = SaveSnapshot(c, c.snapshotPath)
+	}
+	for _, shard := range c.shards {
+		_ = shard.Close()
+	}
+	return err
+}
+
+// Проверка на соответствие интерфейсам Cache, StaleReader, Snapshotter и Indexer.
+var (
+	_ Cache       = (*ShardedInMemoryCache)(nil)
+	_ StaleReader = (*ShardedInMemoryCache)(nil)
+	_ Snapshotter = (*ShardedInMemoryCache)(nil)
+	_ Indexer     = (*ShardedInMemoryCache)(nil)
+)
@@ -0,0 +1,177 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/datagenerators"
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardedInMemoryCache_Basic(t *testing.T) {
+	ctx := context.Background()
+	cache, err := NewShardedInMemoryCache(4, 100, time.Minute)
+	require.NoError(t, err)
+	t.Cleanup(func() { cache.Close() })
+
+	saved := make(map[string]bool)
+	for i := 0; i < 20; i++ {
+		order := datagenerators.GenerateOrder()
+		require.NoError(t, cache.SaveOrder(ctx, order))
+		saved[order.OrderUID] = true
+	}
+
+	for uid := range saved {
+		order, exists, err := cache.GetOrder(ctx, uid)
+		require.NoError(t, err)
+		require.True(t, exists)
+		assert.Equal(t, uid, order.OrderUID)
+	}
+
+	all, err := cache.GetAllOrders(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, len(saved))
+
+	// Постраничный обход возвращает каждый заказ ровно один раз
+	listed := make(map[string]bool)
+	cursor := ""
+	for {
+		orders, next, err := cache.ListOrders(ctx, cursor, 3)
+		require.NoError(t, err)
+		for _, o := range orders {
+			assert.False(t, listed[o.OrderUID], "order %s listed twice", o.OrderUID)
+			listed[o.OrderUID] = true
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	assert.Equal(t, saved, listed)
+
+	_, _, err = cache.ListOrders(ctx, "9:0", 3)
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	stats, err := cache.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(saved)), stats.Size)
+	assert.Equal(t, uint64(len(saved)), stats.Hits)
+
+	for uid := range saved {
+		require.NoError(t, cache.RemoveOrder(ctx, uid))
+		break
+	}
+	require.NoError(t, cache.Clear(ctx))
+	all, err = cache.GetAllOrders(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, all)
+}
+
+func TestShardedInMemoryCache_CapacityPerShard(t *testing.T) {
+	ctx := context.Background()
+	cache, err := NewShardedInMemoryCache(4, 8, time.Minute)
+	require.NoError(t, err)
+	t.Cleanup(func() { cache.Close() })
+
+	for i := 0; i < 100; i++ {
+		require.NoError(t, cache.SaveOrder(ctx, models.Order{OrderUID: fmt.Sprintf("order-%d", i)}))
+	}
+
+	stats, err := cache.Stats(ctx)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, stats.Size, int64(8))
+	assert.Equal(t, uint64(100)-uint64(stats.Size), stats.Evictions)
+
+	_, err = NewShardedInMemoryCache(0, 8, time.Minute)
+	assert.Error(t, err)
+}
+
+func TestInMemoryCache_ApproximateLRU(t *testing.T) {
+	ctx := context.Background()
+	cache := NewInMemoryCache(2, time.Minute, WithApproximateLRU(time.Hour))
+	t.Cleanup(func() { cache.Close() })
+
+	first := models.Order{OrderUID: "first"}
+	require.NoError(t, cache.SaveOrder(ctx, first))
+	require.NoError(t, cache.SaveOrder(ctx, models.Order{OrderUID: "second"}))
+
+	// Чтение внутри окна не продвигает запись, поэтому она остаётся самой старой и вытесняется
+	_, exists, err := cache.GetOrder(ctx, first.OrderUID)
+	require.NoError(t, err)
+	require.True(t, exists)
+	require.NoError(t, cache.SaveOrder(ctx, models.Order{OrderUID: "third"}))
+
+	exists, err = cache.OrderExists(ctx, first.OrderUID)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	stats, err := cache.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), stats.Hits)
+}
+
+// Бенчмарки сравнивают одиночный InMemoryCache с сегментированным
+// на смешанной нагрузке: 90% чтений (HTTP) и 10% записей (консьюмер).
+
+const benchOrders = 10000
+
+func benchmarkCacheParallel(b *testing.B, cache Cache) {
+	ctx := context.Background()
+	uids := make([]string, benchOrders)
+	for i := range uids {
+		uids[i] = fmt.Sprintf("order-%d", i)
+		if err := cache.SaveOrder(ctx, models.Order{OrderUID: uids[i]}); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	var seed atomic.Int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		rnd := rand.New(rand.NewSource(seed.Add(1)))
+		for pb.Next() {
+			uid := uids[rnd.Intn(len(uids))]
+			if rnd.Intn(10) == 0 {
+				_ = cache.SaveOrder(ctx, models.Order{OrderUID: uid})
+			} else {
+				_, _, _ = cache.GetOrder(ctx, uid)
+			}
+		}
+	})
+}
+
+func BenchmarkInMemoryCache_Parallel(b *testing.B) {
+	cache := NewInMemoryCache(benchOrders, time.Hour)
+	defer cache.Close()
+	benchmarkCacheParallel(b, cache)
+}
+
+func BenchmarkInMemoryCache_ApproximateLRU_Parallel(b *testing.B) {
+	cache := NewInMemoryCache(benchOrders, time.Hour, WithApproximateLRU(time.Second))
+	defer cache.Close()
+	benchmarkCacheParallel(b, cache)
+}
+
+func BenchmarkShardedInMemoryCache_Parallel(b *testing.B) {
+	cache, err := NewShardedInMemoryCache(32, benchOrders, time.Hour)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer cache.Close()
+	benchmarkCacheParallel(b, cache)
+}
+
+func BenchmarkShardedInMemoryCache_ApproximateLRU_Parallel(b *testing.B) {
+	cache, err := NewShardedInMemoryCache(32, benchOrders, time.Hour, WithApproximateLRU(time.Second))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer cache.Close()
+	benchmarkCacheParallel(b, cache)
+}
@@ -0,0 +1,151 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/models"
+)
+
+// Формат снимка: [magic "OCSN"][версия][CRC32 полезной нагрузки, big-endian][полезная нагрузка gob].
+// Полезная нагрузка — время снимка и записи от самых холодных к самым горячим,
+// поэтому последовательная вставка при восстановлении сохраняет порядок LRU.
+const (
+	snapshotMagic   = "OCSN"
+	snapshotVersion = 1
+	snapshotHeader  = len(snapshotMagic) + 1 + 4
+)
+
+// ErrSnapshotCorrupt — снимок повреждён (неверный заголовок или контрольная сумма)
+var ErrSnapshotCorrupt = errors.New("cache snapshot is corrupt")
+
+// Snapshotter — кэш, содержимое которого можно сохранить на диск и восстановить при старте
+type Snapshotter interface {
+	// WriteSnapshot записывает не просроченные записи с оставшимся TTL и порядком вытеснения
+	WriteSnapshot(w io.Writer) error
+	// ReadSnapshot добавляет в кэш записи снимка и возвращает время, когда он был сделан.
+	// Повреждённый снимок не меняет кэш и возвращает ErrSnapshotCorrupt.
+	ReadSnapshot(r io.Reader) (time.Time, error)
+}
+
+// snapshotEntry — запись снимка
+type snapshotEntry struct {
+	Order   models.Order
+	TTL     time.Duration // оставшееся время жизни на момент снимка
+	SoftTTL time.Duration // оставшийся мягкий TTL (отрицательный — запись уже устарела; 0 — не задан)
+}
+
+// snapshotData — полезная нагрузка снимка
+type snapshotData struct {
+	CreatedAt time.Time
+	Entries   []snapshotEntry
+}
+
+// SaveSnapshot атомарно записывает снимок кэша в файл (через временный файл и переименование)
+func SaveSnapshot(s Snapshotter, path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := s.WriteSnapshot(tmp); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to sync snapshot file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close snapshot file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace snapshot file: %w", err)
+	}
+	return nil
+}
+
+// LoadSnapshot восстанавливает кэш из файла снимка и возвращает время снимка.
+// Если файла нет, возвращается ошибка, для которой errors.Is(err, os.ErrNotExist).
+func LoadSnapshot(s Snapshotter, path string) (time.Time, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to open snapshot file: %w", err)
+	}
+	defer f.Close()
+
+	createdAt, err := s.ReadSnapshot(f)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read snapshot %s: %w", path, err)
+	}
+	return createdAt, nil
+}
+
+// encodeSnapshot записывает снимок с заголовком и контрольной суммой
+func encodeSnapshot(w io.Writer, data snapshotData) error {
+	var payload bytes.Buffer
+	if err := gob.NewEncoder(&payload).Encode(data); err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	header := make([]byte, snapshotHeader)
+	copy(header, snapshotMagic)
+	header[len(snapshotMagic)] = snapshotVersion
+	binary.BigEndian.PutUint32(header[len(snapshotMagic)+1:], crc32.ChecksumIEEE(payload.Bytes()))
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(payload.Bytes())
+	return err
+}
+
+// decodeSnapshot читает снимок целиком и проверяет контрольную сумму до разбора записей
+func decodeSnapshot(r io.Reader) (snapshotData, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return snapshotData{}, err
+	}
+	if len(raw) < snapshotHeader || string(raw[:len(snapshotMagic)]) != snapshotMagic {
+		return snapshotData{}, ErrSnapshotCorrupt
+	}
+	if version := raw[len(snapshotMagic)]; version != snapshotVersion {
+		return snapshotData{}, fmt.Errorf("unsupported cache snapshot version %d", version)
+	}
+
+	payload := raw[snapshotHeader:]
+	if binary.BigEndian.Uint32(raw[len(snapshotMagic)+1:]) != crc32.ChecksumIEEE(payload) {
+		return snapshotData{}, ErrSnapshotCorrupt
+	}
+
+	var data snapshotData
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&data); err != nil {
+		return snapshotData{}, fmt.Errorf("%w: %v", ErrSnapshotCorrupt, err)
+	}
+	return data, nil
+}
+
+// runSnapshots периодически сохраняет снимок до закрытия stopCh.
+// Ошибка очередного сохранения не прерывает цикл: следующий снимок перезапишет файл.
+func runSnapshots(s Snapshotter, path string, interval time.Duration, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_ = SaveSnapshot(s, path)
+		case <-stopCh:
+			return
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/datagenerators"
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemoryCache_SnapshotRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	source := NewInMemoryCache(3, time.Minute)
+	t.Cleanup(func() { source.Close() })
+
+	order := datagenerators.GenerateOrder()
+	require.NoError(t, source.SaveOrder(ctx, order))
+	require.NoError(t, source.SaveOrder(ctx, models.Order{OrderUID: "second"}))
+	require.NoError(t, source.SaveOrder(ctx, models.Order{OrderUID: "third"}))
+	// order становится самым свежим, "second" — самым старым
+	_, _, err := source.GetOrder(ctx, order.OrderUID)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, source.WriteSnapshot(&buf))
+
+	restored := NewInMemoryCache(3, time.Minute)
+	t.Cleanup(func() { restored.Close() })
+	createdAt, err := restored.ReadSnapshot(&buf)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), createdAt, time.Second)
+
+	got, exists, err := restored.GetOrder(ctx, order.OrderUID)
+	require.NoError(t, err)
+	require.True(t, exists)
+	assert.Equal(t, order.OrderUID, got.OrderUID)
+	assert.Equal(t, order.Items, got.Items)
+	assert.True(t, order.DateCreated.Equal(got.DateCreated))
+
+	// Порядок LRU сохранён: новая запись вытесняет "second"
+	require.NoError(t, restored.SaveOrder(ctx, models.Order{OrderUID: "fourth"}))
+	for uid, expected := range map[string]bool{"second": false, "third": true, order.OrderUID: true} {
+		exists, err := restored.OrderExists(ctx, uid)
+		require.NoError(t, err)
+		assert.Equal(t, expected, exists, uid)
+	}
+}
+
+func TestInMemoryCache_SnapshotKeepsRemainingTTL(t *testing.T) {
+	ctx := context.Background()
+	source := NewInMemoryCache(10, time.Minute)
+	t.Cleanup(func() { source.Close() })
+	require.NoError(t, source.SaveOrder(ctx, models.Order{OrderUID: "order"}))
+
+	var buf bytes.Buffer
+	require.NoError(t, source.WriteSnapshot(&buf))
+	raw := buf.Bytes()
+
+	// Записи, истёкшие за время простоя, не восстанавливаются
+	data, err := decodeSnapshot(bytes.NewReader(raw))
+	require.NoError(t, err)
+	require.Len(t, data.Entries, 1)
+	assert.InDelta(t, time.Minute, data.Entries[0].TTL, float64(time.Second))
+
+	data.CreatedAt = data.CreatedAt.Add(-2 * time.Minute)
+	var stale bytes.Buffer
+	require.NoError(t, encodeSnapshot(&stale, data))
+
+	restored := NewInMemoryCache(10, time.Hour)
+	t.Cleanup(func() { restored.Close() })
+	_, err = restored.ReadSnapshot(&stale)
+	require.NoError(t, err)
+	stats, err := restored.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), stats.Size)
+}
+
+func TestInMemoryCache_CorruptSnapshot(t *testing.T) {
+	ctx := context.Background()
+	source := NewInMemoryCache(10, time.Minute)
+	t.Cleanup(func() { source.Close() })
+	require.NoError(t, source.SaveOrder(ctx, models.Order{OrderUID: "order"}))
+
+	var buf bytes.Buffer
+	require.NoError(t, source.WriteSnapshot(&buf))
+	raw := buf.Bytes()
+	raw[len(raw)-1] ^= 0xff
+
+	restored := NewInMemoryCache(10, time.Minute)
+	t.Cleanup(func() { restored.Close() })
+	_, err := restored.ReadSnapshot(bytes.NewReader(raw))
+	assert.ErrorIs(t, err, ErrSnapshotCorrupt)
+
+	_, err = restored.ReadSnapshot(bytes.NewReader([]byte("garbage")))
+	assert.ErrorIs(t, err, ErrSnapshotCorrupt)
+
+	stats, err := restored.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), stats.Size)
+}
+
+func TestSnapshot_SavedOnClose(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+
+	_, err := LoadSnapshot(NewInMemoryCache(10, time.Minute), path)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	source, err := NewShardedInMemoryCache(4, 100, time.Minute, WithSnapshot(path, 0))
+	require.NoError(t, err)
+	for i := 0; i < 20; i++ {
+		require.NoError(t, source.SaveOrder(ctx, models.Order{OrderUID: fmt.Sprintf("order-%d", i)}))
+	}
+	require.NoError(t, source.Close())
+
+	// Снимок сегментированного кэша читается кэшем с другим количеством сегментов
+	restored := NewInMemoryCache(100, time.Minute)
+	t.Cleanup(func() { restored.Close() })
+	_, err = LoadSnapshot(restored, path)
+	require.NoError(t, err)
+
+	all, err := restored.GetAllOrders(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, 20)
+
+	// Временные файлы не остаются рядом со снимком
+	files, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+}
+
+func TestSnapshot_Periodic(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+
+	cache := NewInMemoryCache(10, time.Minute, WithSnapshot(path, 20*time.Millisecond))
+	t.Cleanup(func() { cache.Close() })
+	require.NoError(t, cache.SaveOrder(ctx, models.Order{OrderUID: "order"}))
+
+	require.Eventually(t, func() bool {
+		restored := NewInMemoryCache(10, time.Minute)
+		defer restored.Close()
+		if _, err := LoadSnapshot(restored, path); err != nil {
+			return false
+		}
+		exists, _ := restored.OrderExists(ctx, "order")
+		return exists
+	}, time.Second, 10*time.Millisecond)
+}
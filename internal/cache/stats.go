@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unsafe"
+
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/models"
+)
+
+// Stats — статистика работы кэша.
+// Счётчики накапливаются с момента создания кэша (для Redis — частично с момента старта сервера).
+type Stats struct {
+	Backend string `json:"backend"`
+	Policy  string `json:"policy,omitempty"` // алгоритм вытеснения in-memory кэша
+
+	Hits        uint64 `json:"hits"`        // GetOrder нашёл заказ
+	StaleHits   uint64 `json:"stale_hits"`  // из них — устаревший после мягкого TTL
+	Misses      uint64 `json:"misses"`      // GetOrder не нашёл заказ
+	Evictions   uint64 `json:"evictions"`   // записи, вытесненные из-за ограничения размера
+	Expirations uint64 `json:"expirations"` // записи, удалённые по истечении TTL
+
+	Size        int64 `json:"size"`                // текущее количество записей
+	Capacity    int   `json:"capacity,omitempty"`  // максимальное количество записей (0 — без ограничения)
+	MaxBytes    int64 `json:"max_bytes,omitempty"` // бюджет памяти (0 — без ограничения)
+	MemoryBytes int64 `json:"memory_bytes"`        // оценка занимаемой памяти
+
+	Generation uint64 `json:"generation,omitempty"` // текущее поколение ключей Redis (см. Rebuilder)
+
+	Loads    uint64        `json:"loads"`        // загрузки из БД при промахе (read-through)
+	LoadTime time.Duration `json:"load_time_ns"` // суммарное время загрузок из БД
+
+	Tiers map[string]Stats `json:"tiers,omitempty"` // статистика уровней составного кэша
+}
+
+// HitRatio возвращает долю попаданий среди обращений GetOrder
+func (s Stats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// Приблизительные размеры структур заказа в памяти (без содержимого строк и слайсов)
+var (
+	orderStructSize = int64(unsafe.Sizeof(models.Order{}))
+	itemStructSize  = int64(unsafe.Sizeof(models.OrderItem{}))
+)
+
+// entryOverhead — накладные расходы in-memory кэша на одну запись: элемент списка, entry и ячейка карты
+const entryOverhead = 160
+
+// estimateOrderSize оценивает объём памяти, занимаемый заказом в in-memory кэше
+func estimateOrderSize(order models.Order) int64 {
+	size := orderStructSize + entryOverhead
+	size += int64(len(order.OrderUID)*2 + len(order.TrackNumber) + len(order.EntryPoint) + len(order.LocaleCode) +
+		len(order.InternalSignature) + len(order.CustomerId) + len(order.DeliveryService) +
+		len(order.ShardKey) + len(order.OOFShard))
+
+	d := order.Delivery
+	size += int64(len(d.OrderUID) + len(d.Name) + len(d.Phone) + len(d.Zip) + len(d.City) +
+		len(d.Address) + len(d.Region) + len(d.Email))
+
+	p := order.Payment
+	size += int64(len(p.TransactionUID) + len(p.RequestID) + len(p.CurrencyCode) +
+		len(p.PaymentProvider) + len(p.BankCode))
+
+	size += int64(cap(order.Items)) * itemStructSize
+	for _, item := range order.Items {
+		size += int64(len(item.TrackNumber) + len(item.RID) + len(item.ProductName) +
+			len(item.SizeCode) + len(item.BrandName))
+	}
+	return size
+}
+
+// parseRedisInfo разбирает ответ INFO в набор "поле: значение"
+func parseRedisInfo(info string) map[string]string {
+	fields := make(map[string]string)
+	for _, line := range strings.Split(info, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			fields[key] = value
+		}
+	}
+	return fields
+}
+
+// infoUint возвращает числовое поле INFO; отсутствующее или некорректное поле даёт 0
+func infoUint(fields map[string]string, key string) uint64 {
+	v, _ := strconv.ParseUint(fields[key], 10, 64)
+	return v
+}
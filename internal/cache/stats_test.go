@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/datagenerators"
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemoryCache_Stats(t *testing.T) {
+	ctx := context.Background()
+	cache := setupTestInMemoryCache(t, 2)
+
+	first := datagenerators.GenerateOrder()
+	second := datagenerators.GenerateOrder()
+	third := datagenerators.GenerateOrder()
+	for _, order := range []models.Order{first, second, third} {
+		require.NoError(t, cache.SaveOrder(ctx, order))
+	}
+
+	_, _, err := cache.GetOrder(ctx, third.OrderUID) // попадание
+	require.NoError(t, err)
+	_, _, err = cache.GetOrder(ctx, first.OrderUID) // вытеснен — промах
+	require.NoError(t, err)
+	_, err = cache.OrderExists(ctx, second.OrderUID) // не учитывается
+	require.NoError(t, err)
+
+	stats, err := cache.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, string(CacheTypeInMemory), stats.Backend)
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Misses)
+	assert.Equal(t, uint64(1), stats.Evictions)
+	assert.Equal(t, int64(2), stats.Size)
+	assert.Equal(t, 2, stats.Capacity)
+	assert.Equal(t, estimateOrderSize(second)+estimateOrderSize(third), stats.MemoryBytes)
+	assert.InDelta(t, 0.5, stats.HitRatio(), 1e-9)
+
+	require.NoError(t, cache.RemoveOrder(ctx, second.OrderUID))
+	stats, err = cache.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, estimateOrderSize(third), stats.MemoryBytes)
+}
+
+func TestInMemoryCache_StatsExpirations(t *testing.T) {
+	ctx := context.Background()
+	cache := NewInMemoryCache(10, 50*time.Millisecond)
+	t.Cleanup(func() { cache.Close() })
+
+	order := datagenerators.GenerateOrder()
+	require.NoError(t, cache.SaveOrder(ctx, order))
+	time.Sleep(100 * time.Millisecond)
+
+	_, exists, err := cache.GetOrder(ctx, order.OrderUID)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	stats, err := cache.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), stats.Expirations)
+	assert.Equal(t, int64(0), stats.Size)
+	assert.Equal(t, int64(0), stats.MemoryBytes)
+}
+
+func TestRedisCache_Stats(t *testing.T) {
+	ctx := context.Background()
+	cache := setupTestRedisCache(t)
+
+	// Служебные ключи перестройки переживают Clear и тоже учитываются в DBSIZE
+	before, err := cache.Stats(ctx)
+	require.NoError(t, err)
+
+	order := datagenerators.GenerateOrder()
+	require.NoError(t, cache.SaveOrder(ctx, order))
+
+	_, _, err = cache.GetOrder(ctx, order.OrderUID)
+	require.NoError(t, err)
+	_, _, err = cache.GetOrder(ctx, "missing")
+	require.NoError(t, err)
+
+	stats, err := cache.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, string(CacheTypeRedis), stats.Backend)
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Misses)
+	// DBSIZE: ключ заказа и множества индексов по трек-номеру и клиенту
+	assert.Equal(t, before.Size+3, stats.Size)
+}
+
+func TestReadThroughCache_Stats(t *testing.T) {
+	ctx := context.Background()
+	order := datagenerators.GenerateOrder()
+	cache := NewReadThroughCache(NewMock(), newStubLoader(order), 0)
+	t.Cleanup(func() { cache.Close() })
+
+	_, exists, err := cache.GetOrder(ctx, order.OrderUID)
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	stats, err := cache.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), stats.Loads)
+	assert.Equal(t, int64(1), stats.Size)
+}
+
+func TestParseRedisInfo(t *testing.T) {
+	fields := parseRedisInfo("# Memory\r\nused_memory:1024\r\n\r\n# Stats\r\nexpired_keys:7\r\n")
+	assert.Equal(t, uint64(1024), infoUint(fields, "used_memory"))
+	assert.Equal(t, uint64(7), infoUint(fields, "expired_keys"))
+	assert.Equal(t, uint64(0), infoUint(fields, "evicted_keys"))
+}
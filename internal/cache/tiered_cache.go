@@ -0,0 +1,246 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/models"
+)
+
+// TieredCache — двухуровневый кэш: ограниченный in-memory LRU (L1) перед Redis (L2).
+// L1 — InMemoryCache или ShardedInMemoryCache.
+// Чтение сначала обращается к L1 и при промахе — к Redis, заполняя L1.
+// Запись идёт в оба уровня. Сохранение, удаление и очистка рассылаются остальным узлам
+// через шину инвалидации, чтобы они сбросили устаревшие записи своих L1.
+type TieredCache struct {
+	l1  Cache
+	l2  *RedisCache
+	bus *InvalidationBus
+}
+
+// NewTieredCache создаёт двухуровневый кэш поверх существующих L1 и L2
+// и подписывается на события инвалидации от других узлов.
+// Канал шины задаётся опцией WithInvalidationChannel.
+func NewTieredCache(l1 Cache, l2 *RedisCache, opts ...Option) (*TieredCache, error) {
+	o := applyOptions(options{}, opts)
+	c := &TieredCache{l1: l1, l2: l2}
+
+	bus, err := newInvalidationBus(l2.client, o.invalidationChannel, c.applyInvalidation)
+	if err != nil {
+		return nil, err
+	}
+	c.bus = bus
+	return c, nil
+}
+
+// applyInvalidation применяет к L1 событие другого узла.
+// Сохранённые на другом узле заказы не копируются в L1, а сбрасываются: при следующем
+// чтении L1 заполнится актуальной версией из Redis.
+func (c *TieredCache) applyInvalidation(ctx context.Context, event invalidationEvent) {
+	switch event.Op {
+	case invalidationSave, invalidationRemove:
+		_ = c.l1.RemoveOrders(ctx, event.uids())
+	case invalidationClear:
+		_ = c.l1.Clear(ctx)
+	}
+}
+
+// SaveOrder сохраняет заказ в Redis и в локальный L1 и сообщает остальным узлам,
+// что их копия заказа устарела
+func (c *TieredCache) SaveOrder(ctx context.Context, order models.Order) error {
+	if err := c.l2.SaveOrder(ctx, order); err != nil {
+		return err
+	}
+	if err := c.l1.SaveOrder(ctx, order); err != nil {
+		return err
+	}
+	return c.bus.publish(ctx, invalidationEvent{Op: invalidationSave, OrderUID: order.OrderUID})
+}
+
+// SaveOrders сохраняет заказы пакетом в Redis и в локальный L1 и рассылает одно событие на пакет
+func (c *TieredCache) SaveOrders(ctx context.Context, orders []models.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+	if err := c.l2.SaveOrders(ctx, orders); err != nil {
+		return err
+	}
+	if err := c.l1.SaveOrders(ctx, orders); err != nil {
+		return err
+	}
+	return c.bus.publish(ctx, invalidationEvent{Op: invalidationSave, OrderUIDs: orderUIDs(orders)})
+}
+
+// GetOrders ищет заказы в L1, отсутствующие дочитывает из Redis одним пайплайном
+// и кладёт их в L1. Результат возвращается в порядке orderUIDs.
+func (c *TieredCache) GetOrders(ctx context.Context, orderUIDs []string) ([]models.Order, []string, error) {
+	found, missing, err := c.l1.GetOrders(ctx, orderUIDs)
+	if err != nil || len(missing) == 0 {
+		return found, missing, err
+	}
+
+	fromL2, missing, err := c.l2.GetOrders(ctx, missing)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(fromL2) == 0 {
+		return found, missing, nil
+	}
+	if err := c.l1.SaveOrders(ctx, fromL2); err != nil {
+		return nil, nil, err
+	}
+
+	byUID := make(map[string]models.Order, len(found)+len(fromL2))
+	for _, order := range found {
+		byUID[order.OrderUID] = order
+	}
+	for _, order := range fromL2 {
+		byUID[order.OrderUID] = order
+	}
+	ordered := make([]models.Order, 0, len(byUID))
+	for _, uid := range orderUIDs {
+		if order, ok := byUID[uid]; ok {
+			ordered = append(ordered, order)
+		}
+	}
+	return ordered, missing, nil
+}
+
+// GetOrder ищет заказ в L1, при промахе — в Redis, и кладёт найденный заказ в L1
+func (c *TieredCache) GetOrder(ctx context.Context, orderUID string) (models.Order, bool, error) {
+	order, ok, err := c.l1.GetOrder(ctx, orderUID)
+	if err != nil || ok {
+		return order, ok, err
+	}
+
+	order, ok, err = c.l2.GetOrder(ctx, orderUID)
+	if err != nil || !ok {
+		return order, ok, err
+	}
+
+	if err := c.l1.SaveOrder(ctx, order); err != nil {
+		return models.Order{}, false, err
+	}
+	return order, true, nil
+}
+
+// OrderExists проверяет наличие заказа в L1, а затем в Redis
+func (c *TieredCache) OrderExists(ctx context.Context, orderUID string) (bool, error) {
+	ok, err := c.l1.OrderExists(ctx, orderUID)
+	if err != nil || ok {
+		return ok, err
+	}
+	return c.l2.OrderExists(ctx, orderUID)
+}
+
+// RemoveOrder удаляет заказ из обоих уровней и сообщает об этом остальным узлам
+func (c *TieredCache) RemoveOrder(ctx context.Context, orderUID string) error {
+	if err := c.l2.RemoveOrder(ctx, orderUID); err != nil {
+		return err
+	}
+	if err := c.l1.RemoveOrder(ctx, orderUID); err != nil {
+		return err
+	}
+	return c.bus.publish(ctx, invalidationEvent{Op: invalidationRemove, OrderUID: orderUID})
+}
+
+// RemoveOrders удаляет заказы из обоих уровней и рассылает одно событие на весь пакет
+func (c *TieredCache) RemoveOrders(ctx context.Context, orderUIDs []string) error {
+	if len(orderUIDs) == 0 {
+		return nil
+	}
+	if err := c.l2.RemoveOrders(ctx, orderUIDs); err != nil {
+		return err
+	}
+	if err := c.l1.RemoveOrders(ctx, orderUIDs); err != nil {
+		return err
+	}
+	return c.bus.publish(ctx, invalidationEvent{Op: invalidationRemove, OrderUIDs: orderUIDs})
+}
+
+// Clear очищает оба уровня и сообщает об этом остальным узлам
+func (c *TieredCache) Clear(ctx context.Context) error {
+	if err := c.l2.Clear(ctx); err != nil {
+		return err
+	}
+	if err := c.l1.Clear(ctx); err != nil {
+		return err
+	}
+	return c.bus.publish(ctx, invalidationEvent{Op: invalidationClear})
+}
+
+// GetAllOrders возвращает все заказы из Redis: L1 содержит лишь их подмножество
+func (c *TieredCache) GetAllOrders(ctx context.Context) ([]models.Order, error) {
+	return c.l2.GetAllOrders(ctx)
+}
+
+// Rebuild перестраивает Redis в новом поколении ключей. После переключения поколения
+// L1 всех узлов очищается, чтобы дальше заполняться уже из нового поколения.
+func (c *TieredCache) Rebuild(ctx context.Context, fill func(ctx context.Context, c Cache) error) error {
+	var switchErr error
+	err := c.l2.rebuild(ctx, fill, func() {
+		if err := c.l1.Clear(ctx); err != nil {
+			switchErr = err
+			return
+		}
+		switchErr = c.bus.publish(ctx, invalidationEvent{Op: invalidationClear})
+	})
+	if err != nil {
+		return err
+	}
+	return switchErr
+}
+
+// FindOrders ищет заказы по вторичному индексу в Redis: L1 содержит лишь их подмножество
+func (c *TieredCache) FindOrders(ctx context.Context, field IndexField, value string) ([]models.Order, error) {
+	return c.l2.FindOrders(ctx, field, value)
+}
+
+// ListOrders постранично обходит заказы в Redis
+func (c *TieredCache) ListOrders(ctx context.Context, cursor string, limit int) ([]models.Order, string, error) {
+	return c.l2.ListOrders(ctx, cursor, limit)
+}
+
+// Stats возвращает сводную статистику и статистику каждого уровня.
+// Промах L1 с попаданием в Redis считается попаданием; размер и память — по Redis.
+func (c *TieredCache) Stats(ctx context.Context) (Stats, error) {
+	l1, err := c.l1.Stats(ctx)
+	if err != nil {
+		return Stats{}, err
+	}
+	l2, err := c.l2.Stats(ctx)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	return Stats{
+		Backend:     string(CacheTypeTiered),
+		Hits:        l1.Hits + l2.Hits,
+		Misses:      l2.Misses,
+		Evictions:   l1.Evictions + l2.Evictions,
+		Expirations: l1.Expirations + l2.Expirations,
+		Size:        l2.Size,
+		MemoryBytes: l2.MemoryBytes,
+		Generation:  l2.Generation,
+		Tiers:       map[string]Stats{"l1": l1, "l2": l2},
+	}, nil
+}
+
+// Close отписывается от событий инвалидации и закрывает оба уровня
+func (c *TieredCache) Close() error {
+	err := c.bus.Close()
+
+	if l1Err := c.l1.Close(); err == nil {
+		err = l1Err
+	}
+	if l2Err := c.l2.Close(); err == nil {
+		err = l2Err
+	}
+	return err
+}
+
+// Проверка на соответствие интерфейсам Cache, Indexer и Rebuilder.
+var (
+	_ Cache     = (*TieredCache)(nil)
+	_ Indexer   = (*TieredCache)(nil)
+	_ Rebuilder = (*TieredCache)(nil)
+)
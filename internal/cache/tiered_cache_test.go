@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/datagenerators"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupTestTieredCache создает tiered-кэш поверх тестовой базы Redis
+func setupTestTieredCache(t *testing.T) *TieredCache {
+	if !isRedisAvailable() {
+		t.Skip("Redis is not available")
+	}
+
+	l2, err := NewRedisCache("localhost:6379", "", 4) // Используем DB 4 для тестов
+	require.NoError(t, err)
+
+	cache, err := NewTieredCache(NewInMemoryCache(100, time.Minute), l2)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		cache.Close()
+	})
+
+	return cache
+}
+
+func TestTieredCache_SaveAndGetOrder(t *testing.T) {
+	ctx := context.Background()
+	cache := setupTestTieredCache(t)
+	require.NoError(t, cache.Clear(ctx))
+
+	order := datagenerators.GenerateOrder()
+	require.NoError(t, cache.SaveOrder(ctx, order))
+
+	// Запись попадает в оба уровня
+	_, inL1, err := cache.l1.GetOrder(ctx, order.OrderUID)
+	require.NoError(t, err)
+	assert.True(t, inL1)
+
+	inL2, err := cache.l2.OrderExists(ctx, order.OrderUID)
+	require.NoError(t, err)
+	assert.True(t, inL2)
+
+	retrieved, exists, err := cache.GetOrder(ctx, order.OrderUID)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, order.OrderUID, retrieved.OrderUID)
+}
+
+func TestTieredCache_FallThroughToRedis(t *testing.T) {
+	ctx := context.Background()
+	cache := setupTestTieredCache(t)
+	require.NoError(t, cache.Clear(ctx))
+
+	order := datagenerators.GenerateOrder()
+	require.NoError(t, cache.l2.SaveOrder(ctx, order))
+
+	retrieved, exists, err := cache.GetOrder(ctx, order.OrderUID)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, order.OrderUID, retrieved.OrderUID)
+
+	// После промаха L1 заполняется из Redis
+	_, inL1, err := cache.l1.GetOrder(ctx, order.OrderUID)
+	require.NoError(t, err)
+	assert.True(t, inL1)
+}
+
+func TestTieredCache_CrossNodeInvalidation(t *testing.T) {
+	ctx := context.Background()
+	node1 := setupTestTieredCache(t)
+	node2 := setupTestTieredCache(t)
+	require.NoError(t, node1.Clear(ctx))
+
+	order := datagenerators.GenerateOrder()
+	require.NoError(t, node1.SaveOrder(ctx, order))
+
+	// Второй узел читает заказ и кладёт его в свой L1
+	_, exists, err := node2.GetOrder(ctx, order.OrderUID)
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	require.NoError(t, node1.RemoveOrder(ctx, order.OrderUID))
+
+	assert.Eventually(t, func() bool {
+		_, inL1, err := node2.l1.GetOrder(ctx, order.OrderUID)
+		return err == nil && !inL1
+	}, 2*time.Second, 10*time.Millisecond, "L1 второго узла должен быть инвалидирован")
+
+	// Clear на одном узле очищает L1 остальных
+	other := datagenerators.GenerateOrder()
+	require.NoError(t, node2.SaveOrder(ctx, other))
+	require.NoError(t, node1.Clear(ctx))
+
+	assert.Eventually(t, func() bool {
+		orders, err := node2.l1.GetAllOrders(ctx)
+		return err == nil && len(orders) == 0
+	}, 2*time.Second, 10*time.Millisecond, "L1 второго узла должен быть очищен")
+}
@@ -0,0 +1,194 @@
+package cache
+
+// Стенд сравнения политик вытеснения на записанных трассах обращений.
+//
+// Трасса — текстовый файл, по одной операции в строке: "<order_uid>" или "<op> <order_uid>",
+// где op — get (по умолчанию), set или del; строки, начинающиеся с '#', пропускаются.
+// Трассу GET-запросов можно получить из логов HTTP-сервера (поле path запросов /order/{order_uid}).
+//
+// Запуск на записанной трассе:
+//
+//	CACHE_TRACE=/path/to/trace.txt CACHE_TRACE_CAPACITY=1000 \
+//	    go test ./internal/cache -run '^$' -bench TraceReplay
+//
+// Без CACHE_TRACE используется синтетическая трасса: распределение Ципфа с периодическими
+// разовыми обходами, как при GetAllOrders и прогреве. Результат — метрика hit-ratio.
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// traceOp — операция трассы
+type traceOp struct {
+	op  string
+	uid string
+}
+
+// readTrace разбирает трассу обращений
+func readTrace(r io.Reader) ([]traceOp, error) {
+	var ops []traceOp
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		switch len(fields) {
+		case 1:
+			ops = append(ops, traceOp{op: "get", uid: fields[0]})
+		case 2:
+			op := strings.ToLower(fields[0])
+			if op != "get" && op != "set" && op != "del" {
+				return nil, fmt.Errorf("line %d: unknown operation %q", line, fields[0])
+			}
+			ops = append(ops, traceOp{op: op, uid: fields[1]})
+		default:
+			return nil, fmt.Errorf("line %d: expected \"[op] order_uid\"", line)
+		}
+	}
+	return ops, scanner.Err()
+}
+
+// syntheticTrace строит трассу: обращения по закону Ципфа к keys заказам
+// и через каждые scanEvery обращений — разовый обход scanLen новых заказов
+func syntheticTrace(n, keys, scanEvery, scanLen int) []traceOp {
+	rnd := rand.New(rand.NewSource(1))
+	zipf := rand.NewZipf(rnd, 1.1, 1, uint64(keys-1))
+
+	ops := make([]traceOp, 0, n)
+	scans := 0
+	for len(ops) < n {
+		ops = append(ops, traceOp{op: "get", uid: fmt.Sprintf("order-%d", zipf.Uint64())})
+		if len(ops)%scanEvery == 0 {
+			for i := 0; i < scanLen && len(ops) < n; i++ {
+				ops = append(ops, traceOp{op: "get", uid: fmt.Sprintf("scan-%d-%d", scans, i)})
+			}
+			scans++
+		}
+	}
+	return ops
+}
+
+// replayTrace проигрывает трассу на кэше; промах get заполняет кэш, как read-through.
+// Возвращает долю попаданий среди get.
+func replayTrace(ctx context.Context, cache Cache, ops []traceOp) (float64, error) {
+	var gets, hits int
+	for _, op := range ops {
+		switch op.op {
+		case "set":
+			if err := cache.SaveOrder(ctx, models.Order{OrderUID: op.uid}); err != nil {
+				return 0, err
+			}
+		case "del":
+			if err := cache.RemoveOrder(ctx, op.uid); err != nil {
+				return 0, err
+			}
+		default:
+			gets++
+			_, ok, err := cache.GetOrder(ctx, op.uid)
+			if err != nil {
+				return 0, err
+			}
+			if ok {
+				hits++
+				continue
+			}
+			if err := cache.SaveOrder(ctx, models.Order{OrderUID: op.uid}); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if gets == 0 {
+		return 0, nil
+	}
+	return float64(hits) / float64(gets), nil
+}
+
+// loadBenchTrace загружает трассу из CACHE_TRACE или строит синтетическую
+func loadBenchTrace(b *testing.B) ([]traceOp, int) {
+	capacity := 1000
+	if raw := os.Getenv("CACHE_TRACE_CAPACITY"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			b.Fatalf("invalid CACHE_TRACE_CAPACITY: %q", raw)
+		}
+		capacity = n
+	}
+
+	path := os.Getenv("CACHE_TRACE")
+	if path == "" {
+		return syntheticTrace(200000, 20000, 5000, 2000), capacity
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+
+	ops, err := readTrace(f)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return ops, capacity
+}
+
+func BenchmarkEvictionPolicies_TraceReplay(b *testing.B) {
+	ops, capacity := loadBenchTrace(b)
+	ctx := context.Background()
+
+	for _, policy := range allEvictionPolicies {
+		b.Run(string(policy), func(b *testing.B) {
+			var ratio float64
+			for i := 0; i < b.N; i++ {
+				cache := NewInMemoryCache(capacity, time.Hour, WithEvictionPolicy(policy))
+				var err error
+				ratio, err = replayTrace(ctx, cache, ops)
+				cache.Close()
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(ratio, "hit-ratio")
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*len(ops)), "ns/access")
+		})
+	}
+}
+
+func TestReadTrace(t *testing.T) {
+	ops, err := readTrace(strings.NewReader("# trace\norder-1\nset order-2\n\nDEL order-1\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []traceOp{
+		{op: "get", uid: "order-1"},
+		{op: "set", uid: "order-2"},
+		{op: "del", uid: "order-1"},
+	}, ops)
+
+	_, err = readTrace(strings.NewReader("put order-1\n"))
+	assert.Error(t, err)
+}
+
+func TestReplayTrace(t *testing.T) {
+	cache := NewInMemoryCache(10, time.Minute)
+	t.Cleanup(func() { cache.Close() })
+
+	ops := []traceOp{{op: "get", uid: "a"}, {op: "get", uid: "a"}, {op: "del", uid: "a"}, {op: "get", uid: "a"}}
+	ratio, err := replayTrace(context.Background(), cache, ops)
+	require.NoError(t, err)
+	assert.InDelta(t, 1.0/3, ratio, 1e-9)
+}
@@ -0,0 +1,176 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/models"
+)
+
+// defaultRedisTTL — время жизни записи в Redis, если TTL не задан
+const defaultRedisTTL = 24 * time.Hour
+
+// TTLPolicy вычисляет время жизни записи по самому заказу.
+// ttl — базовое время жизни, настроенное для кэша. Результат должен быть > 0.
+type TTLPolicy func(order models.Order, ttl time.Duration) time.Duration
+
+// Option настраивает создаваемый кэш
+type Option func(*options)
+
+type options struct {
+	ttl       time.Duration
+	ttlPolicy TTLPolicy
+	softTTL   time.Duration
+	codec     Codec
+	maxBytes  int64
+	eviction  EvictionPolicy
+
+	promoteWindow time.Duration
+
+	snapshotPath     string
+	snapshotInterval time.Duration
+
+	invalidationChannel string
+}
+
+// WithTTL задаёт базовое время жизни записи
+func WithTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.ttl = ttl
+	}
+}
+
+// WithTTLPolicy задаёт политику, которая уточняет время жизни для каждого заказа
+func WithTTLPolicy(policy TTLPolicy) Option {
+	return func(o *options) {
+		o.ttlPolicy = policy
+	}
+}
+
+// WithSoftTTL задаёт мягкий TTL in-memory кэша: по его истечении запись отдаётся как устаревшая
+// (см. StaleReader) до жёсткого TTL. 0 или значение не меньше жёсткого TTL — мягкий TTL выключен.
+func WithSoftTTL(softTTL time.Duration) Option {
+	return func(o *options) {
+		o.softTTL = softTTL
+	}
+}
+
+// WithCodec задаёт сериализацию заказов (используется бэкендами, хранящими байты)
+func WithCodec(codec Codec) Option {
+	return func(o *options) {
+		if codec != nil {
+			o.codec = codec
+		}
+	}
+}
+
+// WithMaxBytes ограничивает суммарный приблизительный размер записей in-memory кэша
+// (0 — без ограничения). Действует вместе с ограничением по количеству записей.
+func WithMaxBytes(maxBytes int64) Option {
+	return func(o *options) {
+		o.maxBytes = maxBytes
+	}
+}
+
+// WithEvictionPolicy задаёт алгоритм вытеснения in-memory кэша (по умолчанию LRU)
+func WithEvictionPolicy(policy EvictionPolicy) Option {
+	return func(o *options) {
+		o.eviction = policy
+	}
+}
+
+// WithApproximateLRU включает приблизительный LRU для in-memory кэша: запись перемещается
+// в начало списка не чаще раза в window, а остальные чтения выполняются под RLock.
+// Это убирает блокировку на запись из большинства GetOrder ценой менее точного порядка вытеснения.
+// Действует только для политики LRU.
+func WithApproximateLRU(window time.Duration) Option {
+	return func(o *options) {
+		o.promoteWindow = window
+	}
+}
+
+// WithSnapshot включает снимки in-memory кэша: содержимое записывается в файл path
+// при Close и каждые interval (0 — только при Close). Восстановление — LoadSnapshot.
+func WithSnapshot(path string, interval time.Duration) Option {
+	return func(o *options) {
+		o.snapshotPath = path
+		o.snapshotInterval = interval
+	}
+}
+
+// WithInvalidationChannel задаёт канал Redis pub/sub шины инвалидации
+// (пусто — DefaultInvalidationChannel). Используется TieredCache и CoherentCache.
+func WithInvalidationChannel(channel string) Option {
+	return func(o *options) {
+		o.invalidationChannel = channel
+	}
+}
+
+// applyOptions применяет опции поверх значений по умолчанию
+func applyOptions(defaults options, opts []Option) options {
+	for _, opt := range opts {
+		opt(&defaults)
+	}
+	return defaults
+}
+
+// ttlFor возвращает время жизни записи с учётом политики
+func (o options) ttlFor(order models.Order) time.Duration {
+	if o.ttlPolicy == nil {
+		return o.ttl
+	}
+	if ttl := o.ttlPolicy(order, o.ttl); ttl > 0 {
+		return ttl
+	}
+	return o.ttl
+}
+
+// OrderTTLRules — правила сокращения TTL для заказов, которые вряд ли будут запрошены снова.
+// Нулевые значения отключают соответствующее правило.
+type OrderTTLRules struct {
+	AgedAfter time.Duration // заказ считается старым, если DateCreated раньше, чем now - AgedAfter
+	AgedTTL   time.Duration // время жизни старого заказа
+
+	TerminalStatuses []int         // терминальные значения StatusCode товара
+	TerminalTTL      time.Duration // время жизни заказа, все товары которого в терминальном статусе
+}
+
+// Policy возвращает TTLPolicy по правилам.
+// Если подходит несколько правил, берётся наименьший TTL; базовый TTL никогда не увеличивается.
+func (r OrderTTLRules) Policy() TTLPolicy {
+	terminal := make(map[int]struct{}, len(r.TerminalStatuses))
+	for _, status := range r.TerminalStatuses {
+		terminal[status] = struct{}{}
+	}
+
+	return func(order models.Order, ttl time.Duration) time.Duration {
+		if r.AgedAfter > 0 && r.AgedTTL > 0 && !order.DateCreated.IsZero() &&
+			time.Since(order.DateCreated) > r.AgedAfter {
+			ttl = shorterTTL(ttl, r.AgedTTL)
+		}
+		if r.TerminalTTL > 0 && len(terminal) > 0 && allItemsTerminal(order.Items, terminal) {
+			ttl = shorterTTL(ttl, r.TerminalTTL)
+		}
+		return ttl
+	}
+}
+
+// allItemsTerminal проверяет, что у заказа есть товары и все они в терминальном статусе
+func allItemsTerminal(items []models.OrderItem, terminal map[int]struct{}) bool {
+	if len(items) == 0 {
+		return false
+	}
+	for _, item := range items {
+		if _, ok := terminal[item.StatusCode]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// shorterTTL возвращает меньший из двух TTL, считая 0 отсутствием ограничения
+func shorterTTL(current, candidate time.Duration) time.Duration {
+	if current <= 0 || candidate < current {
+		return candidate
+	}
+	return current
+}
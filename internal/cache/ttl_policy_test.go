@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/datagenerators"
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderTTLRules_Policy(t *testing.T) {
+	policy := OrderTTLRules{
+		AgedAfter:        30 * 24 * time.Hour,
+		AgedTTL:          10 * time.Minute,
+		TerminalStatuses: []int{202},
+		TerminalTTL:      5 * time.Minute,
+	}.Policy()
+
+	fresh := datagenerators.GenerateOrder()
+	fresh.DateCreated = time.Now()
+	for i := range fresh.Items {
+		fresh.Items[i].StatusCode = 100
+	}
+	assert.Equal(t, time.Hour, policy(fresh, time.Hour), "свежий заказ получает базовый TTL")
+
+	aged := fresh
+	aged.DateCreated = time.Now().Add(-60 * 24 * time.Hour)
+	assert.Equal(t, 10*time.Minute, policy(aged, time.Hour))
+
+	terminal := datagenerators.GenerateOrder()
+	terminal.DateCreated = time.Now()
+	for i := range terminal.Items {
+		terminal.Items[i].StatusCode = 202
+	}
+	assert.Equal(t, 5*time.Minute, policy(terminal, time.Hour))
+
+	// Политика не увеличивает TTL сверх базового
+	assert.Equal(t, time.Minute, policy(aged, time.Minute))
+}
+
+func TestInMemoryCache_TTLPolicy(t *testing.T) {
+	short := datagenerators.GenerateOrder()
+	long := datagenerators.GenerateOrder()
+
+	cache := NewInMemoryCache(100, time.Hour, WithTTLPolicy(func(order models.Order, ttl time.Duration) time.Duration {
+		if order.OrderUID == short.OrderUID {
+			return 50 * time.Millisecond
+		}
+		return ttl
+	}))
+	defer cache.Close()
+
+	ctx := context.Background()
+	assert.NoError(t, cache.SaveOrder(ctx, short))
+	assert.NoError(t, cache.SaveOrder(ctx, long))
+
+	time.Sleep(100 * time.Millisecond)
+
+	_, exists, err := cache.GetOrder(ctx, short.OrderUID)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	_, exists, err = cache.GetOrder(ctx, long.OrderUID)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+}
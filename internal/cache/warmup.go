@@ -0,0 +1,194 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/models"
+)
+
+// defaultWarmUpPageSize — размер страницы прогрева, если он не задан
+const defaultWarmUpPageSize = 500
+
+// WarmUpStrategy определяет, какие заказы загружаются в кэш при старте
+type WarmUpStrategy string
+
+const (
+	WarmUpNone   WarmUpStrategy = "none"   // кэш заполняется только через read-through и консьюмер
+	WarmUpRecent WarmUpStrategy = "recent" // последние Count заказов по date_created
+	WarmUpSince  WarmUpStrategy = "since"  // заказы, созданные за последние Window
+	WarmUpFile   WarmUpStrategy = "file"   // заказы из файла со списком order_uid
+)
+
+// Validate проверяет, что стратегия прогрева известна
+func (s WarmUpStrategy) Validate() error {
+	switch s {
+	case WarmUpNone, WarmUpRecent, WarmUpSince, WarmUpFile:
+		return nil
+	default:
+		return fmt.Errorf("unknown warm-up strategy: %s", s)
+	}
+}
+
+// WarmUpConfig — настройки прогрева кэша
+type WarmUpConfig struct {
+	Strategy WarmUpStrategy
+	Count    int           // recent: сколько последних заказов загрузить
+	Window   time.Duration // since: за какой период загрузить заказы
+	UIDFile  string        // file: файл с order_uid, по одному в строке ('#' — комментарий)
+	PageSize int           // размер страницы (0 — значение по умолчанию)
+}
+
+// Validate проверяет, что для выбранной стратегии заданы нужные параметры
+func (c WarmUpConfig) Validate() error {
+	if err := c.Strategy.Validate(); err != nil {
+		return err
+	}
+	if c.PageSize < 0 {
+		return fmt.Errorf("warm-up page size must not be negative")
+	}
+
+	switch c.Strategy {
+	case WarmUpRecent:
+		if c.Count <= 0 {
+			return fmt.Errorf("warm-up count must be > 0 for %s strategy", c.Strategy)
+		}
+	case WarmUpSince:
+		if c.Window <= 0 {
+			return fmt.Errorf("warm-up window must be > 0 for %s strategy", c.Strategy)
+		}
+	case WarmUpFile:
+		if c.UIDFile == "" {
+			return fmt.Errorf("warm-up UID file is required for %s strategy", c.Strategy)
+		}
+	}
+	return nil
+}
+
+// WarmUpSource постранично отдаёт заказы для прогрева. Реализуется repository.OrdersRepo.
+type WarmUpSource interface {
+	// RecentOrdersCutoff возвращает date_created n-го по свежести заказа; ok = false, если заказов меньше n
+	RecentOrdersCutoff(n int) (cutoff time.Time, ok bool, err error)
+	// GetOrdersCreatedAfter возвращает до limit заказов, следующих за (createdAt, orderUID)
+	// в порядке возрастания (date_created, order_uid)
+	GetOrdersCreatedAfter(createdAt time.Time, orderUID string, limit int) ([]models.Order, error)
+	// GetOrdersByUIDs возвращает заказы из списка; отсутствующие пропускаются
+	GetOrdersByUIDs(orderUIDs []string) ([]models.Order, error)
+}
+
+// WarmUp загружает заказы в кэш по выбранной стратегии страницами через FillOrders
+// и возвращает количество загруженных заказов.
+// Заказы по дате идут от старых к новым, поэтому самые свежие вытесняются последними.
+// Отмена ctx прерывает прогрев между страницами.
+func WarmUp(ctx context.Context, c Cache, src WarmUpSource, cfg WarmUpConfig) (int, error) {
+	if err := cfg.Validate(); err != nil {
+		return 0, err
+	}
+	if cfg.PageSize == 0 {
+		cfg.PageSize = defaultWarmUpPageSize
+	}
+
+	switch cfg.Strategy {
+	case WarmUpRecent:
+		cutoff, ok, err := src.RecentOrdersCutoff(cfg.Count)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			// Заказов меньше Count — загружаем все
+			cutoff = time.Time{}
+		}
+		// Заказы с той же date_created, что у Count-го, тоже попадают в прогрев
+		return warmUpCreatedSince(ctx, c, src, cutoff, cfg.PageSize)
+	case WarmUpSince:
+		return warmUpCreatedSince(ctx, c, src, time.Now().Add(-cfg.Window), cfg.PageSize)
+	case WarmUpFile:
+		return warmUpFromFile(ctx, c, src, cfg.UIDFile, cfg.PageSize)
+	default:
+		return 0, nil
+	}
+}
+
+// warmUpCreatedSince загружает заказы, созданные начиная с since, страницами по возрастанию даты
+func warmUpCreatedSince(ctx context.Context, c Cache, src WarmUpSource, since time.Time, pageSize int) (int, error) {
+	loaded := 0
+	createdAt, orderUID := since, ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return loaded, err
+		}
+
+		page, err := src.GetOrdersCreatedAfter(createdAt, orderUID, pageSize)
+		if err != nil {
+			return loaded, fmt.Errorf("failed to load warm-up page: %w", err)
+		}
+		if err := FillOrders(ctx, c, page); err != nil {
+			return loaded, fmt.Errorf("failed to save warm-up page: %w", err)
+		}
+		loaded += len(page)
+
+		if len(page) < pageSize {
+			return loaded, nil
+		}
+		last := page[len(page)-1]
+		createdAt, orderUID = last.DateCreated, last.OrderUID
+	}
+}
+
+// warmUpFromFile загружает заказы из файла со списком UID в порядке следования в файле
+func warmUpFromFile(ctx context.Context, c Cache, src WarmUpSource, path string, pageSize int) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open warm-up UID file: %w", err)
+	}
+	defer f.Close()
+
+	loaded := 0
+	err = readUIDPages(f, pageSize, func(uids []string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		page, err := src.GetOrdersByUIDs(uids)
+		if err != nil {
+			return fmt.Errorf("failed to load warm-up page: %w", err)
+		}
+		if err := FillOrders(ctx, c, page); err != nil {
+			return fmt.Errorf("failed to save warm-up page: %w", err)
+		}
+		loaded += len(page)
+		return nil
+	})
+	return loaded, err
+}
+
+// readUIDPages читает UID построчно и передаёт их в fn страницами по pageSize.
+// Пустые строки и строки, начинающиеся с '#', пропускаются.
+func readUIDPages(r io.Reader, pageSize int, fn func(uids []string) error) error {
+	scanner := bufio.NewScanner(r)
+	page := make([]string, 0, pageSize)
+	for scanner.Scan() {
+		uid := strings.TrimSpace(scanner.Text())
+		if uid == "" || strings.HasPrefix(uid, "#") {
+			continue
+		}
+		page = append(page, uid)
+		if len(page) == pageSize {
+			if err := fn(page); err != nil {
+				return err
+			}
+			page = page[:0]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read warm-up UID file: %w", err)
+	}
+	if len(page) > 0 {
+		return fn(page)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubWarmUpSource — WarmUpSource поверх списка заказов, отсортированного по (date_created, order_uid)
+type stubWarmUpSource struct {
+	mu     sync.Mutex
+	orders []models.Order
+	pages  int
+}
+
+func newStubWarmUpSource(n int, start time.Time, step time.Duration) *stubWarmUpSource {
+	src := &stubWarmUpSource{}
+	for i := 0; i < n; i++ {
+		src.orders = append(src.orders, models.Order{
+			OrderUID:    fmt.Sprintf("order-%03d", i),
+			DateCreated: start.Add(time.Duration(i) * step),
+		})
+	}
+	return src
+}
+
+func (s *stubWarmUpSource) RecentOrdersCutoff(n int) (time.Time, bool, error) {
+	if n > len(s.orders) {
+		return time.Time{}, false, nil
+	}
+	return s.orders[len(s.orders)-n].DateCreated, true, nil
+}
+
+func (s *stubWarmUpSource) GetOrdersCreatedAfter(createdAt time.Time, orderUID string, limit int) ([]models.Order, error) {
+	s.mu.Lock()
+	s.pages++
+	s.mu.Unlock()
+
+	var page []models.Order
+	for _, o := range s.orders {
+		after := o.DateCreated.After(createdAt) || (o.DateCreated.Equal(createdAt) && o.OrderUID > orderUID)
+		if after && len(page) < limit {
+			page = append(page, o)
+		}
+	}
+	return page, nil
+}
+
+func (s *stubWarmUpSource) GetOrdersByUIDs(orderUIDs []string) ([]models.Order, error) {
+	s.mu.Lock()
+	s.pages++
+	s.mu.Unlock()
+
+	var page []models.Order
+	for _, o := range s.orders {
+		if slices.Contains(orderUIDs, o.OrderUID) {
+			page = append(page, o)
+		}
+	}
+	return page, nil
+}
+
+func (s *stubWarmUpSource) uids() []string {
+	uids := make([]string, len(s.orders))
+	for i, o := range s.orders {
+		uids[i] = o.OrderUID
+	}
+	return uids
+}
+
+func cachedUIDs(t *testing.T, c Cache) []string {
+	all, err := c.GetAllOrders(context.Background())
+	require.NoError(t, err)
+	uids := make([]string, 0, len(all))
+	for _, o := range all {
+		uids = append(uids, o.OrderUID)
+	}
+	slices.Sort(uids)
+	return uids
+}
+
+func TestWarmUp_Strategies(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	// 10 заказов с шагом в час: order-009 создан только что, order-000 — 9 часов назад
+	newSource := func() *stubWarmUpSource { return newStubWarmUpSource(10, now.Add(-9*time.Hour), time.Hour) }
+
+	uidFile := filepath.Join(t.TempDir(), "uids.txt")
+	require.NoError(t, os.WriteFile(uidFile, []byte("# избранные заказы\norder-001\n\n  order-005  \nunknown\n"), 0o644))
+
+	tests := []struct {
+		name     string
+		cfg      WarmUpConfig
+		expected []string
+	}{
+		{"none", WarmUpConfig{Strategy: WarmUpNone}, []string{}},
+		{"recent", WarmUpConfig{Strategy: WarmUpRecent, Count: 3, PageSize: 2}, []string{"order-007", "order-008", "order-009"}},
+		{"recent more than exist", WarmUpConfig{Strategy: WarmUpRecent, Count: 50, PageSize: 4}, newSource().uids()},
+		{"since", WarmUpConfig{Strategy: WarmUpSince, Window: 150 * time.Minute}, []string{"order-007", "order-008", "order-009"}},
+		{"file", WarmUpConfig{Strategy: WarmUpFile, UIDFile: uidFile, PageSize: 2}, []string{"order-001", "order-005"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewInMemoryCache(100, time.Minute)
+			t.Cleanup(func() { c.Close() })
+
+			loaded, err := WarmUp(ctx, c, newSource(), tt.cfg)
+			require.NoError(t, err)
+			assert.Equal(t, len(tt.expected), loaded)
+			assert.Equal(t, tt.expected, cachedUIDs(t, c))
+		})
+	}
+}
+
+func TestWarmUp_StreamsPages(t *testing.T) {
+	ctx := context.Background()
+	src := newStubWarmUpSource(10, time.Now().Add(-time.Hour), time.Second)
+
+	// Кэш меньше прогрева: остаются самые свежие заказы
+	c := NewInMemoryCache(4, time.Minute)
+	t.Cleanup(func() { c.Close() })
+
+	loaded, err := WarmUp(ctx, c, src, WarmUpConfig{Strategy: WarmUpRecent, Count: 10, PageSize: 3})
+	require.NoError(t, err)
+	assert.Equal(t, 10, loaded)
+	assert.Equal(t, 4, src.pages)
+	assert.Equal(t, []string{"order-006", "order-007", "order-008", "order-009"}, cachedUIDs(t, c))
+}
+
+func TestWarmUp_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c := NewInMemoryCache(100, time.Minute)
+	t.Cleanup(func() { c.Close() })
+
+	loaded, err := WarmUp(ctx, c, newStubWarmUpSource(10, time.Now(), time.Second), WarmUpConfig{Strategy: WarmUpRecent, Count: 5})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Zero(t, loaded)
+}
+
+func TestWarmUpConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     WarmUpConfig
+		wantErr bool
+	}{
+		{"none", WarmUpConfig{Strategy: WarmUpNone}, false},
+		{"unknown strategy", WarmUpConfig{Strategy: "popular"}, true},
+		{"recent without count", WarmUpConfig{Strategy: WarmUpRecent}, true},
+		{"since without window", WarmUpConfig{Strategy: WarmUpSince}, true},
+		{"file without path", WarmUpConfig{Strategy: WarmUpFile}, true},
+		{"negative page size", WarmUpConfig{Strategy: WarmUpRecent, Count: 1, PageSize: -1}, true},
+		{"recent", WarmUpConfig{Strategy: WarmUpRecent, Count: 1}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestReadUIDPages(t *testing.T) {
+	input := "a\n# comment\n\nb\n c \nd\ne\n"
+
+	var pages [][]string
+	err := readUIDPages(strings.NewReader(input), 2, func(uids []string) error {
+		pages = append(pages, slices.Clone(uids))
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"a", "b"}, {"c", "d"}, {"e"}}, pages)
+}
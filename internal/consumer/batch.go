@@ -0,0 +1,240 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/config"
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/models"
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/repository"
+	"go.uber.org/zap"
+)
+
+// batchSettings — параметры пакетной обработки; size 1 — обработка по одному сообщению
+type batchSettings struct {
+	size     int
+	interval time.Duration
+	workers  int
+}
+
+// newBatchSettings берёт параметры пакетной обработки из конфигурации, проверенной config.Validate
+func newBatchSettings(kafkaCfg config.KafkaConfig) batchSettings {
+	settings := batchSettings{size: max(kafkaCfg.BatchSize, 1), workers: max(kafkaCfg.BatchWorkers, 1)}
+	if settings.size > 1 {
+		settings.interval, _ = time.ParseDuration(kafkaCfg.BatchInterval)
+	}
+	return settings
+}
+
+// batchItem — сообщение пакета и результат его проверки
+type batchItem struct {
+	msg   *sarama.ConsumerMessage
+	order models.Order
+	perr  *processingError // сообщение отклонено и должно уйти в DLQ
+	done  bool             // сообщение обработано и не повторяется при повторе пакета
+}
+
+// consumeBatches — пакетный режим ConsumeClaim. Сообщения партиции копятся до batch.size штук
+// или в течение batch.interval с первого сообщения пакета, затем пакет обрабатывается целиком,
+// и только после этого отмечается смещение его последнего сообщения.
+func (h *groupHandler) consumeBatches(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	batch := make([]*sarama.ConsumerMessage, 0, h.batch.size)
+	var deadline <-chan time.Time
+
+	flush := func() bool {
+		deadline = nil
+		if len(batch) == 0 {
+			return true
+		}
+		if !h.processBatchWithRetry(session, batch) {
+			return false
+		}
+		session.MarkMessage(batch[len(batch)-1], "")
+		batch = batch[:0]
+		return true
+	}
+
+	for {
+		select {
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				flush()
+				return nil
+			}
+			// Сообщение из топика повторов, которое ещё рано обрабатывать, не задерживает накопленный пакет
+			if attemptDelay(msg) > 0 && !flush() {
+				return nil
+			}
+			if !waitForAttempt(session, msg) {
+				return nil
+			}
+
+			batch = append(batch, msg)
+			if len(batch) == 1 {
+				deadline = time.After(h.batch.interval)
+			}
+			if len(batch) >= h.batch.size && !flush() {
+				return nil
+			}
+		case <-deadline:
+			if !flush() {
+				return nil
+			}
+		case <-session.Context().Done():
+			return nil
+		}
+	}
+}
+
+// processBatchWithRetry обрабатывает пакет до успеха. Сообщения разбираются один раз, а при повторе
+// пакета обрабатываются только те, что ещё не сохранены и не переданы в DLQ или топик повторов.
+// false — сессия завершилась раньше, и смещения пакета не отмечаются.
+func (h *groupHandler) processBatchWithRetry(session sarama.ConsumerGroupSession, batch []*sarama.ConsumerMessage) bool {
+	items := h.prepareBatch(batch)
+	first := batch[0]
+	return h.retryUntilDone(session, func() error { return h.handleBatch(h.ctx, items) },
+		zap.String("topic", first.Topic),
+		zap.Int32("partition", first.Partition),
+		zap.Int64("offset", first.Offset),
+		zap.Int("batch_size", len(batch)))
+}
+
+// handleBatch отправляет отклонённые сообщения пакета в DLQ в порядке пакета и сохраняет прошедшие
+// проверку заказы одной транзакцией. Если транзакция не удалась, заказы сохраняются по одному,
+// чтобы ошибка одного заказа не задерживала остальные. Обработанные сообщения помечаются done;
+// ошибка означает, что оставшиеся нужно повторить.
+func (h *groupHandler) handleBatch(ctx context.Context, items []batchItem) error {
+	pending := make([]*batchItem, 0, len(items))
+	for i := range items {
+		item := &items[i]
+		if item.done {
+			continue
+		}
+		if item.perr == nil {
+			pending = append(pending, item)
+			continue
+		}
+		if err := h.sendToDLQ(item.msg, item.perr); err != nil {
+			return err
+		}
+		item.done = true
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	keyed := make([]repository.KeyedOrder, len(pending))
+	for i, item := range pending {
+		keyed[i] = repository.KeyedOrder{MessageKey: messageKey(item.msg), Order: item.order}
+	}
+	results, err := h.db.AddOrdersOnce(keyed)
+	if err != nil {
+		h.logger.Warn("Failed to save batch to DB, saving orders one by one",
+			zap.Error(err),
+			zap.Int("batch_size", len(pending)))
+		for _, item := range pending {
+			if err := h.persistOrder(ctx, item.msg, item.order); err != nil {
+				return err
+			}
+			item.done = true
+		}
+		return nil
+	}
+
+	orders := make([]models.Order, 0, len(pending))
+	var redelivered, existing int
+	for i, item := range pending {
+		item.done = true
+		switch {
+		case results[i] == nil:
+			orders = append(orders, item.order)
+		case errors.Is(results[i], repository.ErrMessageProcessed):
+			redelivered++
+		default:
+			existing++
+		}
+	}
+	stats.processed.Add(uint64(len(orders)))
+	stats.redelivered.Add(uint64(redelivered))
+	stats.existingOrders.Add(uint64(existing))
+
+	if len(orders) > 0 {
+		// Все обращения к кэшу ограничены таймаутом операции и отменяются вместе с потребителем
+		opCtx, cancel := context.WithTimeout(ctx, operationTimeout)
+		defer cancel()
+
+		if err := h.appCache.SaveOrders(opCtx, orders); err != nil {
+			h.logger.Error("Failed to save batch to cache",
+				zap.Error(err),
+				zap.Int("orders", len(orders)))
+			// Заказы уже в БД: при промахе их дочитает read-through
+		}
+	}
+
+	h.logger.Info("Successfully processed batch",
+		zap.Int("messages", len(items)),
+		zap.Int("stored", len(orders)),
+		zap.Int("redelivered", redelivered),
+		zap.Int("existing_orders", existing))
+	return nil
+}
+
+// prepareBatch разбирает сообщения пакета и проверяет заказы в batch.workers потоках.
+// Проверка не имеет побочных эффектов, поэтому потоки только распределяют работу.
+func (h *groupHandler) prepareBatch(batch []*sarama.ConsumerMessage) []batchItem {
+	items := make([]batchItem, len(batch))
+	for i, msg := range batch {
+		items[i].msg = msg
+		items[i].order, items[i].perr = h.parseMessage(msg)
+	}
+
+	var wg sync.WaitGroup
+	for _, lane := range assignLanes(items, h.batch.workers) {
+		if len(lane) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, i := range lane {
+				items[i].perr = h.validateOrder(items[i].order)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return items
+}
+
+// assignLanes распределяет разобранные сообщения по workers потокам по ключу: ключу Kafka,
+// а без него — order_uid заказа, так что сообщения одного заказа проверяются одним потоком
+// в порядке пакета. Отклонённые при разборе сообщения в потоки не попадают.
+func assignLanes(items []batchItem, workers int) [][]int {
+	lanes := make([][]int, max(min(workers, len(items)), 1))
+	for i, item := range items {
+		if item.perr != nil {
+			continue
+		}
+		key := item.msg.Key
+		if len(key) == 0 {
+			key = []byte(item.order.OrderUID)
+		}
+		lane := workerFor(key, len(lanes))
+		lanes[lane] = append(lanes[lane], i)
+	}
+	return lanes
+}
+
+// workerFor выбирает поток по ключу сообщения
+func workerFor(key []byte, workers int) int {
+	if workers <= 1 {
+		return 0
+	}
+	hash := fnv.New32a()
+	hash.Write(key)
+	return int(hash.Sum32() % uint32(workers))
+}
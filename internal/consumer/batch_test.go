@@ -0,0 +1,217 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/datagenerators"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dlqFromOffset проверяет, что в DLQ отправлено сообщение с заданным смещением
+func dlqFromOffset(offset string) mocks.MessageChecker {
+	return func(msg *sarama.ProducerMessage) error {
+		for _, hdr := range msg.Headers {
+			if string(hdr.Key) == headerDLQSourceOffset && string(hdr.Value) == offset {
+				return nil
+			}
+		}
+		return errors.New("unexpected DLQ message, want offset " + offset)
+	}
+}
+
+// assertInOrder проверяет, что поток lane содержит сообщения first и second именно в этом порядке
+func assertInOrder(t *testing.T, lane []int, first, second int) {
+	t.Helper()
+	firstPos, secondPos := slices.Index(lane, first), slices.Index(lane, second)
+	require.NotEqual(t, -1, firstPos, "message %d not in lane %v", first, lane)
+	require.NotEqual(t, -1, secondPos, "message %d not in lane %v", second, lane)
+	assert.Less(t, firstPos, secondPos)
+}
+
+func TestConsumeBatches_SavesAndMarksLast(t *testing.T) {
+	store := newFakeStore()
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	h := newTestHandler(t, store, producer)
+	h.batch = batchSettings{size: 3, interval: time.Hour, workers: 2}
+
+	var orders []string
+	var msgs []*sarama.ConsumerMessage
+	for i := range 3 {
+		order := datagenerators.GenerateOrder()
+		orders = append(orders, order.OrderUID)
+		msgs = append(msgs, orderMessage(t, int64(10+i), order))
+	}
+	claim := newFakeClaim(msgs...)
+	close(claim.messages)
+	session := newFakeSession(context.Background())
+
+	before := CurrentStats()
+	require.NoError(t, h.ConsumeClaim(session, claim))
+
+	// Отмечается только последнее сообщение пакета
+	assert.Equal(t, []int64{12}, session.markedOffsets())
+	assert.Equal(t, uint64(3), CurrentStats().Processed-before.Processed)
+	for _, uid := range orders {
+		assert.Contains(t, store.stored(), uid)
+		cached, err := h.appCache.OrderExists(context.Background(), uid)
+		require.NoError(t, err)
+		assert.True(t, cached)
+	}
+}
+
+func TestConsumeBatches_FlushesOnInterval(t *testing.T) {
+	store := newFakeStore()
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	h := newTestHandler(t, store, producer)
+	h.batch = batchSettings{size: 10, interval: 20 * time.Millisecond, workers: 2}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	session := newFakeSession(ctx)
+	claim := newFakeClaim(
+		orderMessage(t, 0, datagenerators.GenerateOrder()),
+		orderMessage(t, 1, datagenerators.GenerateOrder()),
+	)
+
+	// Неполный пакет сохраняется по истечении интервала
+	done := consumeAsync(h, session, claim)
+	assert.Eventually(t, func() bool { return len(session.markedOffsets()) == 1 }, time.Second, 10*time.Millisecond)
+	cancel()
+	waitDone(t, done)
+
+	assert.Equal(t, []int64{1}, session.markedOffsets())
+	assert.Len(t, store.stored(), 2)
+}
+
+func TestHandleBatch_DLQSendsNotRepeated(t *testing.T) {
+	store := newFakeStore()
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	// Первое отклонённое сообщение доставлено, второе — со второй попытки
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(dlqFromOffset("0"))
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndFail(dlqFromOffset("2"), sarama.ErrOutOfBrokers)
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(dlqFromOffset("2"))
+	h := newTestHandler(t, store, producer)
+	h.batch = batchSettings{size: 3, interval: time.Hour, workers: 2}
+
+	order := datagenerators.GenerateOrder()
+	items := h.prepareBatch([]*sarama.ConsumerMessage{
+		{Topic: testTopic, Offset: 0, Value: []byte(`{"order_uid":`)},
+		orderMessage(t, 1, order),
+		{Topic: testTopic, Offset: 2},
+	})
+
+	err := h.handleBatch(context.Background(), items)
+	assert.ErrorIs(t, err, sarama.ErrOutOfBrokers)
+	assert.Empty(t, store.stored())
+
+	// При повторе пакета уже отправленное в DLQ сообщение не отправляется снова
+	require.NoError(t, h.handleBatch(context.Background(), items))
+	assert.Contains(t, store.stored(), order.OrderUID)
+
+	// Обработанный пакет больше ничего не отправляет и не сохраняет
+	before := CurrentStats()
+	require.NoError(t, h.handleBatch(context.Background(), items))
+	assert.Equal(t, before, CurrentStats())
+}
+
+func TestHandleBatch_CountsDuplicates(t *testing.T) {
+	store := newFakeStore()
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	h := newTestHandler(t, store, producer)
+	h.batch = batchSettings{size: 3, interval: time.Hour, workers: 2}
+
+	saved, fresh := datagenerators.GenerateOrder(), datagenerators.GenerateOrder()
+	redelivered := orderMessage(t, 0, saved)
+	require.NoError(t, store.AddOrderOnce(messageKey(redelivered), saved))
+
+	before := CurrentStats()
+	items := h.prepareBatch([]*sarama.ConsumerMessage{
+		redelivered,
+		orderMessage(t, 1, saved), // тот же заказ из другого сообщения
+		orderMessage(t, 2, fresh),
+	})
+	require.NoError(t, h.handleBatch(context.Background(), items))
+	after := CurrentStats()
+
+	assert.Equal(t, uint64(1), after.Processed-before.Processed)
+	assert.Equal(t, uint64(1), after.Redelivered-before.Redelivered)
+	assert.Equal(t, uint64(1), after.ExistingOrders-before.ExistingOrders)
+	assert.Contains(t, store.stored(), fresh.OrderUID)
+}
+
+func TestHandleBatch_FallsBackToOneByOne(t *testing.T) {
+	store := newFakeStore()
+	store.batchErr = errors.New("deadlock detected")
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	h := newTestHandler(t, store, producer)
+	h.batch = batchSettings{size: 2, interval: time.Hour, workers: 2}
+
+	first, second := datagenerators.GenerateOrder(), datagenerators.GenerateOrder()
+	items := h.prepareBatch([]*sarama.ConsumerMessage{orderMessage(t, 0, first), orderMessage(t, 1, second)})
+	require.NoError(t, h.handleBatch(context.Background(), items))
+
+	assert.Contains(t, store.stored(), first.OrderUID)
+	assert.Contains(t, store.stored(), second.OrderUID)
+	for _, item := range items {
+		assert.True(t, item.done)
+	}
+}
+
+func TestAssignLanes_RoutesByKey(t *testing.T) {
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	h := newTestHandler(t, newFakeStore(), producer)
+
+	keyed, keyless := datagenerators.GenerateOrder(), datagenerators.GenerateOrder()
+	keyedMsg := func(offset int64) *sarama.ConsumerMessage {
+		msg := orderMessage(t, offset, datagenerators.GenerateOrder())
+		msg.Key = []byte(keyed.OrderUID)
+		return msg
+	}
+	keylessMsg := func(offset int64) *sarama.ConsumerMessage {
+		msg := orderMessage(t, offset, keyless)
+		msg.Key = nil
+		return msg
+	}
+	msgs := []*sarama.ConsumerMessage{
+		keyedMsg(0), keylessMsg(1), keyedMsg(2), keylessMsg(3),
+		{Topic: testTopic, Offset: 4, Value: []byte("not json")},
+	}
+
+	items := make([]batchItem, len(msgs))
+	for i, msg := range msgs {
+		items[i].msg = msg
+		items[i].order, items[i].perr = h.parseMessage(msg)
+	}
+
+	const workers = 4
+	lanes := assignLanes(items, workers)
+	require.Len(t, lanes, workers)
+
+	// Сообщения с одним ключом Kafka и без ключа с одним order_uid попадают в один поток в порядке пакета;
+	// неразобранное сообщение в потоки не попадает
+	assertInOrder(t, lanes[workerFor([]byte(keyed.OrderUID), workers)], 0, 2)
+	assertInOrder(t, lanes[workerFor([]byte(keyless.OrderUID), workers)], 1, 3)
+
+	var assigned int
+	for _, lane := range lanes {
+		assigned += len(lane)
+	}
+	assert.Equal(t, 4, assigned)
+
+	// Пакет меньше числа потоков не создаёт пустых потоков
+	assert.Len(t, assignLanes(items[:2], workers), 2)
+	assert.Equal(t, 0, workerFor([]byte("any"), 1))
+}
@@ -3,32 +3,35 @@ package consumer
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/IBM/sarama"
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/config"
 	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/cache"
 	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/models"
 	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/repository"
 	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/service"
-	"github.com/IBM/sarama"
 	"go.uber.org/zap"
 )
 
 const (
-	orderTopic = "orders"
 	//dlqTopic         = "orders.dlq"
 	operationTimeout = 30 * time.Second
 	reconnectDelay   = 5 * time.Second
+	// retryDelay — пауза перед повторной обработкой сообщения, которое не удалось переслать
+	// в топик повторов или DLQ (недоступность Kafka)
+	retryDelay = 5 * time.Second
 )
 
-// Subscribe подписывается на сообщения Kafka и обрабатывает их.
+// Subscribe подписывается на топик заказов в группе потребителей kafkaCfg.GroupID и обрабатывает сообщения.
 func Subscribe(
 	ctx context.Context,
 	appCache cache.Cache,
 	db *repository.OrdersRepo,
 	logger *zap.Logger,
-	brokers []string,
-	dlqTopic string, // ← новая топика для DLQ
+	kafkaCfg config.KafkaConfig,
 ) error {
 
 	if appCache == nil {
@@ -42,11 +45,12 @@ func Subscribe(
 	}
 	// Создаем валидатор
 	validator := service.NewOrderValidator()
-	// Восстанавливаем кеш из БД при старте
-	logger.Info("Restoring cache from database...")
-	if err := restoreCacheFromDB(ctx, appCache, db, logger); err != nil {
-		return fmt.Errorf("failed to restore cache from DB: %w", err)
+
+	tiers, err := newRetryTiers(kafkaCfg.Topic, kafkaCfg.RetryDelays)
+	if err != nil {
+		return err
 	}
+	batch := newBatchSettings(kafkaCfg)
 
 	for {
 		select {
@@ -54,9 +58,13 @@ func Subscribe(
 			logger.Info("Consumer shutting down due to context cancellation")
 			return nil
 		default:
-			if err := runConsumer(ctx, appCache, db, logger, brokers, validator, dlqTopic); err != nil {
+			if err := runConsumer(ctx, appCache, db, logger, kafkaCfg, validator, tiers, batch); err != nil {
 				logger.Error("Consumer error, reconnecting", zap.Error(err), zap.Duration("delay", reconnectDelay))
-				time.Sleep(reconnectDelay)
+				select {
+				case <-time.After(reconnectDelay):
+				case <-ctx.Done():
+					return nil
+				}
 				continue
 			}
 			return nil
@@ -70,154 +78,257 @@ func runConsumer(
 	appCache cache.Cache,
 	db *repository.OrdersRepo,
 	logger *zap.Logger,
-	brokers []string,
+	kafkaCfg config.KafkaConfig,
 	validator *service.OrderValidator,
-	dlqTopic string,
+	tiers []retryTier,
+	batch batchSettings,
 ) error {
-	// Создаем асинхронного продюсера для DLQ
-	producer, err := sarama.NewAsyncProducer(brokers, createProducerConfig())
+	// Создаем продюсера для DLQ
+	producer, err := sarama.NewSyncProducer(kafkaCfg.Brokers, createProducerConfig())
 	if err != nil {
 		return fmt.Errorf("failed to create DLQ producer: %w", err)
 	}
 	defer safeClose(producer, "dlq producer", logger)
-	// Подключаемся к Kafka
-	consumer, err := sarama.NewConsumer(brokers, createConsumerConfig())
+	// Подключаемся к Kafka в составе группы
+	group, err := sarama.NewConsumerGroup(kafkaCfg.Brokers, kafkaCfg.GroupID, createConsumerConfig())
 	if err != nil {
-		return fmt.Errorf("failed to connect consumer: %w", err)
+		return fmt.Errorf("failed to create consumer group: %w", err)
 	}
-	defer safeClose(consumer, "consumer", logger)
+	defer safeClose(group, "consumer group", logger)
 
-	partitionConsumer, err := consumer.ConsumePartition(orderTopic, 0, sarama.OffsetNewest)
-	if err != nil {
-		return fmt.Errorf("failed to consume partition: %w", err)
+	go func() {
+		for err := range group.Errors() {
+			logger.Error("Kafka consumer error", zap.Error(err))
+		}
+	}()
+
+	handler := &groupHandler{
+		ctx:       ctx,
+		appCache:  appCache,
+		db:        db,
+		logger:    logger,
+		validator: validator,
+		producer:  producer,
+		dlqTopic:  kafkaCfg.DlqTopic,
+		instance:  consumerInstance(kafkaCfg.GroupID),
+
+		retryTiers: tiers,
+		batch:      batch,
 	}
-	defer safeClose(partitionConsumer, "partition consumer", logger)
+	// Топики повторов читаются той же группой: их партиции делятся между репликами так же, как основной топик
+	topics := append([]string{kafkaCfg.Topic}, retryTopics(tiers)...)
 
 	logger.Info("Consumer subscribed to Kafka",
-		zap.String("topic", orderTopic),
-		zap.String("dlq_topic", dlqTopic),
-		zap.Strings("brokers", brokers))
-
+		zap.String("topic", kafkaCfg.Topic),
+		zap.String("group_id", kafkaCfg.GroupID),
+		zap.Strings("retry_topics", retryTopics(tiers)),
+		zap.String("dlq_topic", kafkaCfg.DlqTopic),
+		zap.Int("batch_size", batch.size),
+		zap.Int("batch_workers", batch.workers),
+		zap.Strings("brokers", kafkaCfg.Brokers))
+
+	// Consume держит одну сессию группы и возвращается при ребалансировке; затем входим в группу заново
 	for {
-		select {
-		case <-ctx.Done():
+		if err := group.Consume(ctx, topics, handler); err != nil {
+			if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+				return nil
+			}
+			return fmt.Errorf("consumer group session failed: %w", err)
+		}
+		if ctx.Err() != nil {
 			logger.Info("Consumer shutting down")
 			return nil
+		}
+	}
+}
 
-		case err := <-partitionConsumer.Errors():
-			if err != nil {
-				logger.Error("Kafka consumer error", zap.Error(err))
-			}
+// orderStore — запись заказов вместе с ключами идемпотентности сообщений; реализуется repository.OrdersRepo
+type orderStore interface {
+	AddOrderOnce(messageKey string, order models.Order) error
+	AddOrdersOnce(batch []repository.KeyedOrder) ([]error, error)
+}
 
-		case msg, ok := <-partitionConsumer.Messages():
+// groupHandler обрабатывает сообщения партиций, назначенных реплике в текущей сессии группы.
+// Смещение сообщения отмечается только после того, как заказ сохранён в БД (или сообщение
+// отправлено в топик повторов или DLQ), поэтому после перезапуска или ребалансировки
+// необработанные сообщения читаются повторно, а не пропускаются.
+type groupHandler struct {
+	// ctx — контекст потребителя: ребалансировка не прерывает обработку начатого сообщения
+	ctx       context.Context
+	appCache  cache.Cache
+	db        orderStore
+	logger    *zap.Logger
+	validator *service.OrderValidator
+	producer  sarama.SyncProducer
+	dlqTopic  string
+	instance  string // идентификатор экземпляра для заголовка dlq_consumer
+
+	// Ступени повторов при ошибке сохранения в БД, по возрастанию задержки
+	retryTiers []retryTier
+	// Пакетная обработка (см. consumeBatches); при batch.size 1 сообщения обрабатываются по одному
+	batch batchSettings
+}
+
+// Setup вызывается в начале сессии после назначения партиций
+func (h *groupHandler) Setup(session sarama.ConsumerGroupSession) error {
+	h.logger.Info("Consumer group session started",
+		zap.Int32("generation", session.GenerationID()),
+		zap.Any("partitions", session.Claims()))
+	return nil
+}
+
+// Cleanup вызывается после завершения всех ConsumeClaim сессии, перед коммитом отмеченных смещений
+func (h *groupHandler) Cleanup(session sarama.ConsumerGroupSession) error {
+	h.logger.Info("Consumer group session ended", zap.Int32("generation", session.GenerationID()))
+	return nil
+}
+
+// ConsumeClaim обрабатывает сообщения одной партиции по порядку. Сообщение из топика повторов
+// обрабатывается не раньше времени из его заголовка. Сообщение, которое не удалось переслать
+// в топик повторов или DLQ, обрабатывается повторно, пока не получится или пока партиция
+// не отойдёт другой реплике. При batch.size > 1 сообщения обрабатываются пакетами (см. consumeBatches).
+func (h *groupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	if h.batch.size > 1 {
+		return h.consumeBatches(session, claim)
+	}
+	for {
+		select {
+		case msg, ok := <-claim.Messages():
 			if !ok {
-				logger.Info("Partition consumer channel closed")
-				return fmt.Errorf("partition consumer channel closed")
+				return nil
 			}
-			if msg != nil {
-				handleMessage(ctx, msg, appCache, db, logger, validator, producer, dlqTopic)
+			if !waitForAttempt(session, msg) || !h.processWithRetry(session, msg) {
+				return nil
 			}
+			session.MarkMessage(msg, "")
+		case <-session.Context().Done():
+			return nil
 		}
 	}
 }
 
-// restoreCacheFromDB восстанавливает кеш из базы данных при старте
-func restoreCacheFromDB(ctx context.Context, appCache cache.Cache, db *repository.OrdersRepo, logger *zap.Logger) error {
-	ctx, cancel := context.WithTimeout(ctx, operationTimeout)
-	defer cancel()
+// processWithRetry обрабатывает сообщение до успеха. false — сессия завершилась раньше:
+// смещение не отмечается, и сообщение получит реплика, которой достанется партиция.
+func (h *groupHandler) processWithRetry(session sarama.ConsumerGroupSession, msg *sarama.ConsumerMessage) bool {
+	return h.retryUntilDone(session, func() error { return h.handleMessage(h.ctx, msg) },
+		zap.String("topic", msg.Topic),
+		zap.Int32("partition", msg.Partition),
+		zap.Int64("offset", msg.Offset))
+}
 
-	orders, err := db.GetOrders()
-	if err != nil {
-		return fmt.Errorf("failed to get orders from DB: %w", err)
-	}
+// retryUntilDone вызывает handle, пока он не завершится без ошибки, с паузой retryDelay между попытками.
+// false — сессия завершилась раньше.
+func (h *groupHandler) retryUntilDone(session sarama.ConsumerGroupSession, handle func() error, fields ...zap.Field) bool {
+	for {
+		err := handle()
+		if err == nil {
+			return true
+		}
+		h.logger.Error("Failed to process message, retrying",
+			append(fields, zap.Error(err), zap.Duration("delay", retryDelay))...)
 
-	if clearable, ok := appCache.(interface{ Clear() error }); ok {
-		if err := clearable.Clear(); err != nil {
-			logger.Warn("Failed to clear cache", zap.Error(err))
+		select {
+		case <-time.After(retryDelay):
+		case <-session.Context().Done():
+			return false
 		}
 	}
-	successCount := 0
-	for _, order := range orders {
-		if err := appCache.SaveOrder(order); err != nil {
-			logger.Error("Failed to restore order to cache",
-				zap.String("order_uid", order.OrderUID),
-				zap.Error(err))
-			continue
-		}
-		successCount++
+}
+
+// handleMessage обрабатывает сообщение из Kafka.
+// Ошибка означает, что сообщение не обработано и его нужно повторить.
+func (h *groupHandler) handleMessage(ctx context.Context, msg *sarama.ConsumerMessage) error {
+	order, perr := h.decodeMessage(msg)
+	if perr != nil {
+		return h.sendToDLQ(msg, perr)
 	}
+	return h.persistOrder(ctx, msg, order)
+}
 
-	logger.Info("Cache restored from database",
-		zap.Int("successful_restorations", successCount),
-		zap.Int("orders_count", len(orders)))
-	return nil
+// decodeMessage разбирает и проверяет заказ из сообщения; ошибка — сообщение нужно отправить в DLQ
+func (h *groupHandler) decodeMessage(msg *sarama.ConsumerMessage) (models.Order, *processingError) {
+	order, perr := h.parseMessage(msg)
+	if perr != nil {
+		return order, perr
+	}
+	return order, h.validateOrder(order)
 }
 
-// handleMessage обрабатывает сообщение из Kafka.
-func handleMessage(
-	ctx context.Context,
-	msg *sarama.ConsumerMessage,
-	appCache cache.Cache,
-	db *repository.OrdersRepo,
-	logger *zap.Logger,
-	validator *service.OrderValidator,
-	producer sarama.AsyncProducer,
-	dlqTopic string,
-) {
+// parseMessage разбирает заказ из сообщения и проверяет, что у него есть OrderUID
+func (h *groupHandler) parseMessage(msg *sarama.ConsumerMessage) (models.Order, *processingError) {
+	var order models.Order
+
 	// Проверяем, что сообщение не пустое
 	if msg == nil || len(msg.Value) == 0 {
-		sendToDLQ(producer, dlqTopic, msg, "empty message")
-		return
+		return order, rejectMessage(ReasonEmptyMessage, errors.New("empty message"))
 	}
 
-	var order models.Order
 	if err := json.Unmarshal(msg.Value, &order); err != nil {
-		logger.Error("Failed to unmarshal message", zap.Error(err), zap.ByteString("raw", msg.Value))
-		sendToDLQ(producer, dlqTopic, msg, fmt.Sprintf("unmarshal error: %v", err))
-		return
+		h.logger.Error("Failed to unmarshal message", zap.Error(err), zap.ByteString("raw", msg.Value))
+		return order, rejectMessage(ReasonMalformedJSON, fmt.Errorf("unmarshal error: %w", err))
 	}
 
 	// Валидация OrderUID
 	if order.OrderUID == "" {
-		sendToDLQ(producer, dlqTopic, msg, "empty OrderUID")
-		return
+		return order, rejectMessage(ReasonMissingUID, errors.New("empty OrderUID"))
 	}
+	return order, nil
+}
 
-	// Валидация структуры
-	if !validator.ValidateOrder(order) {
-		sendToDLQ(producer, dlqTopic, msg, "invalid order data")
-		return
-	}
-
-	// Проверка дубликата
-	exists, err := appCache.OrderExists(order.OrderUID)
-	if err != nil {
-		logger.Warn("Failed to check cache",
-			zap.String("order_uid", order.OrderUID), zap.Error(err))
-	} else if exists {
-		logger.Debug("Duplicate order skipped",
-			zap.String("order_uid", order.OrderUID))
-		return
+// validateOrder проверяет поля разобранного заказа
+func (h *groupHandler) validateOrder(order models.Order) *processingError {
+	if fields := h.validator.InvalidFields(order); len(fields) > 0 {
+		perr := rejectMessage(ReasonValidationFailed, fmt.Errorf("invalid order data: %d invalid fields", len(fields)))
+		perr.fields = fields
+		return perr
 	}
+	return nil
+}
 
-	// Сохранение в БД и кеш
-	if err := db.AddOrder(order); err != nil {
-		logger.Error("Failed to save to DB",
+// persistOrder сохраняет проверенный заказ из сообщения msg в БД и кэш.
+// Ошибка означает, что сообщение не обработано и его нужно повторить.
+func (h *groupHandler) persistOrder(ctx context.Context, msg *sarama.ConsumerMessage, order models.Order) error {
+	// Заказ сохраняется в одной транзакции с ключом сообщения, и дубликаты распознаются только там:
+	// кэш может не знать о сохранённом заказе (вытеснение, TTL) или знать о несохранённом.
+	// При ошибках БД, кроме дубликатов, сообщение уходит на следующую ступень повторов
+	key := messageKey(msg)
+	err := h.db.AddOrderOnce(key, order)
+	switch {
+	case errors.Is(err, repository.ErrMessageProcessed):
+		// Повторная доставка: смещение не успело закоммититься до перезапуска или ребалансировки
+		stats.redelivered.Add(1)
+		h.logger.Info("Redelivered message skipped",
+			zap.String("order_uid", order.OrderUID),
+			zap.String("message_key", key))
+		return nil
+	case errors.Is(err, repository.ErrOrderExists):
+		// Заказ уже сохранён из другого сообщения: ключ записан, сохранённый заказ не меняется
+		stats.existingOrders.Add(1)
+		h.logger.Warn("Order already saved from another message, skipped",
+			zap.String("order_uid", order.OrderUID),
+			zap.String("message_key", key))
+		return nil
+	case err != nil:
+		h.logger.Warn("Failed to save to DB",
 			zap.Error(err),
 			zap.String("order_uid", order.OrderUID))
-		// Не отправляем в DLQ — возможно временная ошибка (повтор может помочь)
-		// Можно добавить retry, но не DLQ сразу
-		return
+		return h.scheduleRetry(msg, fmt.Errorf("failed to save order %s to DB: %w", order.OrderUID, err))
 	}
+	stats.processed.Add(1)
 
-	if err := appCache.SaveOrder(order); err != nil {
-		logger.Error("Failed to save to cache",
+	// Все обращения к кэшу ограничены таймаутом операции и отменяются вместе с потребителем
+	opCtx, cancel := context.WithTimeout(ctx, operationTimeout)
+	defer cancel()
+
+	if err := h.appCache.SaveOrder(opCtx, order); err != nil {
+		h.logger.Error("Failed to save to cache",
 			zap.Error(err),
 			zap.String("order_uid", order.OrderUID))
-		// То же — можно не в DLQ, если критично
+		// Заказ уже в БД: при промахе его дочитает read-through
 	}
 
-	logger.Info("Successfully processed order", zap.String("order_uid", order.OrderUID))
+	h.logger.Info("Successfully processed order", zap.String("order_uid", order.OrderUID))
+	return nil
 }
 
 // createConsumerConfig создает конфигурацию для consumer
@@ -225,80 +336,15 @@ func createConsumerConfig() *sarama.Config {
 	config := sarama.NewConfig()
 	config.Version = sarama.V2_8_1_0
 	config.Consumer.Return.Errors = true
-	config.Consumer.Offsets.Initial = sarama.OffsetNewest
+	// Новая группа читает топик с начала; дальше чтение продолжается с закоммиченного смещения
+	config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	// Коммитятся только смещения, отмеченные после обработки; остаток коммитится при завершении сессии
 	config.Consumer.Offsets.AutoCommit.Enable = true
 	config.Consumer.Offsets.AutoCommit.Interval = 1 * time.Second
+	config.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{sarama.NewBalanceStrategySticky()}
 	return config
 }
 
-func sendToDLQ(producer sarama.AsyncProducer, dlqTopic string, msg *sarama.ConsumerMessage, reason string) {
-	var keyEncoder sarama.Encoder
-	if msg.Key != nil {
-		keyEncoder = sarama.ByteEncoder(msg.Key)
-	}
-
-	headers := make([]sarama.RecordHeader, 0, len(msg.Headers)+1)
-	for _, h := range msg.Headers {
-		if h != nil {
-			headers = append(headers, sarama.RecordHeader{
-				Key:   h.Key,
-				Value: h.Value,
-			})
-		}
-	}
-	headers = append(headers, sarama.RecordHeader{
-		Key:   []byte("dlq_reason"),
-		Value: []byte(reason),
-	})
-
-	dlqMsg := &sarama.ProducerMessage{
-		Topic:   dlqTopic,
-		Key:     keyEncoder,
-		Value:   sarama.ByteEncoder(msg.Value),
-		Headers: headers,
-	}
-
-	zap.L().Warn("🔄 Attempting to send to DLQ",
-		zap.String("topic", dlqTopic),
-		zap.String("reason", reason),
-		zap.Int64("offset", msg.Offset))
-
-	// Отправляем с обработкой ошибок
-	select {
-	case producer.Input() <- dlqMsg:
-		zap.L().Info("✅ Message queued for DLQ",
-			zap.String("topic", dlqTopic),
-			zap.String("reason", reason))
-
-		// Ждем подтверждения или ошибки
-		select {
-		case success := <-producer.Successes():
-			zap.L().Info("✅ Message confirmed sent to DLQ",
-				zap.String("topic", success.Topic),
-				zap.Int32("partition", success.Partition),
-				zap.Int64("offset", success.Offset))
-
-		case err := <-producer.Errors():
-			zap.L().Error("❌ Failed to send to DLQ",
-				zap.Error(err),
-				zap.String("reason", reason))
-
-		case <-time.After(5 * time.Second):
-			zap.L().Error("⏰ DLQ confirmation timeout",
-				zap.String("reason", reason))
-		}
-
-	case <-time.After(3 * time.Second):
-		zap.L().Error("⏰ DLQ producer input timeout - producer may be blocked",
-			zap.String("reason", reason))
-
-	case err := <-producer.Errors():
-		zap.L().Error("❌ DLQ producer error",
-			zap.Error(err),
-			zap.String("reason", reason))
-	}
-}
-
 func createProducerConfig() *sarama.Config {
 	config := sarama.NewConfig()
 	config.Version = sarama.V2_8_1_0
@@ -317,11 +363,9 @@ func safeClose(closer interface{}, resourceName string, logger *zap.Logger) {
 
 	var err error
 	switch c := closer.(type) {
-	case sarama.Consumer:
-		err = c.Close()
-	case sarama.PartitionConsumer:
+	case sarama.ConsumerGroup:
 		err = c.Close()
-	case sarama.AsyncProducer:
+	case sarama.SyncProducer:
 		err = c.Close()
 	default:
 		logger.Warn("Unknown resource type for closing", zap.String("resource", resourceName))
@@ -0,0 +1,357 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/config"
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/cache"
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/datagenerators"
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/models"
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/repository"
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const testTopic = "orders"
+
+// fakeStore — orderStore в памяти с той же семантикой ключей идемпотентности, что у OrdersRepo.
+// Пока err не nil, все записи завершаются этой ошибкой; batchErr — только пакетные.
+type fakeStore struct {
+	mu       sync.Mutex
+	err      error
+	batchErr error
+	keys     map[string]bool
+	orders   map[string]models.Order
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{keys: map[string]bool{}, orders: map[string]models.Order{}}
+}
+
+func (s *fakeStore) AddOrderOnce(messageKey string, order models.Order) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	return s.add(messageKey, order)
+}
+
+func (s *fakeStore) AddOrdersOnce(batch []repository.KeyedOrder) ([]error, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	if s.batchErr != nil {
+		return nil, s.batchErr
+	}
+	results := make([]error, len(batch))
+	for i, item := range batch {
+		results[i] = s.add(item.MessageKey, item.Order)
+	}
+	return results, nil
+}
+
+func (s *fakeStore) add(messageKey string, order models.Order) error {
+	if s.keys[messageKey] {
+		return repository.ErrMessageProcessed
+	}
+	s.keys[messageKey] = true
+	if _, ok := s.orders[order.OrderUID]; ok {
+		return repository.ErrOrderExists
+	}
+	s.orders[order.OrderUID] = order
+	return nil
+}
+
+func (s *fakeStore) setErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+func (s *fakeStore) stored() map[string]models.Order {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	orders := make(map[string]models.Order, len(s.orders))
+	for uid, order := range s.orders {
+		orders[uid] = order
+	}
+	return orders
+}
+
+// fakeSession — сессия группы, запоминающая отмеченные смещения.
+// Отмена ctx имитирует ребалансировку: sarama отменяет контекст сессии.
+type fakeSession struct {
+	ctx context.Context
+
+	mu     sync.Mutex
+	marked []int64
+}
+
+func newFakeSession(ctx context.Context) *fakeSession {
+	return &fakeSession{ctx: ctx}
+}
+
+func (s *fakeSession) Claims() map[string][]int32               { return map[string][]int32{testTopic: {0}} }
+func (s *fakeSession) MemberID() string                         { return "test-member" }
+func (s *fakeSession) GenerationID() int32                      { return 1 }
+func (s *fakeSession) MarkOffset(string, int32, int64, string)  {}
+func (s *fakeSession) Commit()                                  {}
+func (s *fakeSession) ResetOffset(string, int32, int64, string) {}
+func (s *fakeSession) Context() context.Context                 { return s.ctx }
+
+func (s *fakeSession) MarkMessage(msg *sarama.ConsumerMessage, _ string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.marked = append(s.marked, msg.Offset)
+}
+
+func (s *fakeSession) markedOffsets() []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int64(nil), s.marked...)
+}
+
+// fakeClaim — партиция, сообщения которой тест кладёт в messages
+type fakeClaim struct {
+	messages chan *sarama.ConsumerMessage
+}
+
+func newFakeClaim(msgs ...*sarama.ConsumerMessage) *fakeClaim {
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, len(msgs)+1)}
+	for _, msg := range msgs {
+		claim.messages <- msg
+	}
+	return claim
+}
+
+func (c *fakeClaim) Topic() string                            { return testTopic }
+func (c *fakeClaim) Partition() int32                         { return 0 }
+func (c *fakeClaim) InitialOffset() int64                     { return 0 }
+func (c *fakeClaim) HighWaterMarkOffset() int64               { return 0 }
+func (c *fakeClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+
+// newTestHandler создаёт обработчик поверх хранилища в памяти, in-memory кэша и mock-продюсера
+func newTestHandler(t *testing.T, store orderStore, producer sarama.SyncProducer, tiers ...retryTier) *groupHandler {
+	return &groupHandler{
+		ctx:        context.Background(),
+		appCache:   cache.NewInMemoryCache(100, time.Minute),
+		db:         store,
+		logger:     zap.NewNop(),
+		validator:  service.NewOrderValidator(),
+		producer:   producer,
+		dlqTopic:   "orders.dlq",
+		instance:   "test-group/test-host-1",
+		retryTiers: tiers,
+		batch:      batchSettings{size: 1, workers: 1},
+	}
+}
+
+// orderMessage формирует сообщение основного топика с заказом в JSON
+func orderMessage(t *testing.T, offset int64, order models.Order) *sarama.ConsumerMessage {
+	value, err := json.Marshal(order)
+	require.NoError(t, err)
+	return &sarama.ConsumerMessage{
+		Topic:     testTopic,
+		Partition: 0,
+		Offset:    offset,
+		Key:       []byte(order.OrderUID),
+		Value:     value,
+		Timestamp: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+}
+
+// consumeAsync запускает ConsumeClaim и возвращает канал с его результатом
+func consumeAsync(h *groupHandler, session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) <-chan error {
+	done := make(chan error, 1)
+	go func() { done <- h.ConsumeClaim(session, claim) }()
+	return done
+}
+
+// waitDone ждёт завершения ConsumeClaim
+func waitDone(t *testing.T, done <-chan error) {
+	t.Helper()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("ConsumeClaim did not return")
+	}
+}
+
+func TestConsumeClaim_MarksAfterSave(t *testing.T) {
+	store := newFakeStore()
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	h := newTestHandler(t, store, producer)
+
+	first, second := datagenerators.GenerateOrder(), datagenerators.GenerateOrder()
+	claim := newFakeClaim(orderMessage(t, 10, first), orderMessage(t, 11, second))
+	close(claim.messages)
+	session := newFakeSession(context.Background())
+
+	// Закрытый канал партиции завершает ConsumeClaim без ошибки
+	require.NoError(t, h.ConsumeClaim(session, claim))
+
+	assert.Equal(t, []int64{10, 11}, session.markedOffsets())
+	assert.Contains(t, store.stored(), first.OrderUID)
+	assert.Contains(t, store.stored(), second.OrderUID)
+
+	cached, err := h.appCache.OrderExists(context.Background(), first.OrderUID)
+	require.NoError(t, err)
+	assert.True(t, cached)
+}
+
+func TestConsumeClaim_NoMarkOnSaveFailure(t *testing.T) {
+	store := newFakeStore()
+	store.setErr(errors.New("database is unavailable"))
+
+	// Ни топик повторов, ни DLQ недоступны: сообщение некуда передать, и оно остаётся неотмеченным
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	producer.ExpectSendMessageAndFail(sarama.ErrOutOfBrokers)
+	h := newTestHandler(t, store, producer, retryTier{topic: "orders.retry.1m", delay: time.Minute})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	session := newFakeSession(ctx)
+	claim := newFakeClaim(orderMessage(t, 5, datagenerators.GenerateOrder()))
+
+	done := consumeAsync(h, session, claim)
+	// Обработчик ждёт повтора; ребалансировка забирает партицию
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	waitDone(t, done)
+
+	assert.Empty(t, session.markedOffsets())
+	assert.Empty(t, store.stored())
+}
+
+func TestConsumeClaim_SaveFailureForwardedIsMarked(t *testing.T) {
+	store := newFakeStore()
+	store.setErr(errors.New("database is unavailable"))
+
+	// Сообщение передано в топик повторов, поэтому его смещение отмечается
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		if msg.Topic != "orders.retry.1m" {
+			return errors.New("unexpected topic " + msg.Topic)
+		}
+		return nil
+	})
+	h := newTestHandler(t, store, producer, retryTier{topic: "orders.retry.1m", delay: time.Minute})
+
+	claim := newFakeClaim(orderMessage(t, 7, datagenerators.GenerateOrder()))
+	close(claim.messages)
+	session := newFakeSession(context.Background())
+
+	require.NoError(t, h.ConsumeClaim(session, claim))
+	assert.Equal(t, []int64{7}, session.markedOffsets())
+}
+
+func TestConsumeClaim_DBDecidesDuplicates(t *testing.T) {
+	store := newFakeStore()
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	h := newTestHandler(t, store, producer)
+
+	// Заказ есть в кэше, но не в БД: кэш не отменяет сохранение
+	cachedOnly := datagenerators.GenerateOrder()
+	require.NoError(t, h.appCache.SaveOrder(context.Background(), cachedOnly))
+
+	order := datagenerators.GenerateOrder()
+	redelivered := orderMessage(t, 1, order)
+	// Тот же заказ из другого сообщения (ключ идемпотентности — позиция сообщения в топике)
+	other := orderMessage(t, 2, order)
+
+	claim := newFakeClaim(orderMessage(t, 0, cachedOnly), redelivered, redelivered, other)
+	close(claim.messages)
+	session := newFakeSession(context.Background())
+
+	before := CurrentStats()
+	require.NoError(t, h.ConsumeClaim(session, claim))
+	after := CurrentStats()
+
+	assert.Equal(t, []int64{0, 1, 1, 2}, session.markedOffsets())
+	assert.Contains(t, store.stored(), cachedOnly.OrderUID)
+	assert.Equal(t, uint64(2), after.Processed-before.Processed)
+	assert.Equal(t, uint64(1), after.Redelivered-before.Redelivered)
+	assert.Equal(t, uint64(1), after.ExistingOrders-before.ExistingOrders)
+}
+
+func TestConsumeClaim_ExitsOnRebalance(t *testing.T) {
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+
+	t.Run("idle partition", func(t *testing.T) {
+		h := newTestHandler(t, newFakeStore(), producer)
+		ctx, cancel := context.WithCancel(context.Background())
+		session := newFakeSession(ctx)
+
+		done := consumeAsync(h, session, newFakeClaim())
+		cancel()
+		waitDone(t, done)
+		assert.Empty(t, session.markedOffsets())
+	})
+
+	t.Run("waiting for retry attempt", func(t *testing.T) {
+		store := newFakeStore()
+		h := newTestHandler(t, store, producer)
+		ctx, cancel := context.WithCancel(context.Background())
+		session := newFakeSession(ctx)
+
+		// Сообщение ступени повторов, время обработки которого ещё не наступило
+		msg := orderMessage(t, 3, datagenerators.GenerateOrder())
+		msg.Headers = []*sarama.RecordHeader{{
+			Key:   []byte(headerRetryNextAttempt),
+			Value: []byte(time.Now().Add(time.Hour).UTC().Format(time.RFC3339Nano)),
+		}}
+
+		done := consumeAsync(h, session, newFakeClaim(msg))
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+		waitDone(t, done)
+
+		assert.Empty(t, session.markedOffsets())
+		assert.Empty(t, store.stored())
+	})
+}
+
+func TestSubscribe_ExitsOnContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	kafkaCfg := config.KafkaConfig{
+		Brokers:     []string{"localhost:9092"},
+		Topic:       testTopic,
+		DlqTopic:    "orders.dlq",
+		GroupID:     "test-group",
+		RetryDelays: []string{"1m"},
+		BatchSize:   1,
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- Subscribe(ctx, cache.NewInMemoryCache(10, time.Minute), &repository.OrdersRepo{}, zap.NewNop(), kafkaCfg)
+	}()
+
+	// Отменённый контекст завершает потребителя, не подключаясь к Kafka
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Subscribe did not return after context cancellation")
+	}
+}
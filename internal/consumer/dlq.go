@@ -0,0 +1,133 @@
+package consumer
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IBM/sarama"
+	"go.uber.org/zap"
+)
+
+// DLQReason — класс ошибки, по которому сообщение попало в DLQ (заголовок dlq_reason).
+// По нему DLQ фильтруется и переобрабатывается автоматически.
+type DLQReason string
+
+const (
+	ReasonEmptyMessage     DLQReason = "empty_message"     // пустое тело сообщения
+	ReasonMalformedJSON    DLQReason = "malformed_json"    // тело не разбирается как заказ
+	ReasonMissingUID       DLQReason = "missing_uid"       // у заказа нет order_uid
+	ReasonValidationFailed DLQReason = "validation_failed" // поля заказа не прошли проверку
+	ReasonPersistFailed    DLQReason = "persist_failed"    // заказ не сохранён в БД после всех повторов
+)
+
+// Заголовки сообщений DLQ; исходные заголовки сообщения сохраняются рядом с ними
+const (
+	headerDLQReason          = "dlq_reason"           // DLQReason
+	headerDLQError           = "dlq_error"            // текст ошибки
+	headerDLQSourceTopic     = "dlq_source_topic"     // топик, из которого прочитано сообщение
+	headerDLQSourcePartition = "dlq_source_partition" // его партиция
+	headerDLQSourceOffset    = "dlq_source_offset"    // и смещение
+	headerDLQSourceTime      = "dlq_source_timestamp" // время записи сообщения в Kafka (RFC 3339, UTC)
+	headerDLQConsumer        = "dlq_consumer"         // экземпляр потребителя: группа/хост-pid
+	headerDLQAttempts        = "dlq_attempts"         // сколько раз сообщение обрабатывалось
+	headerDLQInvalidFields   = "dlq_invalid_fields"   // для validation_failed: JSON-пути полей через запятую
+)
+
+// processingError — ошибка обработки сообщения, после которой оно уходит в DLQ
+type processingError struct {
+	reason DLQReason
+	err    error
+	fields []string // для ReasonValidationFailed — пути невалидных полей
+}
+
+func (e *processingError) Error() string {
+	return fmt.Sprintf("%s: %v", e.reason, e.err)
+}
+
+func (e *processingError) Unwrap() error {
+	return e.err
+}
+
+// rejectMessage возвращает ошибку обработки класса reason
+func rejectMessage(reason DLQReason, err error) *processingError {
+	return &processingError{reason: reason, err: err}
+}
+
+// consumerInstance формирует идентификатор экземпляра потребителя для заголовка dlq_consumer
+func consumerInstance(groupID string) string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s/%s-%d", groupID, host, os.Getpid())
+}
+
+// sendToDLQ отправляет сообщение в DLQ с классом ошибки и метаданными источника и ждёт подтверждения.
+// Ошибка — сообщение не доставлено.
+func (h *groupHandler) sendToDLQ(msg *sarama.ConsumerMessage, perr *processingError) error {
+	var keyEncoder sarama.Encoder
+	if msg.Key != nil {
+		keyEncoder = sarama.ByteEncoder(msg.Key)
+	}
+
+	headers := make([]sarama.RecordHeader, 0, len(msg.Headers)+9)
+	for _, hdr := range msg.Headers {
+		// Метаданные прошлой отправки в DLQ (при переобработке) заменяются новыми
+		if hdr != nil && !strings.HasPrefix(string(hdr.Key), "dlq_") {
+			headers = append(headers, *hdr)
+		}
+	}
+	headers = append(headers,
+		stringHeader(headerDLQReason, string(perr.reason)),
+		stringHeader(headerDLQError, perr.err.Error()),
+		stringHeader(headerDLQSourceTopic, msg.Topic),
+		stringHeader(headerDLQSourcePartition, strconv.FormatInt(int64(msg.Partition), 10)),
+		stringHeader(headerDLQSourceOffset, strconv.FormatInt(msg.Offset, 10)),
+		stringHeader(headerDLQSourceTime, msg.Timestamp.UTC().Format(time.RFC3339Nano)),
+		stringHeader(headerDLQConsumer, h.instance),
+		stringHeader(headerDLQAttempts, strconv.Itoa(retryAttempt(msg)+1)),
+	)
+	if len(perr.fields) > 0 {
+		headers = append(headers, stringHeader(headerDLQInvalidFields, strings.Join(perr.fields, ",")))
+	}
+
+	dlqMsg := &sarama.ProducerMessage{
+		Topic:   h.dlqTopic,
+		Key:     keyEncoder,
+		Value:   sarama.ByteEncoder(msg.Value),
+		Headers: headers,
+	}
+
+	h.logger.Warn("🔄 Attempting to send to DLQ",
+		zap.String("topic", h.dlqTopic),
+		zap.String("reason", string(perr.reason)),
+		zap.Error(perr.err),
+		zap.Strings("invalid_fields", perr.fields),
+		zap.String("source_topic", msg.Topic),
+		zap.Int32("source_partition", msg.Partition),
+		zap.Int64("offset", msg.Offset))
+
+	// Синхронный продюсер безопасен при одновременной отправке из обработчиков разных партиций
+	partition, offset, err := h.producer.SendMessage(dlqMsg)
+	if err != nil {
+		h.logger.Error("❌ Failed to send to DLQ",
+			zap.Error(err),
+			zap.String("reason", string(perr.reason)))
+		return fmt.Errorf("failed to send message to DLQ: %w", err)
+	}
+
+	stats.deadLettered.Add(1)
+	h.logger.Info("✅ Message confirmed sent to DLQ",
+		zap.String("topic", h.dlqTopic),
+		zap.Int32("partition", partition),
+		zap.Int64("offset", offset))
+	return nil
+}
+
+// stringHeader формирует заголовок сообщения Kafka
+func stringHeader(key, value string) sarama.RecordHeader {
+	return sarama.RecordHeader{Key: []byte(key), Value: []byte(value)}
+}
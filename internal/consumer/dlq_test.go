@@ -0,0 +1,111 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/datagenerators"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleMessage_DLQReasons(t *testing.T) {
+	invalid := datagenerators.GenerateOrder()
+	invalid.Delivery.Email = "not-an-email"
+	invalid.Items[0].ChartID = 0
+	invalidValue, err := json.Marshal(invalid)
+	require.NoError(t, err)
+
+	noUID := datagenerators.GenerateOrder()
+	noUID.OrderUID = ""
+	noUIDValue, err := json.Marshal(noUID)
+	require.NoError(t, err)
+
+	valid := datagenerators.GenerateOrder()
+	validValue, err := json.Marshal(valid)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		value   []byte
+		dbErr   error // ошибка сохранения в БД
+		reason  DLQReason
+		errText string // начало текста dlq_error
+		fields  string // ожидаемый dlq_invalid_fields; пусто — заголовка нет
+	}{
+		{"empty message", nil, nil, ReasonEmptyMessage, "empty message", ""},
+		{"malformed json", []byte(`{"order_uid":`), nil, ReasonMalformedJSON, "unmarshal error:", ""},
+		{"missing order uid", noUIDValue, nil, ReasonMissingUID, "empty OrderUID", ""},
+		{"validation failed", invalidValue, nil, ReasonValidationFailed, "invalid order data: 2 invalid fields", "delivery.email,items[0].chrt_id"},
+		{"permanent db error", validValue, errors.New("value too long"), ReasonPersistFailed, "failed to save order " + valid.OrderUID + " to DB: value too long", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeStore()
+			store.setErr(tt.dbErr)
+
+			var sent *sarama.ProducerMessage
+			producer := mocks.NewSyncProducer(t, nil)
+			defer producer.Close()
+			expectCapture(producer, &sent)
+
+			// Без ступеней повторов ошибка БД сразу ведёт в DLQ
+			h := newTestHandler(t, store, producer)
+			msg := &sarama.ConsumerMessage{
+				Topic:     testTopic,
+				Partition: 3,
+				Offset:    1234,
+				Key:       []byte("order-key"),
+				Value:     tt.value,
+				Timestamp: orderMessage(t, 0, valid).Timestamp,
+				Headers: []*sarama.RecordHeader{
+					{Key: []byte("trace_id"), Value: []byte("abc")},
+					// Метаданные прошлой отправки в DLQ заменяются
+					{Key: []byte(headerDLQReason), Value: []byte("stale")},
+				},
+			}
+			require.NoError(t, h.handleMessage(context.Background(), msg))
+
+			require.NotNil(t, sent)
+			assert.Equal(t, "orders.dlq", sent.Topic)
+			assert.Equal(t, sarama.ByteEncoder("order-key"), sent.Key)
+			assert.Equal(t, sarama.ByteEncoder(tt.value), sent.Value)
+
+			headers := producedHeaders(t, sent)
+			assert.Contains(t, headers[headerDLQError], tt.errText)
+			delete(headers, headerDLQError)
+
+			expected := map[string]string{
+				"trace_id":               "abc",
+				headerDLQReason:          string(tt.reason),
+				headerDLQSourceTopic:     testTopic,
+				headerDLQSourcePartition: "3",
+				headerDLQSourceOffset:    "1234",
+				headerDLQSourceTime:      "2026-01-02T03:04:05Z",
+				headerDLQConsumer:        "test-group/test-host-1",
+				headerDLQAttempts:        "1",
+			}
+			if tt.fields != "" {
+				expected[headerDLQInvalidFields] = tt.fields
+			}
+			assert.Equal(t, expected, headers)
+			assert.Empty(t, store.stored())
+		})
+	}
+}
+
+func TestHandleMessage_DLQSendFailure(t *testing.T) {
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	producer.ExpectSendMessageAndFail(sarama.ErrOutOfBrokers)
+	h := newTestHandler(t, newFakeStore(), producer)
+
+	// Недоставленное в DLQ сообщение обрабатывается повторно
+	err := h.handleMessage(context.Background(), &sarama.ConsumerMessage{Topic: testTopic})
+	assert.ErrorIs(t, err, sarama.ErrOutOfBrokers)
+}
@@ -0,0 +1,172 @@
+package consumer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IBM/sarama"
+	"go.uber.org/zap"
+)
+
+// Заголовки сообщений в топиках повторов
+const (
+	headerRetryAttempt     = "retry_attempt"         // сколько попыток обработки уже завершились ошибкой
+	headerRetryNextAttempt = "retry_next_attempt_at" // не раньше какого времени повторять (RFC 3339, UTC)
+	headerRetryError       = "retry_error"           // ошибка последней попытки
+
+	// headerMessageID — идентификатор сообщения, заданный продюсером; при первом повторе
+	// проставляется из координат исходного сообщения, чтобы ключ идемпотентности не менялся
+	headerMessageID = "message_id"
+)
+
+// retryTier — ступень повторов: топик и задержка перед обработкой сообщений из него
+type retryTier struct {
+	topic string
+	delay time.Duration
+}
+
+// newRetryTiers строит ступени повторов по задержкам: для топика orders и задержки "1m" — orders.retry.1m
+func newRetryTiers(topic string, delays []string) ([]retryTier, error) {
+	tiers := make([]retryTier, 0, len(delays))
+	for _, d := range delays {
+		d = strings.TrimSpace(d)
+		delay, err := time.ParseDuration(d)
+		if err != nil {
+			return nil, fmt.Errorf("invalid retry delay %q: %w", d, err)
+		}
+		if delay <= 0 {
+			return nil, fmt.Errorf("retry delay must be positive, got %q", d)
+		}
+		tiers = append(tiers, retryTier{topic: fmt.Sprintf("%s.retry.%s", topic, d), delay: delay})
+	}
+	return tiers, nil
+}
+
+// retryTopics возвращает топики ступеней повторов
+func retryTopics(tiers []retryTier) []string {
+	topics := make([]string, len(tiers))
+	for i, tier := range tiers {
+		topics[i] = tier.topic
+	}
+	return topics
+}
+
+// scheduleRetry отправляет сообщение на следующую ступень повторов.
+// После последней ступени (или если повторы выключены) сообщение уходит в DLQ с ошибкой cause.
+func (h *groupHandler) scheduleRetry(msg *sarama.ConsumerMessage, cause error) error {
+	attempt := retryAttempt(msg) + 1
+	if attempt > len(h.retryTiers) {
+		h.logger.Error("Retries exhausted, sending order to DLQ",
+			zap.Int("attempts", attempt),
+			zap.Error(cause))
+		return h.sendToDLQ(msg, rejectMessage(ReasonPersistFailed, cause))
+	}
+
+	tier := h.retryTiers[attempt-1]
+	nextAttempt := time.Now().Add(tier.delay).UTC()
+
+	headers := make([]sarama.RecordHeader, 0, len(msg.Headers)+3)
+	for _, hdr := range msg.Headers {
+		if hdr != nil && !strings.HasPrefix(string(hdr.Key), "retry_") {
+			headers = append(headers, *hdr)
+		}
+	}
+	if _, ok := messageHeader(msg, headerMessageID); !ok {
+		headers = append(headers, sarama.RecordHeader{Key: []byte(headerMessageID), Value: []byte(messageKey(msg))})
+	}
+	headers = append(headers,
+		sarama.RecordHeader{Key: []byte(headerRetryAttempt), Value: []byte(strconv.Itoa(attempt))},
+		sarama.RecordHeader{Key: []byte(headerRetryNextAttempt), Value: []byte(nextAttempt.Format(time.RFC3339Nano))},
+		sarama.RecordHeader{Key: []byte(headerRetryError), Value: []byte(cause.Error())},
+	)
+
+	var key sarama.Encoder
+	if msg.Key != nil {
+		key = sarama.ByteEncoder(msg.Key)
+	}
+	_, _, err := h.producer.SendMessage(&sarama.ProducerMessage{
+		Topic:   tier.topic,
+		Key:     key,
+		Value:   sarama.ByteEncoder(msg.Value),
+		Headers: headers,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send message to retry topic %s: %w", tier.topic, err)
+	}
+
+	stats.retried.Add(1)
+	h.logger.Warn("Order scheduled for retry",
+		zap.String("retry_topic", tier.topic),
+		zap.Int("attempt", attempt),
+		zap.Time("next_attempt_at", nextAttempt),
+		zap.Error(cause))
+	return nil
+}
+
+// waitForAttempt ждёт времени повтора из заголовка сообщения (у сообщений основного топика его нет).
+// Сообщения ступени приходят в порядке отправки с одинаковой задержкой, поэтому ожидание
+// первого из них не задерживает остальные сверх их собственного срока.
+// false — сессия завершилась раньше, и сообщение нужно оставить неотмеченным.
+func waitForAttempt(session sarama.ConsumerGroupSession, msg *sarama.ConsumerMessage) bool {
+	wait := attemptDelay(msg)
+	if wait <= 0 {
+		return true
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-session.Context().Done():
+		return false
+	}
+}
+
+// attemptDelay возвращает, сколько осталось ждать времени повтора из заголовка сообщения (0 — можно обрабатывать)
+func attemptDelay(msg *sarama.ConsumerMessage) time.Duration {
+	raw, ok := messageHeader(msg, headerRetryNextAttempt)
+	if !ok {
+		return 0
+	}
+	nextAttempt, err := time.Parse(time.RFC3339Nano, raw)
+	if err != nil {
+		// Без корректного времени повторяем сразу, чтобы сообщение не застряло
+		return 0
+	}
+	return max(time.Until(nextAttempt), 0)
+}
+
+// retryAttempt возвращает число уже неудачных попыток обработки сообщения (0 — первая попытка)
+func retryAttempt(msg *sarama.ConsumerMessage) int {
+	raw, ok := messageHeader(msg, headerRetryAttempt)
+	if !ok {
+		return 0
+	}
+	attempt, err := strconv.Atoi(raw)
+	if err != nil || attempt < 0 {
+		return 0
+	}
+	return attempt
+}
+
+// messageKey возвращает ключ идемпотентности сообщения: заголовок message_id,
+// а без него — топик, партицию и смещение
+func messageKey(msg *sarama.ConsumerMessage) string {
+	if id, ok := messageHeader(msg, headerMessageID); ok && id != "" {
+		return id
+	}
+	return fmt.Sprintf("%s/%d/%d", msg.Topic, msg.Partition, msg.Offset)
+}
+
+// messageHeader возвращает значение заголовка сообщения
+func messageHeader(msg *sarama.ConsumerMessage, key string) (string, bool) {
+	for _, hdr := range msg.Headers {
+		if hdr != nil && string(hdr.Key) == key {
+			return string(hdr.Value), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,203 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/datagenerators"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expectCapture ждёт отправку одного сообщения и сохраняет его в *sent
+func expectCapture(producer *mocks.SyncProducer, sent **sarama.ProducerMessage) {
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		*sent = msg
+		return nil
+	})
+}
+
+// producedHeaders возвращает заголовки отправленного сообщения; повторяющийся заголовок — ошибка теста
+func producedHeaders(t *testing.T, msg *sarama.ProducerMessage) map[string]string {
+	t.Helper()
+	headers := make(map[string]string, len(msg.Headers))
+	for _, hdr := range msg.Headers {
+		key := string(hdr.Key)
+		require.NotContains(t, headers, key, "duplicate header %s", key)
+		headers[key] = string(hdr.Value)
+	}
+	return headers
+}
+
+// consumed превращает отправленное в топик повторов сообщение в прочитанное из него
+func consumed(t *testing.T, msg *sarama.ProducerMessage, offset int64) *sarama.ConsumerMessage {
+	t.Helper()
+	key, err := msg.Key.Encode()
+	require.NoError(t, err)
+	value, err := msg.Value.Encode()
+	require.NoError(t, err)
+
+	headers := make([]*sarama.RecordHeader, len(msg.Headers))
+	for i := range msg.Headers {
+		headers[i] = &msg.Headers[i]
+	}
+	return &sarama.ConsumerMessage{
+		Topic:     msg.Topic,
+		Offset:    offset,
+		Key:       key,
+		Value:     value,
+		Headers:   headers,
+		Timestamp: time.Now(),
+	}
+}
+
+func TestScheduleRetry_MovesThroughTiers(t *testing.T) {
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	tiers, err := newRetryTiers(testTopic, []string{"1m", "10m"})
+	require.NoError(t, err)
+	h := newTestHandler(t, newFakeStore(), producer, tiers...)
+
+	msg := orderMessage(t, 42, datagenerators.GenerateOrder())
+	msg.Headers = []*sarama.RecordHeader{{Key: []byte("trace_id"), Value: []byte("abc")}}
+
+	// Первая ошибка — на первую ступень; ключ идемпотентности фиксируется по исходному сообщению
+	var sent *sarama.ProducerMessage
+	expectCapture(producer, &sent)
+	before := time.Now()
+	require.NoError(t, h.scheduleRetry(msg, errors.New("db is down")))
+
+	assert.Equal(t, "orders.retry.1m", sent.Topic)
+	retried := consumed(t, sent, 0)
+	assert.Equal(t, msg.Key, retried.Key)
+	assert.Equal(t, msg.Value, retried.Value)
+
+	headers := producedHeaders(t, sent)
+	assert.Equal(t, "abc", headers["trace_id"])
+	assert.Equal(t, "orders/0/42", headers[headerMessageID])
+	assert.Equal(t, "1", headers[headerRetryAttempt])
+	assert.Equal(t, "db is down", headers[headerRetryError])
+	nextAttempt, err := time.Parse(time.RFC3339Nano, headers[headerRetryNextAttempt])
+	require.NoError(t, err)
+	assert.WithinDuration(t, before.Add(time.Minute), nextAttempt, 5*time.Second)
+
+	// Вторая ошибка — на вторую ступень; заголовки повторов заменяются, остальные сохраняются
+	expectCapture(producer, &sent)
+	require.NoError(t, h.scheduleRetry(retried, errors.New("db is still down")))
+
+	assert.Equal(t, "orders.retry.10m", sent.Topic)
+	headers = producedHeaders(t, sent)
+	assert.Equal(t, "abc", headers["trace_id"])
+	assert.Equal(t, "orders/0/42", headers[headerMessageID])
+	assert.Equal(t, "2", headers[headerRetryAttempt])
+	assert.Equal(t, "db is still down", headers[headerRetryError])
+	assert.Equal(t, messageKey(msg), messageKey(consumed(t, sent, 7)))
+}
+
+func TestScheduleRetry_DLQAfterLastTier(t *testing.T) {
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	tiers, err := newRetryTiers(testTopic, []string{"1m", "10m"})
+	require.NoError(t, err)
+	h := newTestHandler(t, newFakeStore(), producer, tiers...)
+
+	msg := orderMessage(t, 9, datagenerators.GenerateOrder())
+	msg.Topic = "orders.retry.10m"
+	msg.Headers = []*sarama.RecordHeader{
+		{Key: []byte(headerMessageID), Value: []byte("orders/0/1")},
+		{Key: []byte(headerRetryAttempt), Value: []byte(strconv.Itoa(len(tiers)))},
+	}
+
+	var sent *sarama.ProducerMessage
+	expectCapture(producer, &sent)
+	require.NoError(t, h.scheduleRetry(msg, errors.New("db is down")))
+
+	assert.Equal(t, "orders.dlq", sent.Topic)
+	headers := producedHeaders(t, sent)
+	assert.Equal(t, string(ReasonPersistFailed), headers[headerDLQReason])
+	assert.Equal(t, "db is down", headers[headerDLQError])
+	assert.Equal(t, "3", headers[headerDLQAttempts])
+	assert.Equal(t, "orders/0/1", headers[headerMessageID])
+}
+
+func TestScheduleRetry_NoTiersGoesToDLQ(t *testing.T) {
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	h := newTestHandler(t, newFakeStore(), producer)
+
+	var sent *sarama.ProducerMessage
+	expectCapture(producer, &sent)
+	require.NoError(t, h.scheduleRetry(orderMessage(t, 1, datagenerators.GenerateOrder()), errors.New("db is down")))
+	assert.Equal(t, "orders.dlq", sent.Topic)
+}
+
+func TestWaitForAttempt_WaitsOutEachTierDelay(t *testing.T) {
+	delays := []string{"50ms", "150ms"}
+	tiers, err := newRetryTiers(testTopic, delays)
+	require.NoError(t, err)
+
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	h := newTestHandler(t, newFakeStore(), producer, tiers...)
+	session := newFakeSession(context.Background())
+
+	msg := orderMessage(t, 1, datagenerators.GenerateOrder())
+	for i, tier := range tiers {
+		var sent *sarama.ProducerMessage
+		expectCapture(producer, &sent)
+		scheduled := time.Now()
+		require.NoError(t, h.scheduleRetry(msg, errors.New("db is down")))
+		require.Equal(t, tier.topic, sent.Topic)
+
+		msg = consumed(t, sent, int64(i))
+		assert.Greater(t, attemptDelay(msg), time.Duration(0))
+		require.True(t, waitForAttempt(session, msg))
+		assert.GreaterOrEqual(t, time.Since(scheduled), tier.delay, delays[i])
+		assert.Zero(t, attemptDelay(msg))
+	}
+}
+
+func TestWaitForAttempt(t *testing.T) {
+	header := func(value string) *sarama.ConsumerMessage {
+		return &sarama.ConsumerMessage{Headers: []*sarama.RecordHeader{
+			{Key: []byte(headerRetryNextAttempt), Value: []byte(value)},
+		}}
+	}
+	session := newFakeSession(context.Background())
+
+	tests := []struct {
+		name string
+		msg  *sarama.ConsumerMessage
+	}{
+		{"main topic message", &sarama.ConsumerMessage{}},
+		{"attempt time passed", header(time.Now().Add(-time.Minute).UTC().Format(time.RFC3339Nano))},
+		{"malformed attempt time", header("tomorrow")},
+	}
+	for _, tt := range tests {
+		start := time.Now()
+		assert.True(t, waitForAttempt(session, tt.msg), tt.name)
+		assert.Less(t, time.Since(start), 50*time.Millisecond, tt.name)
+	}
+
+	// Завершение сессии прерывает ожидание
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.False(t, waitForAttempt(newFakeSession(ctx), header(time.Now().Add(time.Hour).UTC().Format(time.RFC3339Nano))))
+}
+
+func TestNewRetryTiers(t *testing.T) {
+	tiers, err := newRetryTiers(testTopic, []string{"1m", " 10m ", "1h"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"orders.retry.1m", "orders.retry.10m", "orders.retry.1h"}, retryTopics(tiers))
+	assert.Equal(t, 10*time.Minute, tiers[1].delay)
+
+	_, err = newRetryTiers(testTopic, []string{"soon"})
+	assert.Error(t, err)
+	_, err = newRetryTiers(testTopic, []string{"-1m"})
+	assert.Error(t, err)
+}
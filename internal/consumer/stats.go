@@ -0,0 +1,27 @@
+package consumer
+
+import (
+	"sync/atomic"
+
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/models"
+)
+
+// Потребитель в процессе один, поэтому счётчики общие для пакета
+var stats struct {
+	processed      atomic.Uint64
+	redelivered    atomic.Uint64
+	existingOrders atomic.Uint64
+	retried        atomic.Uint64
+	deadLettered   atomic.Uint64
+}
+
+// CurrentStats возвращает текущие значения счётчиков
+func CurrentStats() models.ConsumerStats {
+	return models.ConsumerStats{
+		Processed:      stats.processed.Load(),
+		Redelivered:    stats.redelivered.Load(),
+		ExistingOrders: stats.existingOrders.Load(),
+		Retried:        stats.retried.Load(),
+		DeadLettered:   stats.deadLettered.Load(),
+	}
+}
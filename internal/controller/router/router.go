@@ -1,9 +1,13 @@
 package router
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gorilla/handlers"
 	"go.uber.org/zap"
@@ -13,9 +17,31 @@ import (
 	"github.com/gorilla/mux"
 )
 
+const (
+	// cacheOperationTimeout ограничивает время одного обращения к кэшу в рамках HTTP-запроса
+	cacheOperationTimeout = 3 * time.Second
+	// defaultPageLimit — размер страницы /orders, если limit не задан
+	defaultPageLimit = 100
+	// maxPageLimit — максимальный размер страницы /orders
+	maxPageLimit = 1000
+	// maxBatchSize — максимальное количество UID в запросе /orders/batch
+	maxBatchSize = 1000
+	// maxBatchBodyBytes ограничивает размер тела запроса /orders/batch
+	maxBatchBodyBytes = 1 << 20
+	// maxOrderUIDLength — максимальная длина order_uid в запросе
+	maxOrderUIDLength = 50
+	// maxIndexValueLength — максимальная длина трек-номера или идентификатора клиента в поиске
+	maxIndexValueLength = 100
+	// staleHeader помечает ответ, в котором заказ отдан из кэша после истечения мягкого TTL
+	staleHeader = "X-Cache-Stale"
+)
+
 type Controller struct {
 	Cache  cache.Cache
 	logger *zap.Logger
+	ready  func() bool // готовность сервиса (nil — готов всегда)
+
+	consumerStats func() models.ConsumerStats // счётчики потребителя Kafka (nil — потребитель не запущен)
 }
 
 // Функция для инициализации контроллера с кэшем
@@ -26,6 +52,16 @@ func NewController(cache cache.Cache, logger *zap.Logger) *Controller {
 	}
 }
 
+// SetReadinessCheck задаёт проверку готовности для /ready (например, завершение прогрева кэша)
+func (c *Controller) SetReadinessCheck(ready func() bool) {
+	c.ready = ready
+}
+
+// SetConsumerStats задаёт источник счётчиков потребителя Kafka для /consumer/stats
+func (c *Controller) SetConsumerStats(stats func() models.ConsumerStats) {
+	c.consumerStats = stats
+}
+
 // Настройка маршрутизатора
 func (c *Controller) SetupRouter() *mux.Router {
 	r := mux.NewRouter()
@@ -34,9 +70,10 @@ func (c *Controller) SetupRouter() *mux.Router {
 	corsOptions := handlers.AllowedOrigins([]string{"*"})
 	corsMethods := handlers.AllowedMethods([]string{"GET", "POST", "PUT", "DELETE", "OPTIONS"})
 	corsHeaders := handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization"})
+	corsExposed := handlers.ExposedHeaders([]string{staleHeader})
 
 	// middleware для CORS
-	r.Use(handlers.CORS(corsOptions, corsMethods, corsHeaders))
+	r.Use(handlers.CORS(corsOptions, corsMethods, corsHeaders, corsExposed))
 	r.Use(c.preflightHandler)
 	r.Use(c.loggingMiddleware)
 
@@ -45,8 +82,14 @@ func (c *Controller) SetupRouter() *mux.Router {
 	r.HandleFunc("/order/{order_uid}", c.HandleDeleteOrder).Methods(http.MethodDelete, http.MethodOptions)
 	r.HandleFunc("/delorders", c.HandleClearOrders).Methods(http.MethodDelete, http.MethodOptions)
 	r.HandleFunc("/orders", c.HandleGetAllOrders).Methods(http.MethodGet, http.MethodOptions)
+	r.HandleFunc("/orders/batch", c.HandleGetOrdersBatch).Methods(http.MethodPost, http.MethodOptions)
+	r.HandleFunc("/customers/{customer_id}/orders", c.HandleGetCustomerOrders).Methods(http.MethodGet, http.MethodOptions)
+	r.HandleFunc("/cache/stats", c.HandleCacheStats).Methods(http.MethodGet)
+	r.HandleFunc("/consumer/stats", c.HandleConsumerStats).Methods(http.MethodGet)
 	// Health check
 	r.HandleFunc("/health", c.HandleHealthCheck).Methods(http.MethodGet)
+	// Readiness check: 503, пока кэш прогревается
+	r.HandleFunc("/ready", c.HandleReadinessCheck).Methods(http.MethodGet)
 	return r
 }
 
@@ -78,6 +121,45 @@ func (c *Controller) HandleHealthCheck(w http.ResponseWriter, r *http.Request) {
 	c.writeJSON(w, http.StatusOK, map[string]string{"status": "ok", "service": "order-cache"})
 }
 
+// HandleReadinessCheck обработчик проверки готовности сервиса принимать трафик
+func (c *Controller) HandleReadinessCheck(w http.ResponseWriter, r *http.Request) {
+	if c.ready != nil && !c.ready() {
+		c.writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "warming_up", "service": "order-cache"})
+		return
+	}
+	c.writeJSON(w, http.StatusOK, map[string]string{"status": "ready", "service": "order-cache"})
+}
+
+// cacheStatsResponse — статистика кэша с долей попаданий
+type cacheStatsResponse struct {
+	cache.Stats
+	HitRatio float64 `json:"hit_ratio"`
+}
+
+// HandleConsumerStats обработчик для получения счётчиков потребителя Kafka
+func (c *Controller) HandleConsumerStats(w http.ResponseWriter, r *http.Request) {
+	if c.consumerStats == nil {
+		c.writeError(w, http.StatusNotFound, "Consumer is not running")
+		return
+	}
+	c.writeJSON(w, http.StatusOK, c.consumerStats())
+}
+
+// HandleCacheStats обработчик для получения статистики кэша
+func (c *Controller) HandleCacheStats(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.cacheContext(r)
+	defer cancel()
+
+	stats, err := c.Cache.Stats(ctx)
+	if err != nil {
+		c.logger.Error("Failed to get cache stats", zap.Error(err))
+		c.writeError(w, cacheErrorStatus(err), "Failed to retrieve cache stats")
+		return
+	}
+
+	c.writeJSON(w, http.StatusOK, cacheStatsResponse{Stats: stats, HitRatio: stats.HitRatio()})
+}
+
 // HandleGetOrder Обработчик для получения заказа по order_uid
 func (c *Controller) HandleGetOrder(w http.ResponseWriter, r *http.Request) {
 	orderUID := mux.Vars(r)["order_uid"]
@@ -88,17 +170,20 @@ func (c *Controller) HandleGetOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if len(orderUID) > 50 {
+	if len(orderUID) > maxOrderUIDLength {
 		c.writeError(w, http.StatusBadRequest, "OrderUID is too long")
 		return
 	}
 
-	order, exists, err := c.Cache.GetOrder(orderUID)
+	ctx, cancel := c.cacheContext(r)
+	defer cancel()
+
+	order, exists, stale, err := c.getOrder(ctx, orderUID)
 	if err != nil {
 		c.logger.Error("Failed to get order from cache",
 			zap.String("order_uid", orderUID),
 			zap.Error(err))
-		c.writeError(w, http.StatusInternalServerError, "Internal server error")
+		c.writeError(w, cacheErrorStatus(err), "Internal server error")
 		return
 	}
 
@@ -107,11 +192,100 @@ func (c *Controller) HandleGetOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if stale {
+		w.Header().Set(staleHeader, "true")
+	}
+
 	c.logger.Info("Order retrieved successfully",
-		zap.String("order_uid", orderUID))
+		zap.String("order_uid", orderUID),
+		zap.Bool("stale", stale))
 	c.writeJSON(w, http.StatusOK, order)
 }
 
+// getOrder читает заказ из кэша; stale заполняется, если кэш поддерживает мягкий TTL
+func (c *Controller) getOrder(ctx context.Context, orderUID string) (order models.Order, exists, stale bool, err error) {
+	if reader, ok := c.Cache.(cache.StaleReader); ok {
+		return reader.GetOrderStale(ctx, orderUID)
+	}
+	order, exists, err = c.Cache.GetOrder(ctx, orderUID)
+	return order, exists, false, err
+}
+
+// ordersBatchRequest — тело запроса /orders/batch
+type ordersBatchRequest struct {
+	OrderUIDs []string `json:"order_uids"`
+}
+
+// ordersBatchResponse — найденные заказы и UID, которых нет ни в кэше, ни в БД
+type ordersBatchResponse struct {
+	Orders  []models.Order `json:"orders"`
+	Missing []string       `json:"missing"`
+}
+
+// HandleGetOrdersBatch обработчик пакетного получения заказов по списку order_uid.
+// Повторяющиеся UID обрабатываются один раз; порядок заказов в ответе совпадает с порядком в запросе.
+func (c *Controller) HandleGetOrdersBatch(w http.ResponseWriter, r *http.Request) {
+	var req ordersBatchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBodyBytes)).Decode(&req); err != nil {
+		c.writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	uids, err := uniqueOrderUIDs(req.OrderUIDs)
+	if err != nil {
+		c.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := c.cacheContext(r)
+	defer cancel()
+
+	orders, missing, err := c.Cache.GetOrders(ctx, uids)
+	if err != nil {
+		c.logger.Error("Failed to get orders batch from cache",
+			zap.Int("order_count", len(uids)),
+			zap.Error(err))
+		c.writeError(w, cacheErrorStatus(err), "Failed to retrieve orders")
+		return
+	}
+	if missing == nil {
+		missing = []string{}
+	}
+
+	c.logger.Info("Retrieved orders batch",
+		zap.Int("requested", len(uids)),
+		zap.Int("found", len(orders)),
+		zap.Int("missing", len(missing)))
+	c.writeJSON(w, http.StatusOK, ordersBatchResponse{Orders: orders, Missing: missing})
+}
+
+// uniqueOrderUIDs проверяет список UID пакетного запроса и убирает повторы
+func uniqueOrderUIDs(orderUIDs []string) ([]string, error) {
+	if len(orderUIDs) == 0 {
+		return nil, fmt.Errorf("order_uids is required")
+	}
+
+	seen := make(map[string]struct{}, len(orderUIDs))
+	uids := make([]string, 0, len(orderUIDs))
+	for _, uid := range orderUIDs {
+		if uid == "" {
+			return nil, fmt.Errorf("order_uids must not contain empty values")
+		}
+		if len(uid) > maxOrderUIDLength {
+			return nil, fmt.Errorf("OrderUID is too long")
+		}
+		if _, dup := seen[uid]; dup {
+			continue
+		}
+		seen[uid] = struct{}{}
+		uids = append(uids, uid)
+	}
+	if len(uids) > maxBatchSize {
+		return nil, fmt.Errorf("too many order_uids: at most %d allowed", maxBatchSize)
+	}
+	return uids, nil
+}
+
 // HandleDeleteOrder Обработчик для удаления заказа по order_uid
 func (c *Controller) HandleDeleteOrder(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -123,12 +297,15 @@ func (c *Controller) HandleDeleteOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	exists, err := c.Cache.OrderExists(orderUID)
+	ctx, cancel := c.cacheContext(r)
+	defer cancel()
+
+	exists, err := c.Cache.OrderExists(ctx, orderUID)
 	if err != nil {
 		c.logger.Error("Failed to check order existence",
 			zap.String("order_uid", orderUID),
 			zap.Error(err))
-		c.writeError(w, http.StatusInternalServerError, "Internal server error")
+		c.writeError(w, cacheErrorStatus(err), "Internal server error")
 		return
 	}
 
@@ -137,11 +314,11 @@ func (c *Controller) HandleDeleteOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := c.Cache.RemoveOrder(orderUID); err != nil {
+	if err := c.Cache.RemoveOrder(ctx, orderUID); err != nil {
 		c.logger.Error("Failed to remove order from cache",
 			zap.String("order_uid", orderUID),
 			zap.Error(err))
-		c.writeError(w, http.StatusInternalServerError, "Failed to delete order")
+		c.writeError(w, cacheErrorStatus(err), "Failed to delete order")
 		return
 	}
 
@@ -154,19 +331,37 @@ func (c *Controller) HandleDeleteOrder(w http.ResponseWriter, r *http.Request) {
 
 // HandleClearOrders Обработчик для очистки всех заказов
 func (c *Controller) HandleClearOrders(w http.ResponseWriter, r *http.Request) {
-	if err := c.Cache.Clear(); err != nil {
-		c.writeError(w, http.StatusInternalServerError, fmt.Sprintf("Error clearing orders: %v", err))
+	ctx, cancel := c.cacheContext(r)
+	defer cancel()
+
+	if err := c.Cache.Clear(ctx); err != nil {
+		c.writeError(w, cacheErrorStatus(err), fmt.Sprintf("Error clearing orders: %v", err))
 		return
 	}
 	c.writeJSON(w, http.StatusOK, map[string]string{"message": "All orders successfully cleared"})
 }
 
-// HandleGetAllOrders обработчик для получения всех заказов
+// HandleGetAllOrders обработчик для получения всех заказов.
+// Если задан параметр track_number, возвращает заказы с этим трек-номером;
+// если заданы параметры cursor или limit, возвращает одну страницу заказов.
 func (c *Controller) HandleGetAllOrders(w http.ResponseWriter, r *http.Request) {
-	orders, err := c.Cache.GetAllOrders()
+	query := r.URL.Query()
+	if query.Has("track_number") {
+		c.handleFindOrders(w, r, cache.IndexTrackNumber, query.Get("track_number"))
+		return
+	}
+	if query.Has("cursor") || query.Has("limit") {
+		c.handleListOrders(w, r)
+		return
+	}
+
+	ctx, cancel := c.cacheContext(r)
+	defer cancel()
+
+	orders, err := c.Cache.GetAllOrders(ctx)
 	if err != nil {
 		c.logger.Error("Failed to get all orders from cache", zap.Error(err))
-		c.writeError(w, http.StatusInternalServerError, "Failed to retrieve orders")
+		c.writeError(w, cacheErrorStatus(err), "Failed to retrieve orders")
 		return
 	}
 
@@ -181,6 +376,110 @@ func (c *Controller) HandleGetAllOrders(w http.ResponseWriter, r *http.Request)
 	c.writeJSON(w, http.StatusOK, orders)
 }
 
+// HandleGetCustomerOrders обработчик для получения заказов клиента
+func (c *Controller) HandleGetCustomerOrders(w http.ResponseWriter, r *http.Request) {
+	c.handleFindOrders(w, r, cache.IndexCustomerID, mux.Vars(r)["customer_id"])
+}
+
+// handleFindOrders ищет заказы по вторичному индексу кэша.
+// Результат содержит только заказы, находящиеся в кэше, от новых к старым.
+func (c *Controller) handleFindOrders(w http.ResponseWriter, r *http.Request, field cache.IndexField, value string) {
+	if value == "" {
+		c.writeError(w, http.StatusBadRequest, fmt.Sprintf("%s is required", field))
+		return
+	}
+	if len(value) > maxIndexValueLength {
+		c.writeError(w, http.StatusBadRequest, fmt.Sprintf("%s is too long", field))
+		return
+	}
+
+	indexer, ok := c.Cache.(cache.Indexer)
+	if !ok {
+		c.writeError(w, http.StatusNotImplemented, "Search is not supported by the cache backend")
+		return
+	}
+
+	ctx, cancel := c.cacheContext(r)
+	defer cancel()
+
+	orders, err := indexer.FindOrders(ctx, field, value)
+	if errors.Is(err, cache.ErrIndexNotSupported) {
+		c.writeError(w, http.StatusNotImplemented, "Search is not supported by the cache backend")
+		return
+	}
+	if err != nil {
+		c.logger.Error("Failed to find orders in cache",
+			zap.String("field", string(field)),
+			zap.String("value", value),
+			zap.Error(err))
+		c.writeError(w, cacheErrorStatus(err), "Failed to retrieve orders")
+		return
+	}
+
+	c.logger.Info("Found orders by index",
+		zap.String("field", string(field)),
+		zap.String("value", value),
+		zap.Int("order_count", len(orders)))
+	c.writeJSON(w, http.StatusOK, orders)
+}
+
+// ordersPage — страница заказов с курсором следующей страницы
+type ordersPage struct {
+	Orders     []models.Order `json:"orders"`
+	NextCursor string         `json:"next_cursor"`
+}
+
+// handleListOrders обработчик постраничного получения заказов (?cursor=&limit=)
+func (c *Controller) handleListOrders(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit := defaultPageLimit
+	if raw := query.Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			c.writeError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = parsed
+	}
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+
+	ctx, cancel := c.cacheContext(r)
+	defer cancel()
+
+	orders, next, err := c.Cache.ListOrders(ctx, query.Get("cursor"), limit)
+	if errors.Is(err, cache.ErrInvalidCursor) {
+		c.writeError(w, http.StatusBadRequest, "Invalid cursor")
+		return
+	}
+	if err != nil {
+		c.logger.Error("Failed to list orders from cache", zap.Error(err))
+		c.writeError(w, cacheErrorStatus(err), "Failed to retrieve orders")
+		return
+	}
+
+	c.logger.Info("Retrieved orders page from cache",
+		zap.Int("order_count", len(orders)),
+		zap.String("next_cursor", next))
+	c.writeJSON(w, http.StatusOK, ordersPage{Orders: orders, NextCursor: next})
+}
+
+// cacheContext возвращает контекст запроса, ограниченный таймаутом обращения к кэшу.
+// Если клиент разорвал соединение, операция с кэшем отменяется вместе с запросом.
+func (c *Controller) cacheContext(r *http.Request) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(r.Context(), cacheOperationTimeout)
+}
+
+// cacheErrorStatus подбирает HTTP-статус для ошибки кэша
+func cacheErrorStatus(err error) int {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
 // Приватные методы для записи JSON и ошибок
 func (c *Controller) writeJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
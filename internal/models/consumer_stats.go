@@ -0,0 +1,10 @@
+package models
+
+// ConsumerStats — счётчики обработки сообщений потребителем Kafka с момента запуска процесса
+type ConsumerStats struct {
+	Processed      uint64 `json:"processed"`       // заказы сохранены в БД
+	Redelivered    uint64 `json:"redelivered"`     // повторные доставки уже обработанного сообщения, подтверждённые без обработки
+	ExistingOrders uint64 `json:"existing_orders"` // сообщения с другим ключом, заказ которых уже сохранён
+	Retried        uint64 `json:"retried"`         // сообщения, отправленные в топики повторов
+	DeadLettered   uint64 `json:"dead_lettered"`   // сообщения, отправленные в DLQ
+}
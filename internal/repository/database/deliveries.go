@@ -25,7 +25,7 @@ const (
 	getDeliveryQuery = `SELECT * FROM deliveries WHERE order_uid = $1`
 )
 
-func AddDelivery(db *sql.DB, delivery models.Delivery, orderUID string) (string, error) {
+func AddDelivery(db Querier, delivery models.Delivery, orderUID string) (string, error) {
 	existingDelivery, err := GetDelivery(db, orderUID)
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		return "", fmt.Errorf("не удалось получить доставку: %w", err)
@@ -59,7 +59,7 @@ func AddDelivery(db *sql.DB, delivery models.Delivery, orderUID string) (string,
 	return operationMessage, nil
 }
 
-func GetDelivery(db *sql.DB, orderUID string) (*models.Delivery, error) {
+func GetDelivery(db Querier, orderUID string) (*models.Delivery, error) {
 	row := db.QueryRow(getDeliveryQuery, orderUID)
 
 	var delivery models.Delivery
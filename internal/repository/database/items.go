@@ -1,7 +1,6 @@
 package database
 
 import (
-	"database/sql"
 	"fmt"
 	"strconv"
 
@@ -9,13 +8,13 @@ import (
 )
 
 const (
-	addItemQuery = `INSERT INTO items ("chrt_id", "track_number", "price", "rid", "name", "sale", "size", "total_price", "nm_id", "brand", "status", "order_uid") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) ON CONFLICT (chrt_id) DO NOTHING`
+	addItemQuery = `INSERT INTO items ("chrt_id", "track_number", "price", "rid", "name", "sale", "size", "total_price", "nm_id", "brand", "status", "order_uid") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) ON CONFLICT (order_uid, chrt_id) DO NOTHING`
 
 	getAllItemsQuery = "SELECT chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status FROM items WHERE order_uid = $1"
 )
 
 // AddItems сохраняет список элементов заказа в БД, пропуская существующие элементы
-func AddItems(db *sql.DB, items []models.OrderItem, orderUID string) error {
+func AddItems(db Querier, items []models.OrderItem, orderUID string) error {
 	for _, item := range items {
 		exists, err := ItemExists(db, strconv.Itoa(item.ChartID), orderUID) // Проверка существования
 		if err != nil {
@@ -33,7 +32,7 @@ func AddItems(db *sql.DB, items []models.OrderItem, orderUID string) error {
 }
 
 // ItemExists проверяет, существует ли элемент в БД
-func ItemExists(db *sql.DB, chrtID string, orderUID string) (bool, error) {
+func ItemExists(db Querier, chrtID string, orderUID string) (bool, error) {
 	var exists bool
 	err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM items WHERE chrt_id = $1 AND order_uid = $2)`, chrtID, orderUID).Scan(&exists)
 	if err != nil {
@@ -43,7 +42,7 @@ func ItemExists(db *sql.DB, chrtID string, orderUID string) (bool, error) {
 }
 
 // AddItem добавляет новый элемент в БД
-func AddItem(db *sql.DB, item models.OrderItem, orderUID string) error {
+func AddItem(db Querier, item models.OrderItem, orderUID string) error {
 	_, err := db.Exec(
 		addItemQuery,
 		&item.ChartID,
@@ -66,7 +65,7 @@ func AddItem(db *sql.DB, item models.OrderItem, orderUID string) error {
 }
 
 // GetItems получает все элементы из БД по идентификатору заказа
-func GetItems(db *sql.DB, orderUID string) ([]models.OrderItem, error) {
+func GetItems(db Querier, orderUID string) ([]models.OrderItem, error) {
 	rows, err := db.Query(getAllItemsQuery, orderUID)
 	if err != nil {
 		return nil, fmt.Errorf("get items failed: %w", err)
@@ -28,7 +28,7 @@ FROM payments WHERE order_uid = $1`
 )
 
 // AddPayment добавляет платеж в базу данных.
-func AddPayment(db *sql.DB, payment models.Payment, orderUID string) error {
+func AddPayment(db Querier, payment models.Payment, orderUID string) error {
 	_, err := db.Exec(
 		addPaymentQuery,
 		payment.TransactionUID,
@@ -50,7 +50,7 @@ func AddPayment(db *sql.DB, payment models.Payment, orderUID string) error {
 }
 
 // GetPayment получает платеж из базы данных по orderUID.
-func GetPayment(db *sql.DB, orderUID string) (*models.Payment, error) {
+func GetPayment(db Querier, orderUID string) (*models.Payment, error) {
 	row := db.QueryRow(getPaymentQuery, orderUID) // Используем tx
 	var payment models.Payment
 
@@ -76,7 +76,7 @@ func GetPayment(db *sql.DB, orderUID string) (*models.Payment, error) {
 }
 
 // PaymentExists проверяет существование платежа в базе данных по orderUID.
-func PaymentExists(tx *sql.DB, orderUID string) (bool, error) {
+func PaymentExists(tx Querier, orderUID string) (bool, error) {
 	var exists bool
 	err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM payments WHERE order_uid = $1)", orderUID).Scan(&exists)
 	if err != nil {
@@ -0,0 +1,10 @@
+package database
+
+import "database/sql"
+
+// Querier — общие методы *sql.DB и *sql.Tx, чтобы функции пакета работали и внутри транзакции
+type Querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
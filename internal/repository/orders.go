@@ -4,19 +4,37 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/config"
 	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/models"
 	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/repository/database"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 const (
 	addOrderQuery     = `INSERT INTO orders("order_uid", "track_number", "entry", "locale", "internal_signature", "customer_id", "delivery_service", "shardkey", "sm_id", "date_created", "oof_shard") VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
-	getOrderQuery     = "SELECT order_uid, track_number, entry, delivery, payment, items, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard FROM orders WHERE order_uid = $1"
-	getAllOrdersQuery = "SELECT * FROM orders"
+	getAllOrdersQuery = "SELECT order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard FROM orders"
+	// Доставка, оплата и товары хранятся в своих таблицах и дочитываются populateOrderDetails
+	getOrderQuery = getAllOrdersQuery + " WHERE order_uid = $1"
+
+	getOrdersUpdatedSinceQuery = getAllOrdersQuery + " WHERE updated_at >= $1"
+
+	// Выборки для прогрева кэша: постранично по (date_created, order_uid) и по списку UID
+	getOrdersCreatedAfterQuery = getAllOrdersQuery + " WHERE (date_created, order_uid) > ($1, $2) ORDER BY date_created, order_uid LIMIT $3"
+	getRecentOrdersCutoffQuery = "SELECT date_created FROM orders WHERE date_created IS NOT NULL ORDER BY date_created DESC OFFSET $1 LIMIT 1"
+	getOrdersByUIDsQuery       = getAllOrdersQuery + " WHERE order_uid = ANY($1)"
+
+	// Ключ идемпотентности записывается один раз; повтор не вставляет строку
+	markMessageProcessedQuery = "INSERT INTO processed_messages (message_key, order_uid) VALUES ($1, $2) ON CONFLICT (message_key) DO NOTHING"
 )
 
+// ErrOrderExists возвращается AddOrder, если заказ с таким order_uid уже сохранён
+var ErrOrderExists = errors.New("order already exists")
+
+// ErrMessageProcessed возвращается AddOrderOnce, если сообщение с таким ключом уже обработано
+var ErrMessageProcessed = errors.New("message already processed")
+
 type OrdersRepo struct {
 	DB *sql.DB
 }
@@ -39,26 +57,118 @@ func New(cfg *config.Config) (*OrdersRepo, error) {
 }
 
 func (o *OrdersRepo) OrderExists(orderUID string) (bool, error) {
+	return orderExists(o.DB, orderUID)
+}
+
+func orderExists(q database.Querier, orderUID string) (bool, error) {
 	var exists bool
-	err := o.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM orders WHERE order_uid = $1)", orderUID).Scan(&exists)
+	err := q.QueryRow("SELECT EXISTS(SELECT 1 FROM orders WHERE order_uid = $1)", orderUID).Scan(&exists)
 	if err != nil {
 		return false, err
 	}
 	return exists, nil
 }
+
+// AddOrder сохраняет заказ с платежом, товарами и доставкой в одной транзакции
 func (o *OrdersRepo) AddOrder(order models.Order) error {
+	tx, err := o.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := addOrder(tx, order); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit order: %w", err)
+	}
+	return nil
+}
+
+// AddOrderOnce сохраняет заказ и ключ идемпотентности сообщения messageKey в одной транзакции,
+// поэтому повторно доставленное сообщение не сохраняет заказ второй раз ни после рестарта, ни после
+// вытеснения из кэша. ErrMessageProcessed — сообщение с этим ключом уже обработано;
+// ErrOrderExists — заказ сохранён другим сообщением (ключ при этом записывается).
+func (o *OrdersRepo) AddOrderOnce(messageKey string, order models.Order) error {
+	tx, err := o.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	addErr := addOrderOnce(tx, messageKey, order)
+	if addErr != nil && !errors.Is(addErr, ErrOrderExists) {
+		return addErr
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit order: %w", err)
+	}
+	return addErr
+}
+
+// KeyedOrder — заказ и ключ идемпотентности сообщения, из которого он получен
+type KeyedOrder struct {
+	MessageKey string
+	Order      models.Order
+}
+
+// AddOrdersOnce сохраняет пакет заказов с ключами сообщений в одной транзакции в порядке пакета.
+// Для каждого заказа возвращает nil, если он сохранён, ErrMessageProcessed, если сообщение уже
+// обработано, и ErrOrderExists, если заказ сохранён другим сообщением (в том числе предыдущим
+// сообщением того же пакета). При ошибке не сохраняется ничего.
+func (o *OrdersRepo) AddOrdersOnce(batch []KeyedOrder) ([]error, error) {
+	tx, err := o.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	results := make([]error, len(batch))
+	for i, item := range batch {
+		err := addOrderOnce(tx, item.MessageKey, item.Order)
+		if err != nil && !errors.Is(err, ErrMessageProcessed) && !errors.Is(err, ErrOrderExists) {
+			return nil, fmt.Errorf("order %s: %w", item.Order.OrderUID, err)
+		}
+		results[i] = err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit orders batch: %w", err)
+	}
+	return results, nil
+}
+
+// addOrderOnce записывает ключ сообщения и, если он новый, сохраняет заказ
+func addOrderOnce(q database.Querier, messageKey string, order models.Order) error {
+	// Конкурирующая транзакция с тем же ключом ждёт фиксации этой и затем ничего не вставляет
+	res, err := q.Exec(markMessageProcessedQuery, messageKey, order.OrderUID)
+	if err != nil {
+		return fmt.Errorf("failed to record processed message: %w", err)
+	}
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to record processed message: %w", err)
+	}
+	if inserted == 0 {
+		return fmt.Errorf("message %s: %w", messageKey, ErrMessageProcessed)
+	}
+	return addOrder(q, order)
+}
+
+func addOrder(q database.Querier, order models.Order) error {
 	// существует ли заказ?
-	exists, err := o.OrderExists(order.OrderUID)
+	exists, err := orderExists(q, order.OrderUID)
 	if err != nil {
 		return fmt.Errorf("failed to check if order exists: %w", err)
 	}
 
 	if exists {
-		return fmt.Errorf("order with order_uid %s already exists", order.OrderUID)
+		return fmt.Errorf("order with order_uid %s: %w", order.OrderUID, ErrOrderExists)
 	}
 
 	// Вставляем заказ в базу данных
-	_, err = o.DB.Exec(
+	_, err = q.Exec(
 		addOrderQuery,
 		order.OrderUID,
 		order.TrackNumber,
@@ -77,13 +187,13 @@ func (o *OrdersRepo) AddOrder(order models.Order) error {
 	}
 
 	// Проверка существования платежа и добавление при необходимости.
-	if err := o.processPayment(order); err != nil {
+	if err := processPayment(q, order); err != nil {
 		return fmt.Errorf("failed to process payment: %w", err)
 	}
 
 	// Добавление предметов заказа
 	if err := database.AddItems(
-		o.DB,
+		q,
 		order.Items,
 		order.OrderUID,
 	); err != nil {
@@ -91,24 +201,21 @@ func (o *OrdersRepo) AddOrder(order models.Order) error {
 	}
 
 	// Добавление доставки
-	statusMessage, err := database.AddDelivery(
-		o.DB,
+	if _, err := database.AddDelivery(
+		q,
 		order.Delivery,
 		order.OrderUID,
-	)
-	if err != nil {
+	); err != nil {
 		return fmt.Errorf("failed to insert delivery: %w", err)
 	}
 
-	fmt.Println(statusMessage)
-
 	return nil
 }
 
 // processPayment проверяет существование платежа и добавляет новый, если его нет.
-func (o *OrdersRepo) processPayment(order models.Order) error {
+func processPayment(q database.Querier, order models.Order) error {
 	exists, err := database.PaymentExists(
-		o.DB,
+		q,
 		order.OrderUID,
 	)
 	if err != nil {
@@ -116,7 +223,7 @@ func (o *OrdersRepo) processPayment(order models.Order) error {
 	}
 
 	if !exists {
-		if err := database.AddPayment(o.DB, order.Payment, order.OrderUID); err != nil {
+		if err := database.AddPayment(q, order.Payment, order.OrderUID); err != nil {
 			return fmt.Errorf("failed to insert payment: %w", err)
 		}
 	}
@@ -159,7 +266,9 @@ func populateOrderDetails(db *sql.DB, order *models.Order) error {
 	if err != nil {
 		return err
 	}
-	order.Delivery = *delivery
+	if delivery != nil {
+		order.Delivery = *delivery
+	}
 
 	payment, err := database.GetPayment(db, order.OrderUID)
 	if err != nil {
@@ -177,7 +286,44 @@ func populateOrderDetails(db *sql.DB, order *models.Order) error {
 }
 
 func (o *OrdersRepo) GetOrders() ([]models.Order, error) {
-	rows, err := o.DB.Query(getAllOrdersQuery)
+	return o.queryOrders(getAllOrdersQuery)
+}
+
+// GetOrdersUpdatedSince возвращает заказы, добавленные или изменённые начиная с since
+// (используется для дозагрузки кэша после восстановления из снимка).
+func (o *OrdersRepo) GetOrdersUpdatedSince(since time.Time) ([]models.Order, error) {
+	return o.queryOrders(getOrdersUpdatedSinceQuery, since)
+}
+
+// GetOrdersCreatedAfter возвращает до limit заказов, следующих за (createdAt, orderUID)
+// в порядке возрастания date_created (keyset-пагинация для прогрева кэша).
+// Первую страницу с момента since даёт вызов с (since, "").
+func (o *OrdersRepo) GetOrdersCreatedAfter(createdAt time.Time, orderUID string, limit int) ([]models.Order, error) {
+	return o.queryOrders(getOrdersCreatedAfterQuery, createdAt, orderUID, limit)
+}
+
+// RecentOrdersCutoff возвращает date_created n-го по свежести заказа.
+// ok = false, если заказов с датой создания меньше n.
+func (o *OrdersRepo) RecentOrdersCutoff(n int) (time.Time, bool, error) {
+	var cutoff time.Time
+	err := o.DB.QueryRow(getRecentOrdersCutoffQuery, n-1).Scan(&cutoff)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to get recent orders cutoff: %w", err)
+	}
+	return cutoff, true, nil
+}
+
+// GetOrdersByUIDs возвращает заказы из списка; отсутствующие в БД UID пропускаются
+func (o *OrdersRepo) GetOrdersByUIDs(orderUIDs []string) ([]models.Order, error) {
+	return o.queryOrders(getOrdersByUIDsQuery, pq.Array(orderUIDs))
+}
+
+// queryOrders выполняет запрос заказов и дополняет каждый доставкой, оплатой и товарами
+func (o *OrdersRepo) queryOrders(query string, args ...any) ([]models.Order, error) {
+	rows, err := o.DB.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get orders: %w", err)
 	}
@@ -0,0 +1,287 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/datagenerators"
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/models"
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/repository/database"
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/migrations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// defaultTestDSN — PostgreSQL из docker-compose; переопределяется переменной TEST_POSTGRES_DSN
+const defaultTestDSN = "host=localhost port=5432 user=my_user password=my_password dbname=orders_db sslmode=disable"
+
+// setupTestRepo создаёт репозиторий в отдельной схеме PostgreSQL с применёнными версионными миграциями.
+// Схема удаляется после теста; без PostgreSQL тест пропускается.
+func setupTestRepo(t *testing.T) *OrdersRepo {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		dsn = defaultTestDSN
+	}
+
+	admin, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	if err := admin.Ping(); err != nil {
+		admin.Close()
+		t.Skip("PostgreSQL is not available")
+	}
+
+	schema := fmt.Sprintf("repo_test_%d", time.Now().UnixNano())
+	_, err = admin.Exec("CREATE SCHEMA " + schema)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_, _ = admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		admin.Close()
+	})
+
+	db, err := sql.Open("postgres", dsn+" search_path="+schema)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	require.NoError(t, migrations.NewMigrationManager(db, zap.NewNop()).Up())
+	return &OrdersRepo{DB: db}
+}
+
+// testOrder возвращает заказ, который без потерь проходит через схему БД
+func testOrder() models.Order {
+	order := datagenerators.GenerateOrder()
+	// date_created — TIMESTAMP без зоны с точностью до микросекунд
+	order.DateCreated = time.Now().UTC().Truncate(time.Microsecond)
+	for i := range order.Items {
+		order.Items[i].ChartID = i + 1
+	}
+	return order
+}
+
+// assertSameOrder сравнивает заказ из БД с сохранённым (порядок товаров не гарантируется)
+func assertSameOrder(t *testing.T, want models.Order, got *models.Order) {
+	t.Helper()
+	require.NotNil(t, got)
+	assert.ElementsMatch(t, want.Items, got.Items)
+	assert.True(t, want.DateCreated.Equal(got.DateCreated))
+
+	want.Items, got.Items = nil, nil
+	want.DateCreated, got.DateCreated = time.Time{}, time.Time{}
+	assert.Equal(t, want, *got)
+}
+
+func TestOrdersRepo_AddAndGetOrder(t *testing.T) {
+	repo := setupTestRepo(t)
+	order := testOrder()
+
+	require.NoError(t, repo.AddOrder(order))
+
+	got, err := repo.GetOrder(order.OrderUID)
+	require.NoError(t, err)
+	assertSameOrder(t, order, got)
+
+	err = repo.AddOrder(order)
+	assert.ErrorIs(t, err, ErrOrderExists)
+}
+
+func TestOrdersRepo_AddOrderOnce(t *testing.T) {
+	repo := setupTestRepo(t)
+	order := testOrder()
+
+	require.NoError(t, repo.AddOrderOnce("orders/0/1", order))
+	got, err := repo.GetOrder(order.OrderUID)
+	require.NoError(t, err)
+	assertSameOrder(t, order, got)
+
+	// Повторная доставка того же сообщения
+	err = repo.AddOrderOnce("orders/0/1", order)
+	assert.ErrorIs(t, err, ErrMessageProcessed)
+
+	// Тот же заказ из другого сообщения: заказ не меняется, ключ записывается
+	changed := order
+	changed.TrackNumber = "CHANGED"
+	err = repo.AddOrderOnce("orders/0/2", changed)
+	assert.ErrorIs(t, err, ErrOrderExists)
+
+	var recorded bool
+	require.NoError(t, repo.DB.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM processed_messages WHERE message_key = $1)", "orders/0/2",
+	).Scan(&recorded))
+	assert.True(t, recorded)
+	assert.ErrorIs(t, repo.AddOrderOnce("orders/0/2", changed), ErrMessageProcessed)
+
+	got, err = repo.GetOrder(order.OrderUID)
+	require.NoError(t, err)
+	assert.Equal(t, order.TrackNumber, got.TrackNumber)
+}
+
+func TestOrdersRepo_AddOrdersOnce(t *testing.T) {
+	repo := setupTestRepo(t)
+	saved, first, second := testOrder(), testOrder(), testOrder()
+	require.NoError(t, repo.AddOrderOnce("orders/0/1", saved))
+
+	results, err := repo.AddOrdersOnce([]KeyedOrder{
+		{MessageKey: "orders/0/1", Order: saved},  // повторная доставка
+		{MessageKey: "orders/0/2", Order: first},  // новый заказ
+		{MessageKey: "orders/0/3", Order: first},  // тот же заказ из следующего сообщения пакета
+		{MessageKey: "orders/0/4", Order: saved},  // заказ, сохранённый раньше другим сообщением
+		{MessageKey: "orders/0/5", Order: second}, // новый заказ
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 5)
+	assert.ErrorIs(t, results[0], ErrMessageProcessed)
+	assert.NoError(t, results[1])
+	assert.ErrorIs(t, results[2], ErrOrderExists)
+	assert.ErrorIs(t, results[3], ErrOrderExists)
+	assert.NoError(t, results[4])
+
+	orders, err := repo.GetOrdersByUIDs([]string{first.OrderUID, second.OrderUID})
+	require.NoError(t, err)
+	assert.Len(t, orders, 2)
+}
+
+func TestOrdersRepo_GetOrderNotFound(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	got, err := repo.GetOrder("missing")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
+
+func TestOrdersRepo_GetOrdersByUIDs(t *testing.T) {
+	repo := setupTestRepo(t)
+	first, second := testOrder(), testOrder()
+	require.NoError(t, repo.AddOrder(first))
+	require.NoError(t, repo.AddOrder(second))
+
+	orders, err := repo.GetOrdersByUIDs([]string{first.OrderUID, "missing", second.OrderUID})
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
+
+	byUID := map[string]models.Order{}
+	for _, order := range orders {
+		byUID[order.OrderUID] = order
+	}
+	for _, want := range []models.Order{first, second} {
+		got, ok := byUID[want.OrderUID]
+		require.True(t, ok)
+		assertSameOrder(t, want, &got)
+	}
+}
+
+func TestOrdersRepo_UpdatedAtFollowsChanges(t *testing.T) {
+	repo := setupTestRepo(t)
+	order := testOrder()
+	require.NoError(t, repo.AddOrder(order))
+
+	// Время берётся у PostgreSQL, чтобы не зависеть от расхождения часов
+	var since time.Time
+	require.NoError(t, repo.DB.QueryRow("SELECT clock_timestamp()").Scan(&since))
+
+	orders, err := repo.GetOrdersUpdatedSince(since)
+	require.NoError(t, err)
+	assert.Empty(t, orders)
+
+	// Изменение доставки (upsert) помечает заказ изменённым
+	order.Delivery.City = "Changed"
+	_, err = database.AddDelivery(repo.DB, order.Delivery, order.OrderUID)
+	require.NoError(t, err)
+
+	orders, err = repo.GetOrdersUpdatedSince(since)
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+	assert.Equal(t, "Changed", orders[0].Delivery.City)
+}
+
+func TestOrdersRepo_RecentOrdersKeyset(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	// Индекс keyset-пагинации прогрева создаётся миграциями при старте
+	var indexed bool
+	require.NoError(t, repo.DB.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM pg_indexes WHERE indexname = 'idx_orders_date_created_uid' AND schemaname = current_schema())",
+	).Scan(&indexed))
+	assert.True(t, indexed)
+
+	base := time.Now().UTC().Truncate(time.Microsecond)
+	orders := make([]models.Order, 3)
+	for i := range orders {
+		orders[i] = testOrder()
+		orders[i].DateCreated = base.Add(time.Duration(i) * time.Minute)
+		require.NoError(t, repo.AddOrder(orders[i]))
+	}
+
+	cutoff, ok, err := repo.RecentOrdersCutoff(2)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.True(t, orders[1].DateCreated.Equal(cutoff))
+
+	_, ok, err = repo.RecentOrdersCutoff(4)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	page, err := repo.GetOrdersCreatedAfter(cutoff, "", 1)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, orders[1].OrderUID, page[0].OrderUID)
+
+	page, err = repo.GetOrdersCreatedAfter(page[0].DateCreated, page[0].OrderUID, 10)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, orders[2].OrderUID, page[0].OrderUID)
+}
+
+// TestOrderQueries_MatchSchema проверяет без БД, что выборки заказов читают только столбцы таблицы
+// orders из версионных миграций и ровно столько, сколько сканируют GetOrder и queryOrders
+func TestOrderQueries_MatchSchema(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "..", "migrations", "versions", "*.up.sql"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	createTable := regexp.MustCompile(`(?s)CREATE TABLE IF NOT EXISTS orders\s*\((.*?)\)\s*;`)
+	addColumn := regexp.MustCompile(`ALTER TABLE orders ADD COLUMN IF NOT EXISTS (\w+)`)
+
+	columns := map[string]bool{}
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		require.NoError(t, err)
+
+		if m := createTable.FindSubmatch(content); m != nil {
+			for _, line := range strings.Split(string(m[1]), "\n") {
+				if fields := strings.Fields(line); len(fields) > 0 {
+					columns[fields[0]] = true
+				}
+			}
+		}
+		for _, m := range addColumn.FindAllSubmatch(content, -1) {
+			columns[string(m[1])] = true
+		}
+	}
+	require.True(t, columns["order_uid"])
+
+	const scanTargets = 11
+	queries := map[string]string{
+		"getOrderQuery":              getOrderQuery,
+		"getAllOrdersQuery":          getAllOrdersQuery,
+		"getOrdersUpdatedSinceQuery": getOrdersUpdatedSinceQuery,
+		"getOrdersCreatedAfterQuery": getOrdersCreatedAfterQuery,
+		"getOrdersByUIDsQuery":       getOrdersByUIDsQuery,
+	}
+	selectList := regexp.MustCompile(`^SELECT (.*?) FROM orders`)
+	for name, query := range queries {
+		m := selectList.FindStringSubmatch(query)
+		require.NotNil(t, m, name)
+
+		selected := strings.Split(m[1], ",")
+		assert.Len(t, selected, scanTargets, name)
+		for _, column := range selected {
+			assert.True(t, columns[strings.TrimSpace(column)], "%s selects unknown column %q", name, strings.TrimSpace(column))
+		}
+	}
+}
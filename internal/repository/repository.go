@@ -4,6 +4,10 @@ import "github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/mo
 
 type Orders interface {
 	AddOrder(order models.Order) error
+	AddOrderOnce(messageKey string, order models.Order) error
+	AddOrdersOnce(batch []KeyedOrder) ([]error, error)
 	GetOrder(OrderUID string) (*models.Order, error)
 	GetOrders() ([]models.Order, error)
 }
+
+var _ Orders = (*OrdersRepo)(nil)
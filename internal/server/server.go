@@ -11,6 +11,7 @@ import (
 	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/config"
 	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/cache"
 	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/controller/router"
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/models"
 )
 
 type Server struct {
@@ -19,6 +20,9 @@ type Server struct {
 	HTTPPort string
 	logger   *zap.Logger
 	server   *http.Server
+	ready    func() bool // проверка готовности для /ready (nil — готов всегда)
+
+	consumerStats func() models.ConsumerStats // счётчики потребителя для /consumer/stats
 }
 
 func New(cfg *config.Config, cache cache.Cache, logger *zap.Logger) (*Server, error) {
@@ -42,9 +46,21 @@ func New(cfg *config.Config, cache cache.Cache, logger *zap.Logger) (*Server, er
 	}, nil
 }
 
+// SetReadinessCheck задаёт проверку готовности сервиса; вызывается до Launch
+func (s *Server) SetReadinessCheck(ready func() bool) {
+	s.ready = ready
+}
+
+// SetConsumerStats задаёт источник счётчиков потребителя Kafka; вызывается до Launch
+func (s *Server) SetConsumerStats(stats func() models.ConsumerStats) {
+	s.consumerStats = stats
+}
+
 func (s *Server) Launch() error {
 	// Создаем контроллер с логгером
 	controller := router.NewController(s.Cache, s.logger)
+	controller.SetReadinessCheck(s.ready)
+	controller.SetConsumerStats(s.consumerStats)
 	r := controller.SetupRouter()
 
 	// Настраиваем HTTP сервер с таймаутами
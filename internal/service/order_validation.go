@@ -1,6 +1,7 @@
 package service
 
 import (
+	"fmt"
 	"regexp"
 	"strings"
 	"time"
@@ -21,34 +22,51 @@ func NewOrderValidator() *OrderValidator {
 
 // ValidateOrder проверяет валидность данных заказа
 func (v *OrderValidator) ValidateOrder(order models.Order) bool {
-	return v.validateRequiredFields(order) &&
-		v.validateDelivery(order.Delivery) &&
-		v.validatePayment(order.Payment) &&
-		v.validateItems(order.Items) &&
-		v.validateDate(order.DateCreated)
+	return len(v.InvalidFields(order)) == 0
 }
 
+// InvalidFields возвращает JSON-пути полей заказа, не прошедших проверку,
+// например "delivery.email" или "items[1].chrt_id"; пустой результат — заказ валиден
+func (v *OrderValidator) InvalidFields(order models.Order) []string {
+	var fields []string
+	check := func(ok bool, path string) {
+		if !ok {
+			fields = append(fields, path)
+		}
+	}
+
+	v.validateRequiredFields(order, check)
+	v.validateDelivery(order.Delivery, check)
+	v.validatePayment(order.Payment, check)
+	v.validateItems(order.Items, check)
+	check(v.validateDate(order.DateCreated), "date_created")
+	return fields
+}
+
+// fieldCheck отмечает поле path невалидным, если ok == false
+type fieldCheck func(ok bool, path string)
+
 // validateRequiredFields проверяет обязательные поля заказа
-func (v *OrderValidator) validateRequiredFields(order models.Order) bool {
-	return order.OrderUID != "" &&
-		order.TrackNumber != "" &&
-		order.EntryPoint != "" &&
-		order.LocaleCode != "" &&
-		order.CustomerId != "" &&
-		order.DeliveryService != "" &&
-		order.ShardKey != "" &&
-		order.OOFShard != ""
+func (v *OrderValidator) validateRequiredFields(order models.Order, check fieldCheck) {
+	check(order.OrderUID != "", "order_uid")
+	check(order.TrackNumber != "", "track_number")
+	check(order.EntryPoint != "", "entry")
+	check(order.LocaleCode != "", "locale")
+	check(order.CustomerId != "", "customer_id")
+	check(order.DeliveryService != "", "delivery_service")
+	check(order.ShardKey != "", "shardkey")
+	check(order.OOFShard != "", "oof_shard")
 }
 
 // validateDelivery проверяет валидность данных доставки
-func (v *OrderValidator) validateDelivery(delivery models.Delivery) bool {
-	return delivery.Name != "" &&
-		delivery.Phone != "" &&
-		delivery.Zip != "" &&
-		delivery.City != "" &&
-		delivery.Address != "" &&
-		delivery.Region != "" &&
-		v.isValidEmail(delivery.Email)
+func (v *OrderValidator) validateDelivery(delivery models.Delivery, check fieldCheck) {
+	check(delivery.Name != "", "delivery.name")
+	check(delivery.Phone != "", "delivery.phone")
+	check(delivery.Zip != "", "delivery.zip")
+	check(delivery.City != "", "delivery.city")
+	check(delivery.Address != "", "delivery.address")
+	check(delivery.Region != "", "delivery.region")
+	check(v.isValidEmail(delivery.Email), "delivery.email")
 }
 
 // isValidEmail проверяет, что email не пустой и соответствует формату
@@ -57,44 +75,37 @@ func (v *OrderValidator) isValidEmail(email string) bool {
 }
 
 // validatePayment проверяет валидность данных платежа
-func (v *OrderValidator) validatePayment(payment models.Payment) bool {
-	return payment.TransactionUID != "" &&
-		payment.CurrencyCode != "" &&
-		payment.PaymentProvider != "" &&
-		payment.BankCode != "" &&
-		payment.AmountTotal >= 0 &&
-		payment.DeliveryCost >= 0 &&
-		payment.GoodsTotal >= 0 &&
-		payment.CustomFee >= 0
+func (v *OrderValidator) validatePayment(payment models.Payment, check fieldCheck) {
+	check(payment.TransactionUID != "", "payment.transaction")
+	check(payment.CurrencyCode != "", "payment.currency")
+	check(payment.PaymentProvider != "", "payment.provider")
+	check(payment.BankCode != "", "payment.bank")
+	check(payment.AmountTotal >= 0, "payment.amount")
+	check(payment.DeliveryCost >= 0, "payment.delivery_cost")
+	check(payment.GoodsTotal >= 0, "payment.goods_total")
+	check(payment.CustomFee >= 0, "payment.custom_fee")
 }
 
 // validateItems проверяет валидность списка товаров
-func (v *OrderValidator) validateItems(items []models.OrderItem) bool {
-	if len(items) == 0 {
-		return false
-	}
-
-	for _, item := range items {
-		if !v.validateItem(item) {
-			return false
-		}
+func (v *OrderValidator) validateItems(items []models.OrderItem, check fieldCheck) {
+	check(len(items) > 0, "items")
+	for i, item := range items {
+		v.validateItem(item, fmt.Sprintf("items[%d]", i), check)
 	}
+}
 
-	return true
-}
-
-// validateItem проверяет валидность отдельного товара
-func (v *OrderValidator) validateItem(item models.OrderItem) bool {
-	return item.ChartID != 0 &&
-		item.TrackNumber != "" &&
-		item.UnitPrice >= 0 &&
-		item.RID != "" &&
-		item.ProductName != "" &&
-		item.SizeCode != "" &&
-		item.LineTotal >= 0 &&
-		item.ProductID != 0 &&
-		item.BrandName != "" &&
-		v.isValidSalePercent(item.SalePercent)
+// validateItem проверяет валидность отдельного товара; prefix — путь товара в заказе
+func (v *OrderValidator) validateItem(item models.OrderItem, prefix string, check fieldCheck) {
+	check(item.ChartID != 0, prefix+".chrt_id")
+	check(item.TrackNumber != "", prefix+".track_number")
+	check(item.UnitPrice >= 0, prefix+".price")
+	check(item.RID != "", prefix+".rid")
+	check(item.ProductName != "", prefix+".name")
+	check(item.SizeCode != "", prefix+".size")
+	check(item.LineTotal >= 0, prefix+".total_price")
+	check(item.ProductID != 0, prefix+".nm_id")
+	check(item.BrandName != "", prefix+".brand")
+	check(v.isValidSalePercent(item.SalePercent), prefix+".sale")
 }
 
 // isValidSalePercent проверяет, что скидка в диапазоне [0, 100]
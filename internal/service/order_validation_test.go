@@ -0,0 +1,102 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bondarenkozahar80-hub/Kafka-PostgreSQL-cache-test/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// validOrder возвращает заказ, проходящий все проверки валидатора
+func validOrder() models.Order {
+	return models.Order{
+		OrderUID:        "b563feb7b2b84b6test",
+		TrackNumber:     "WBILMTESTTRACK",
+		EntryPoint:      "WBIL",
+		LocaleCode:      "en",
+		CustomerId:      "test",
+		DeliveryService: "meest",
+		ShardKey:        "9",
+		StateMachineID:  99,
+		DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		OOFShard:        "1",
+		Delivery: models.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: models.Payment{
+			TransactionUID:  "b563feb7b2b84b6test",
+			CurrencyCode:    "USD",
+			PaymentProvider: "wbpay",
+			AmountTotal:     1817,
+			PaymentDateTime: 1637907727,
+			BankCode:        "alpha",
+			DeliveryCost:    1500,
+			GoodsTotal:      317,
+		},
+		Items: []models.OrderItem{{
+			ChartID:     9934930,
+			TrackNumber: "WBILMTESTTRACK",
+			UnitPrice:   453,
+			RID:         "ab4219087a764ae0btest",
+			ProductName: "Mascaras",
+			SalePercent: 30,
+			SizeCode:    "0",
+			LineTotal:   317,
+			ProductID:   2389212,
+			BrandName:   "Vivienne Sabo",
+			StatusCode:  202,
+		}},
+	}
+}
+
+func TestOrderValidator_InvalidFields(t *testing.T) {
+	validator := NewOrderValidator()
+
+	tests := []struct {
+		name   string
+		modify func(order *models.Order)
+		fields []string
+	}{
+		{"valid order", func(order *models.Order) {}, nil},
+		{"missing top-level fields", func(order *models.Order) {
+			order.TrackNumber = ""
+			order.OOFShard = ""
+		}, []string{"track_number", "oof_shard"}},
+		{"malformed email", func(order *models.Order) {
+			order.Delivery.Email = "not-an-email"
+		}, []string{"delivery.email"}},
+		{"negative payment amounts", func(order *models.Order) {
+			order.Payment.AmountTotal = -1
+			order.Payment.CustomFee = -0.5
+		}, []string{"payment.amount", "payment.custom_fee"}},
+		{"no items", func(order *models.Order) {
+			order.Items = nil
+		}, []string{"items"}},
+		{"invalid fields of second item", func(order *models.Order) {
+			item := order.Items[0]
+			item.ChartID = 0
+			item.SalePercent = 150
+			order.Items = append(order.Items, item)
+		}, []string{"items[1].chrt_id", "items[1].sale"}},
+		{"zero creation date", func(order *models.Order) {
+			order.DateCreated = time.Time{}
+		}, []string{"date_created"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := validOrder()
+			tt.modify(&order)
+
+			assert.Equal(t, tt.fields, validator.InvalidFields(order))
+			assert.Equal(t, len(tt.fields) == 0, validator.ValidateOrder(order))
+		})
+	}
+}
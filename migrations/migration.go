@@ -1,13 +1,16 @@
 package migrations
 
 import (
+	"context"
 	"database/sql"
 	"embed"
 	"fmt"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"unicode"
 
 	"go.uber.org/zap"
 )
@@ -31,6 +34,10 @@ const (
 	MigrationDown MigrationType = "down"
 )
 
+// migrationLockID — ключ advisory-блокировки PostgreSQL: реплики, стартующие одновременно,
+// применяют миграции по очереди
+const migrationLockID = 7202411
+
 // MigrationManager управляет миграциями
 type MigrationManager struct {
 	db               *sql.DB
@@ -52,6 +59,12 @@ func NewMigrationManager(db *sql.DB, logger *zap.Logger) *MigrationManager {
 func (mgr *MigrationManager) Up() error {
 	mgr.logger.Info("Starting database migrations (Up)")
 
+	unlock, err := mgr.lock()
+	if err != nil {
+		return fmt.Errorf("can't acquire migrations lock: %w", err)
+	}
+	defer unlock()
+
 	if err := mgr.schemaMigrations.CreateMigrationTable(); err != nil {
 		return fmt.Errorf("can't create migrations table: %w", err)
 	}
@@ -157,7 +170,7 @@ func (mgr *MigrationManager) executeMigration(migration *MigrationFile) error {
 		}
 	}()
 
-	statements := strings.Split(migration.Content, ";")
+	statements := splitStatements(migration.Content)
 
 	for i, stmt := range statements {
 		trimmedStmt := strings.TrimSpace(stmt)
@@ -186,6 +199,79 @@ func (mgr *MigrationManager) executeMigration(migration *MigrationFile) error {
 	return nil
 }
 
+// lock берёт advisory-блокировку миграций на отдельном соединении; возвращает функцию её снятия
+func (mgr *MigrationManager) lock() (func(), error) {
+	ctx := context.Background()
+	conn, err := mgr.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return func() {
+		_, _ = conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", migrationLockID)
+		conn.Close()
+	}, nil
+}
+
+// dollarQuote находит открывающую долларовую кавычку ($$ или $tag$) в начале s
+var dollarQuote = regexp.MustCompile(`^\$([A-Za-z_][A-Za-z0-9_]*)?\$`)
+
+// splitStatements делит миграцию на SQL-выражения по ";" вне строк, комментариев и тел
+// функций в долларовых кавычках. Выражения из одних комментариев пропускаются.
+func splitStatements(content string) []string {
+	var statements []string
+	var current strings.Builder
+	hasCode := false
+
+	flush := func() {
+		if hasCode {
+			statements = append(statements, current.String())
+		}
+		current.Reset()
+		hasCode = false
+	}
+	// skipTo переносит в выражение content[i:] до конца закрывающей последовательности end
+	skipTo := func(i int, start int, end string) int {
+		j := strings.Index(content[start:], end)
+		if j < 0 {
+			current.WriteString(content[i:])
+			return len(content)
+		}
+		stop := start + j + len(end)
+		current.WriteString(content[i:stop])
+		return stop
+	}
+
+	for i := 0; i < len(content); {
+		switch c := content[i]; {
+		case strings.HasPrefix(content[i:], "--"):
+			i = skipTo(i, i, "\n")
+		case c == '\'':
+			hasCode = true
+			i = skipTo(i, i+1, "'")
+		case c == '$' && dollarQuote.MatchString(content[i:]):
+			hasCode = true
+			tag := dollarQuote.FindString(content[i:])
+			i = skipTo(i, i+len(tag), tag)
+		case c == ';':
+			flush()
+			i++
+		default:
+			if !unicode.IsSpace(rune(c)) {
+				hasCode = true
+			}
+			current.WriteByte(c)
+			i++
+		}
+	}
+	flush()
+
+	return statements
+}
+
 // getMigrationFiles получает список файлов миграций
 func (mgr *MigrationManager) getMigrationFiles(migrationType MigrationType) ([]MigrationFile, error) {
 	//pattern := fmt.Sprintf("versions/*.%s.sql", string(migrationType))
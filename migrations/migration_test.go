@@ -0,0 +1,89 @@
+package migrations
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSplitStatements(t *testing.T) {
+	testCases := []struct {
+		description string
+		content     string
+		expected    []string
+	}{
+		{
+			"plain statements",
+			"CREATE TABLE a (id INT);\nCREATE INDEX i ON a(id);",
+			[]string{"CREATE TABLE a (id INT)", "CREATE INDEX i ON a(id)"},
+		},
+		{
+			"semicolon in comment",
+			"-- таблица; комментарий\nCREATE TABLE a (id INT);",
+			[]string{"-- таблица; комментарий\nCREATE TABLE a (id INT)"},
+		},
+		{
+			"semicolon in string",
+			"INSERT INTO a VALUES ('x;y');",
+			[]string{"INSERT INTO a VALUES ('x;y')"},
+		},
+		{
+			"dollar-quoted function body",
+			"CREATE FUNCTION f() RETURNS trigger AS $$\nBEGIN\n    RETURN NEW;\nEND;\n$$ LANGUAGE plpgsql;\nSELECT 1;",
+			[]string{"CREATE FUNCTION f() RETURNS trigger AS $$\nBEGIN\n    RETURN NEW;\nEND;\n$$ LANGUAGE plpgsql", "SELECT 1"},
+		},
+		{
+			"tagged dollar quote",
+			"DO $body$ BEGIN PERFORM 1; END $body$;",
+			[]string{"DO $body$ BEGIN PERFORM 1; END $body$"},
+		},
+		{
+			"trailing comment only",
+			"SELECT 1;\n-- конец",
+			[]string{"SELECT 1"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			statements := splitStatements(tc.content)
+			for i := range statements {
+				statements[i] = strings.TrimSpace(statements[i])
+			}
+			assert.Equal(t, tc.expected, statements)
+		})
+	}
+}
+
+func TestMigrationFiles_Split(t *testing.T) {
+	mgr := NewMigrationManager(nil, zap.NewNop())
+
+	for _, migrationType := range []MigrationType{MigrationUp, MigrationDown} {
+		files, err := mgr.getMigrationFiles(migrationType)
+		require.NoError(t, err)
+		require.NotEmpty(t, files)
+
+		for _, file := range files {
+			statements := splitStatements(file.Content)
+			assert.NotEmpty(t, statements, file.Path)
+			for _, stmt := range statements {
+				// Каждое выражение после комментариев начинается с SQL, а не с хвоста комментария
+				assert.Regexp(t, `^(CREATE|DROP|ALTER|INSERT)\b`, stripComments(stmt), file.Path)
+			}
+		}
+	}
+}
+
+// stripComments убирает из выражения строки комментариев
+func stripComments(stmt string) string {
+	var code []string
+	for _, line := range strings.Split(stmt, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			code = append(code, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(code, "\n"))
+}
@@ -0,0 +1,5 @@
+-- migrations/versions/006_add_orders_updated_at.up.sql
+-- Время последнего изменения заказа (для дозагрузки кэша после снимка)
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS idx_orders_updated_at ON orders(updated_at);
@@ -0,0 +1,2 @@
+-- migrations/versions/007_add_orders_date_created_index.down.sql
+DROP INDEX IF EXISTS idx_orders_date_created_uid;
@@ -0,0 +1,3 @@
+-- migrations/versions/007_add_orders_date_created_index.up.sql
+-- Keyset-пагинация прогрева кэша по (date_created, order_uid)
+CREATE INDEX IF NOT EXISTS idx_orders_date_created_uid ON orders(date_created, order_uid);
@@ -0,0 +1,2 @@
+-- migrations/versions/008_create_processed_messages.down.sql
+DROP TABLE IF EXISTS processed_messages;
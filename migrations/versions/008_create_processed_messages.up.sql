@@ -0,0 +1,10 @@
+-- migrations/versions/008_create_processed_messages.up.sql
+-- Ключи идемпотентности обработанных сообщений Kafka; пишутся в одной транзакции с заказом
+CREATE TABLE IF NOT EXISTS processed_messages
+(
+    message_key  VARCHAR(512) PRIMARY KEY NOT NULL,
+    order_uid    VARCHAR(255) NOT NULL,
+    processed_at TIMESTAMPTZ  NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_processed_messages_processed_at ON processed_messages(processed_at);
@@ -0,0 +1,7 @@
+-- migrations/versions/009_touch_orders_updated_at.down.sql
+DROP TRIGGER IF EXISTS items_touch_order ON items;
+DROP TRIGGER IF EXISTS payments_touch_order ON payments;
+DROP TRIGGER IF EXISTS deliveries_touch_order ON deliveries;
+DROP TRIGGER IF EXISTS orders_touch_updated_at ON orders;
+DROP FUNCTION IF EXISTS touch_parent_order_updated_at();
+DROP FUNCTION IF EXISTS touch_order_updated_at();
@@ -0,0 +1,37 @@
+-- migrations/versions/009_touch_orders_updated_at.up.sql
+-- updated_at меняется при изменении заказа, его доставки, оплаты или товаров, чтобы дозагрузка
+-- кэша после снимка (GetOrdersUpdatedSince) видела изменения. Вставка дочерних строк идёт вместе
+-- с заказом и отдельно не отслеживается
+CREATE OR REPLACE FUNCTION touch_order_updated_at() RETURNS trigger AS $$
+BEGIN
+    NEW.updated_at = now();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION touch_parent_order_updated_at() RETURNS trigger AS $$
+BEGIN
+    UPDATE orders SET updated_at = now() WHERE order_uid = NEW.order_uid;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS orders_touch_updated_at ON orders;
+CREATE TRIGGER orders_touch_updated_at
+    BEFORE UPDATE ON orders
+    FOR EACH ROW EXECUTE FUNCTION touch_order_updated_at();
+
+DROP TRIGGER IF EXISTS deliveries_touch_order ON deliveries;
+CREATE TRIGGER deliveries_touch_order
+    AFTER UPDATE ON deliveries
+    FOR EACH ROW EXECUTE FUNCTION touch_parent_order_updated_at();
+
+DROP TRIGGER IF EXISTS payments_touch_order ON payments;
+CREATE TRIGGER payments_touch_order
+    AFTER UPDATE ON payments
+    FOR EACH ROW EXECUTE FUNCTION touch_parent_order_updated_at();
+
+DROP TRIGGER IF EXISTS items_touch_order ON items;
+CREATE TRIGGER items_touch_order
+    AFTER UPDATE ON items
+    FOR EACH ROW EXECUTE FUNCTION touch_parent_order_updated_at();